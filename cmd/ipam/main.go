@@ -0,0 +1,75 @@
+// Command ipam manages the IPAM registry that records the CIDRs owned by each
+// stack.
+//
+// Stacks are named <project>/<stack>. To allocate the next free /16 block to
+// a new stack:
+//
+//	go run ./cmd/ipam allocate -registry ipam.yaml -stack pulumi-azure/prod -size /16
+//
+// To check that CIDRs don't collide with the allocations of other stacks:
+//
+//	go run ./cmd/ipam check -registry ipam.yaml -stack pulumi-azure/prod 10.1.0.0/16
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ihcsim/pulumi-azure/v2/pkg/ipam"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var (
+		flags       = flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+		registry    = flags.String("registry", "ipam.yaml", "path to the IPAM registry file")
+		stack       = flags.String("stack", "", "<project>/<stack> name of the stack")
+		size        = flags.String("size", "/16", "prefix length of the block to allocate")
+		description = flags.String("description", "", "description of the allocation")
+	)
+	flags.Parse(os.Args[2:])
+
+	if len(*stack) == 0 {
+		exit(fmt.Errorf("missing -stack"))
+	}
+
+	r, err := ipam.Load(*registry)
+	if err != nil {
+		exit(err)
+	}
+
+	switch os.Args[1] {
+	case "allocate":
+		cidr, err := r.Allocate(*stack, *size, *description)
+		if err != nil {
+			exit(err)
+		}
+
+		if err := r.Save(*registry); err != nil {
+			exit(err)
+		}
+		fmt.Println(cidr)
+
+	case "check":
+		if err := r.Check(*stack, flags.Args()); err != nil {
+			exit(err)
+		}
+
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s allocate|check -registry <path> -stack <project>/<stack> [-size /N] [cidr...]\n", os.Args[0])
+	os.Exit(2)
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
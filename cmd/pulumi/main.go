@@ -1,11 +1,16 @@
 package main
 
 import (
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/appgateway"
 	"github.com/ihcsim/pulumi-azure/v2/pkg/component/appsecgroup"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/backup"
 	"github.com/ihcsim/pulumi-azure/v2/pkg/component/bastion"
 	"github.com/ihcsim/pulumi-azure/v2/pkg/component/compute"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/firewall"
 	"github.com/ihcsim/pulumi-azure/v2/pkg/component/loadbalancer"
 	"github.com/ihcsim/pulumi-azure/v2/pkg/component/network"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/privatedns"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/privateendpoint"
 	"github.com/ihcsim/pulumi-azure/v2/pkg/component/publicip"
 	"github.com/ihcsim/pulumi-azure/v2/pkg/component/resourcegroup"
 	"github.com/pulumi/pulumi/sdk/go/pulumi"
@@ -33,29 +38,71 @@ func main() {
 			return err
 		}
 
-		virtualNetworks, err := network.Reconcile(ctx, cfg, appSecGroups, resourceGroup, commonTags)
+		publicIPs, publicIPPrefixes, err := publicip.Reconcile(ctx, cfg, resourceGroup, commonTags)
 		if err != nil {
 			return err
 		}
 
-		virtualMachines, err := compute.Reconcile(ctx, cfg, appSecGroups, resourceGroup, virtualNetworks, commonTags)
+		virtualNetworks, subnets, securityGroups, vpnGateways, err := network.Reconcile(ctx, cfg, appSecGroups, publicIPs, publicIPPrefixes, resourceGroup, commonTags)
 		if err != nil {
 			return err
 		}
 
-		publicIPs, err := publicip.Reconcile(ctx, cfg, resourceGroup, commonTags)
+		vpnGatewayIDs := pulumi.Map{}
+		for name, vpnGateway := range vpnGateways {
+			vpnGatewayIDs[name] = vpnGateway.ID()
+		}
+		ctx.Export("vpnGatewayIDs", vpnGatewayIDs)
+
+		if _, err := firewall.Reconcile(ctx, cfg, publicIPs, resourceGroup, subnets, commonTags); err != nil {
+			return err
+		}
+
+		backupPolicies, vaults, err := backup.Reconcile(ctx, cfg, resourceGroup, commonTags)
+		if err != nil {
+			return err
+		}
+
+		loadBalancers, err := loadbalancer.Reconcile(ctx, cfg, publicIPs, resourceGroup, subnets, commonTags)
+		if err != nil {
+			return err
+		}
+
+		vmGroups, err := compute.Reconcile(ctx, cfg, appSecGroups, backupPolicies, loadBalancers, resourceGroup, securityGroups, subnets, virtualNetworks, commonTags)
 		if err != nil {
 			return err
 		}
 
-		if _, err := bastion.Reconcile(ctx, cfg, publicIPs, resourceGroup, virtualNetworks, commonTags); err != nil {
+		if _, err := bastion.Reconcile(ctx, cfg, publicIPs, resourceGroup, subnets, virtualNetworks, commonTags); err != nil {
 			return err
 		}
 
-		if _, err := loadbalancer.Reconcile(ctx, cfg, publicIPs, resourceGroup, virtualMachines, commonTags); err != nil {
+		if _, err := appgateway.Reconcile(ctx, cfg, publicIPs, resourceGroup, subnets, vmGroups, commonTags); err != nil {
 			return err
 		}
 
+		privateDNSZones, err := privatedns.Reconcile(ctx, cfg, loadBalancers, resourceGroup, virtualNetworks, vmGroups, commonTags)
+		if err != nil {
+			return err
+		}
+
+		privateEndpointTargets := map[string]map[string]pulumi.IDOutput{
+			"recoveryServicesVault": {},
+		}
+		for name, vault := range vaults {
+			privateEndpointTargets["recoveryServicesVault"][name] = vault.ID()
+		}
+
+		if _, err := privateendpoint.Reconcile(ctx, cfg, privateDNSZones, resourceGroup, subnets, privateEndpointTargets, commonTags); err != nil {
+			return err
+		}
+
+		privateIPAddresses := pulumi.Map{}
+		for name, vmGroup := range vmGroups {
+			privateIPAddresses[name] = vmGroup.PrivateIPAddresses()
+		}
+		ctx.Export("privateIPAddresses", privateIPAddresses)
+
 		return nil
 	})
 }
@@ -0,0 +1,547 @@
+package appgateway
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/bastion"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/compute"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/firewall"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/loadbalancer"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/privateendpoint"
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+const (
+	defaultCapacity                = 2
+	defaultProbeHost               = "127.0.0.1"
+	defaultProbeInterval           = 30
+	defaultProbeTimeout            = 30
+	defaultProbeUnhealthyThreshold = 3
+)
+
+// reservedSubnets can't be shared with an application gateway.
+var reservedSubnets = map[string]bool{
+	"AzureBastionSubnet":  true,
+	"AzureFirewallSubnet": true,
+	"GatewaySubnet":       true,
+}
+
+func Reconcile(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	publicIPs map[string]*network.PublicIp,
+	resourceGroup *core.ResourceGroup,
+	subnets map[string]*network.Subnet,
+	vmGroups map[string]*compute.VMGroup,
+	tags pulumi.StringMap) (map[string]*network.ApplicationGateway, error) {
+
+	applicationGatewayInput := []*ApplicationGatewayInput{}
+	if err := cfg.GetObject("applicationGateways", &applicationGatewayInput); err != nil {
+		return nil, err
+	}
+
+	subnetUsers, err := subnetUsers(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	applicationGateways := map[string]*network.ApplicationGateway{}
+	for _, input := range applicationGatewayInput {
+		if err := validateApplicationGateway(input, subnetUsers); err != nil {
+			return nil, err
+		}
+
+		subnet, exists := subnets[input.Subnet]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{input.Subnet, "subnet"}
+		}
+
+		publicIP, exists := publicIPs[input.PublicIP]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{input.PublicIP, "public IP"}
+		}
+
+		for _, pool := range input.BackendPools {
+			for _, vmGroup := range pool.VMGroups {
+				if _, exists := vmGroups[vmGroup]; !exists {
+					return nil, pulumierr.MissingConfigErr{vmGroup, "virtual machine group"}
+				}
+			}
+		}
+
+		args := applicationGatewayArgs(input, publicIP, resourceGroup, subnet, tags)
+		applicationGateway, err := network.NewApplicationGateway(ctx, input.Name, args)
+		if err != nil {
+			return nil, err
+		}
+		applicationGateways[input.Name] = applicationGateway
+
+		for _, pool := range input.BackendPools {
+			backendAddressPoolID := pulumi.Sprintf("%s/backendAddressPools/%s", applicationGateway.ID(), pool.Name)
+			for _, vmGroup := range pool.VMGroups {
+				for _, instance := range vmGroups[vmGroup].Instances {
+					associationName := fmt.Sprintf("%s-%s-%s-association", input.Name, pool.Name, instance.Name)
+					if _, err := network.NewNetworkInterfaceApplicationGatewayBackendAddressPoolAssociation(ctx, associationName,
+						&network.NetworkInterfaceApplicationGatewayBackendAddressPoolAssociationArgs{
+							BackendAddressPoolId: backendAddressPoolID,
+							IpConfigurationName:  pulumi.String(instance.IPConfigurationName),
+							NetworkInterfaceId:   instance.NetworkInterface.ID(),
+						}); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	return applicationGateways, nil
+}
+
+// applicationGatewayArgs returns the arguments of the application gateway.
+// Backend pools are created empty; the network interfaces of their VM groups
+// are associated with them separately.
+func applicationGatewayArgs(
+	input *ApplicationGatewayInput,
+	publicIP *network.PublicIp,
+	resourceGroup *core.ResourceGroup,
+	subnet *network.Subnet,
+	tags pulumi.StringMap) *network.ApplicationGatewayArgs {
+
+	frontendIPConfigurationName := fmt.Sprintf("%s-frontend-config", input.Name)
+	args := &network.ApplicationGatewayArgs{
+		FrontendIpConfigurations: network.ApplicationGatewayFrontendIpConfigurationArray{
+			network.ApplicationGatewayFrontendIpConfigurationArgs{
+				Name:              pulumi.String(frontendIPConfigurationName),
+				PublicIpAddressId: publicIP.ID(),
+			},
+		},
+		GatewayIpConfigurations: network.ApplicationGatewayGatewayIpConfigurationArray{
+			network.ApplicationGatewayGatewayIpConfigurationArgs{
+				Name:     pulumi.String(fmt.Sprintf("%s-gateway-ip-config", input.Name)),
+				SubnetId: subnet.ID(),
+			},
+		},
+		Location:          resourceGroup.Location,
+		Name:              pulumi.String(input.Name),
+		ResourceGroupName: resourceGroup.Name,
+		Sku: network.ApplicationGatewaySkuArgs{
+			Capacity: pulumi.Int(withDefaultInt(input.Capacity, defaultCapacity)),
+			Name:     pulumi.String(input.SKU),
+			Tier:     pulumi.String(input.SKU),
+		},
+		Tags: tags,
+	}
+
+	if len(input.Zones) > 0 {
+		zones := pulumi.StringArray{}
+		for _, zone := range input.Zones {
+			zones = append(zones, pulumi.String(zone))
+		}
+		args.Zones = zones
+	}
+
+	if len(input.Identity) > 0 {
+		args.Identity = network.ApplicationGatewayIdentityArgs{
+			IdentityIds: pulumi.String(input.Identity),
+			Type:        pulumi.String("UserAssigned"),
+		}
+	}
+
+	backendAddressPools := network.ApplicationGatewayBackendAddressPoolArray{}
+	for _, pool := range input.BackendPools {
+		poolArgs := network.ApplicationGatewayBackendAddressPoolArgs{
+			Name: pulumi.String(pool.Name),
+		}
+
+		if len(pool.FQDNs) > 0 {
+			fqdns := pulumi.StringArray{}
+			for _, fqdn := range pool.FQDNs {
+				fqdns = append(fqdns, pulumi.String(fqdn))
+			}
+			poolArgs.Fqdns = fqdns
+		}
+
+		backendAddressPools = append(backendAddressPools, poolArgs)
+	}
+	args.BackendAddressPools = backendAddressPools
+
+	sslCertificates := network.ApplicationGatewaySslCertificateArray{}
+	for _, certificate := range input.Certificates {
+		certificateArgs := network.ApplicationGatewaySslCertificateArgs{
+			Name: pulumi.String(certificate.Name),
+		}
+
+		if len(certificate.KeyVaultSecretID) > 0 {
+			certificateArgs.KeyVaultSecretId = pulumi.String(certificate.KeyVaultSecretID)
+		} else {
+			certificateArgs.Data = pulumi.ToSecret(pulumi.String(certificate.Data)).(pulumi.StringOutput)
+			certificateArgs.Password = pulumi.ToSecret(pulumi.String(certificate.Password)).(pulumi.StringOutput)
+		}
+
+		sslCertificates = append(sslCertificates, certificateArgs)
+	}
+	args.SslCertificates = sslCertificates
+
+	probes := network.ApplicationGatewayProbeArray{}
+	for _, probe := range input.Probes {
+		probes = append(probes, network.ApplicationGatewayProbeArgs{
+			Host:               pulumi.String(withDefault(probe.Host, defaultProbeHost)),
+			Interval:           pulumi.Int(withDefaultInt(probe.Interval, defaultProbeInterval)),
+			Name:               pulumi.String(probe.Name),
+			Path:               pulumi.String(probe.Path),
+			Protocol:           pulumi.String(probe.Protocol),
+			Timeout:            pulumi.Int(withDefaultInt(probe.Timeout, defaultProbeTimeout)),
+			UnhealthyThreshold: pulumi.Int(withDefaultInt(probe.UnhealthyThreshold, defaultProbeUnhealthyThreshold)),
+		})
+	}
+	args.Probes = probes
+
+	backendHTTPSettings := network.ApplicationGatewayBackendHttpSettingArray{}
+	for _, setting := range input.HTTPSettings {
+		cookieBasedAffinity := "Disabled"
+		if setting.CookieBasedAffinity {
+			cookieBasedAffinity = "Enabled"
+		}
+
+		settingArgs := network.ApplicationGatewayBackendHttpSettingArgs{
+			CookieBasedAffinity: pulumi.String(cookieBasedAffinity),
+			Name:                pulumi.String(setting.Name),
+			Port:                pulumi.Int(setting.Port),
+			Protocol:            pulumi.String(setting.Protocol),
+		}
+
+		if len(setting.HostName) > 0 {
+			settingArgs.HostName = pulumi.String(setting.HostName)
+		}
+
+		if len(setting.Path) > 0 {
+			settingArgs.Path = pulumi.String(setting.Path)
+		}
+
+		if len(setting.Probe) > 0 {
+			settingArgs.ProbeName = pulumi.String(setting.Probe)
+		}
+
+		if setting.RequestTimeout > 0 {
+			settingArgs.RequestTimeout = pulumi.Int(setting.RequestTimeout)
+		}
+
+		backendHTTPSettings = append(backendHTTPSettings, settingArgs)
+	}
+	args.BackendHttpSettings = backendHTTPSettings
+
+	var (
+		frontendPorts = network.ApplicationGatewayFrontendPortArray{}
+		httpListeners = network.ApplicationGatewayHttpListenerArray{}
+		ports         = map[int]bool{}
+	)
+	for _, listener := range input.Listeners {
+		frontendPortName := fmt.Sprintf("%s-port-%d", input.Name, listener.Port)
+		if !ports[listener.Port] {
+			frontendPorts = append(frontendPorts, network.ApplicationGatewayFrontendPortArgs{
+				Name: pulumi.String(frontendPortName),
+				Port: pulumi.Int(listener.Port),
+			})
+			ports[listener.Port] = true
+		}
+
+		listenerArgs := network.ApplicationGatewayHttpListenerArgs{
+			FrontendIpConfigurationName: pulumi.String(frontendIPConfigurationName),
+			FrontendPortName:            pulumi.String(frontendPortName),
+			Name:                        pulumi.String(listener.Name),
+			Protocol:                    pulumi.String(listener.Protocol),
+		}
+
+		if len(listener.HostName) > 0 {
+			listenerArgs.HostName = pulumi.String(listener.HostName)
+		}
+
+		if len(listener.Certificate) > 0 {
+			listenerArgs.SslCertificateName = pulumi.String(listener.Certificate)
+		}
+
+		httpListeners = append(httpListeners, listenerArgs)
+	}
+	args.FrontendPorts = frontendPorts
+	args.HttpListeners = httpListeners
+
+	var (
+		requestRoutingRules = network.ApplicationGatewayRequestRoutingRuleArray{}
+		urlPathMaps         = network.ApplicationGatewayUrlPathMapArray{}
+	)
+	for _, rule := range input.Rules {
+		ruleArgs := network.ApplicationGatewayRequestRoutingRuleArgs{
+			HttpListenerName: pulumi.String(rule.Listener),
+			Name:             pulumi.String(rule.Name),
+		}
+
+		if len(rule.PathRules) == 0 {
+			ruleArgs.BackendAddressPoolName = pulumi.String(rule.BackendPool)
+			ruleArgs.BackendHttpSettingsName = pulumi.String(rule.HTTPSetting)
+			ruleArgs.RuleType = pulumi.String("Basic")
+			requestRoutingRules = append(requestRoutingRules, ruleArgs)
+			continue
+		}
+
+		pathRules := network.ApplicationGatewayUrlPathMapPathRuleArray{}
+		for _, pathRule := range rule.PathRules {
+			paths := pulumi.StringArray{}
+			for _, path := range pathRule.Paths {
+				paths = append(paths, pulumi.String(path))
+			}
+
+			pathRules = append(pathRules, network.ApplicationGatewayUrlPathMapPathRuleArgs{
+				BackendAddressPoolName:  pulumi.String(pathRule.BackendPool),
+				BackendHttpSettingsName: pulumi.String(pathRule.HTTPSetting),
+				Name:                    pulumi.String(pathRule.Name),
+				Paths:                   paths,
+			})
+		}
+
+		urlPathMapName := fmt.Sprintf("%s-path-map", rule.Name)
+		urlPathMaps = append(urlPathMaps, network.ApplicationGatewayUrlPathMapArgs{
+			DefaultBackendAddressPoolName:  pulumi.String(rule.BackendPool),
+			DefaultBackendHttpSettingsName: pulumi.String(rule.HTTPSetting),
+			Name:                           pulumi.String(urlPathMapName),
+			PathRules:                      pathRules,
+		})
+
+		ruleArgs.RuleType = pulumi.String("PathBasedRouting")
+		ruleArgs.UrlPathMapName = pulumi.String(urlPathMapName)
+		requestRoutingRules = append(requestRoutingRules, ruleArgs)
+	}
+	args.RequestRoutingRules = requestRoutingRules
+	args.UrlPathMaps = urlPathMaps
+
+	if waf := input.WAF; waf != nil {
+		disabledRuleGroups := network.ApplicationGatewayWafConfigurationDisabledRuleGroupArray{}
+		for _, group := range waf.DisabledRuleGroups {
+			groupArgs := network.ApplicationGatewayWafConfigurationDisabledRuleGroupArgs{
+				RuleGroupName: pulumi.String(group.Name),
+			}
+
+			if len(group.Rules) > 0 {
+				rules := pulumi.IntArray{}
+				for _, rule := range group.Rules {
+					rules = append(rules, pulumi.Int(rule))
+				}
+				groupArgs.Rules = rules
+			}
+
+			disabledRuleGroups = append(disabledRuleGroups, groupArgs)
+		}
+
+		wafArgs := network.ApplicationGatewayWafConfigurationArgs{
+			DisabledRuleGroups: disabledRuleGroups,
+			Enabled:            pulumi.Bool(true),
+			FirewallMode:       pulumi.String(waf.Mode),
+			RuleSetType:        pulumi.String("OWASP"),
+			RuleSetVersion:     pulumi.String(waf.RuleSetVersion),
+		}
+
+		if waf.FileUploadLimitMB > 0 {
+			wafArgs.FileUploadLimitMb = pulumi.Int(waf.FileUploadLimitMB)
+		}
+
+		if waf.MaxRequestBodySizeKB > 0 {
+			wafArgs.MaxRequestBodySizeKb = pulumi.Int(waf.MaxRequestBodySizeKB)
+		}
+
+		args.WafConfiguration = wafArgs
+	}
+
+	return args
+}
+
+// subnetUsers returns the components of the stack, other than application
+// gateways, that use each subnet, e.g. "virtual machine group web". These are
+// the VM instances, internal load balancers, bastion hosts, firewalls and
+// private endpoints.
+func subnetUsers(cfg *config.Config) (map[string]string, error) {
+	virtualMachineInput := []*compute.VirtualMachineInput{}
+	if err := cfg.GetObject("virtualMachines", &virtualMachineInput); err != nil {
+		return nil, err
+	}
+
+	loadBalancerInput := []*loadbalancer.LoadBalancerInput{}
+	if err := cfg.GetObject("loadBalancers", &loadBalancerInput); err != nil {
+		return nil, err
+	}
+
+	bastionHostInput := []*bastion.BastionHostInput{}
+	if err := cfg.GetObject("bastionHosts", &bastionHostInput); err != nil {
+		return nil, err
+	}
+
+	firewallInput := []*firewall.FirewallInput{}
+	if err := cfg.GetObject("firewalls", &firewallInput); err != nil {
+		return nil, err
+	}
+
+	privateEndpointInput := []*privateendpoint.PrivateEndpointInput{}
+	if err := cfg.GetObject("privateEndpoints", &privateEndpointInput); err != nil {
+		return nil, err
+	}
+
+	users := map[string]string{}
+	for _, input := range virtualMachineInput {
+		for _, subnet := range compute.InstanceSubnets(input) {
+			users[subnet] = "virtual machine group " + input.Name
+		}
+	}
+
+	for _, input := range loadBalancerInput {
+		if len(input.PublicIP) == 0 {
+			users[input.Subnet] = "load balancer " + input.Name
+		}
+	}
+
+	for _, input := range bastionHostInput {
+		users[input.Subnet] = "bastion host " + input.Name
+	}
+
+	for _, input := range firewallInput {
+		users[input.Subnet] = "firewall " + input.Name
+	}
+
+	for _, input := range privateEndpointInput {
+		users[input.Subnet] = "private endpoint " + input.Name
+	}
+
+	return users, nil
+}
+
+// validateApplicationGateway ensures that the application gateway is in a
+// dedicated subnet, that isn't used by any of subnetUsers, and that its
+// listeners, rules and settings refer to components of the gateway.
+func validateApplicationGateway(input *ApplicationGatewayInput, subnetUsers map[string]string) error {
+	if reservedSubnets[input.Subnet] {
+		return pulumierr.InvalidConfigErr{input.Name, "application gateway",
+			fmt.Sprintf("subnet %s is reserved for another service", input.Subnet)}
+	}
+
+	if user, exists := subnetUsers[input.Subnet]; exists {
+		return pulumierr.InvalidConfigErr{input.Name, "application gateway",
+			fmt.Sprintf("subnet %s isn't dedicated: it's shared with %s", input.Subnet, user)}
+	}
+
+	switch input.SKU {
+	case "Standard_v2":
+		if input.WAF != nil {
+			return pulumierr.InvalidConfigErr{input.Name, "application gateway", "a web application firewall requires the WAF_v2 SKU"}
+		}
+	case "WAF_v2":
+		if input.WAF == nil {
+			return pulumierr.MissingConfigErr{input.Name, "application gateway WAF"}
+		}
+
+		if input.WAF.Mode != "Detection" && input.WAF.Mode != "Prevention" {
+			return pulumierr.InvalidConfigErr{input.Name, "application gateway",
+				fmt.Sprintf("unknown WAF mode %q", input.WAF.Mode)}
+		}
+	default:
+		return pulumierr.InvalidConfigErr{input.Name, "application gateway", fmt.Sprintf("unknown SKU %q", input.SKU)}
+	}
+
+	certificates := map[string]bool{}
+	for _, certificate := range input.Certificates {
+		if (len(certificate.Data) > 0) == (len(certificate.KeyVaultSecretID) > 0) {
+			return pulumierr.InvalidConfigErr{certificate.Name, "application gateway certificate",
+				"exactly one of data and keyVaultSecretID must be set"}
+		}
+
+		if len(certificate.KeyVaultSecretID) > 0 && len(input.Identity) == 0 {
+			return pulumierr.InvalidConfigErr{certificate.Name, "application gateway certificate",
+				"a Key Vault certificate requires the identity of the application gateway"}
+		}
+		certificates[certificate.Name] = true
+	}
+
+	listeners := map[string]bool{}
+	for _, listener := range input.Listeners {
+		switch listener.Protocol {
+		case "Http":
+			if len(listener.Certificate) > 0 {
+				return pulumierr.InvalidConfigErr{listener.Name, "application gateway listener", "an HTTP listener can't have a certificate"}
+			}
+		case "Https":
+			if !certificates[listener.Certificate] {
+				return pulumierr.MissingConfigErr{listener.Certificate, "application gateway certificate"}
+			}
+		default:
+			return pulumierr.InvalidConfigErr{listener.Name, "application gateway listener",
+				fmt.Sprintf("unknown protocol %q", listener.Protocol)}
+		}
+		listeners[listener.Name] = true
+	}
+
+	probes := map[string]bool{}
+	for _, probe := range input.Probes {
+		probes[probe.Name] = true
+	}
+
+	httpSettings := map[string]bool{}
+	for _, setting := range input.HTTPSettings {
+		if len(setting.Probe) > 0 && !probes[setting.Probe] {
+			return pulumierr.MissingConfigErr{setting.Probe, "application gateway probe"}
+		}
+		httpSettings[setting.Name] = true
+	}
+
+	backendPools := map[string]bool{}
+	for _, pool := range input.BackendPools {
+		backendPools[pool.Name] = true
+	}
+
+	for _, rule := range input.Rules {
+		if !listeners[rule.Listener] {
+			return pulumierr.MissingConfigErr{rule.Listener, "application gateway listener"}
+		}
+
+		targets := []*PathRuleInput{{BackendPool: rule.BackendPool, HTTPSetting: rule.HTTPSetting, Name: rule.Name, Paths: []string{"/"}}}
+		targets = append(targets, rule.PathRules...)
+		for _, target := range targets {
+			if !backendPools[target.BackendPool] {
+				return pulumierr.MissingConfigErr{target.BackendPool, "application gateway backend pool"}
+			}
+
+			if !httpSettings[target.HTTPSetting] {
+				return pulumierr.MissingConfigErr{target.HTTPSetting, "application gateway HTTP setting"}
+			}
+
+			if len(target.Paths) == 0 {
+				return pulumierr.MissingConfigErr{target.Name, "application gateway path rule paths"}
+			}
+
+			for _, path := range target.Paths {
+				if !strings.HasPrefix(path, "/") {
+					return pulumierr.InvalidConfigErr{target.Name, "application gateway path rule",
+						fmt.Sprintf("path %q must start with /", path)}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func withDefault(value, defaultValue string) string {
+	if len(value) == 0 {
+		return defaultValue
+	}
+
+	return value
+}
+
+func withDefaultInt(value, defaultValue int) int {
+	if value == 0 {
+		return defaultValue
+	}
+
+	return value
+}
@@ -0,0 +1,189 @@
+package appgateway
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/compute"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/mock"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/test"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+func TestReconcile(t *testing.T) {
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		publicIPs, err := test.MockPublicIPs(ctx)
+		if err != nil {
+			return err
+		}
+
+		subnets, err := test.MockSubnets(ctx)
+		if err != nil {
+			return err
+		}
+
+		networkInterface, err := network.NewNetworkInterface(ctx, test.NetworkInterfaceName, &network.NetworkInterfaceArgs{
+			IpConfigurations: network.NetworkInterfaceIpConfigurationArray{
+				network.NetworkInterfaceIpConfigurationArgs{
+					Name:                       pulumi.String(test.IPConfigurationName),
+					PrivateIpAddressAllocation: pulumi.String(test.IPConfigurationPrivateIPAddressAllocation),
+				},
+			},
+			Name:              pulumi.String(test.NetworkInterfaceName),
+			ResourceGroupName: resourceGroup.Name,
+		})
+		if err != nil {
+			return err
+		}
+
+		vmGroups := map[string]*compute.VMGroup{
+			test.VirtualMachineName: {
+				Instances: []*compute.VMInstance{{
+					IPConfigurationName: test.IPConfigurationName,
+					Name:                test.VirtualMachineInstanceName,
+					NetworkInterface:    networkInterface,
+				}},
+				Name: test.VirtualMachineName,
+			},
+		}
+
+		applicationGateways, err := Reconcile(ctx, cfg, publicIPs, resourceGroup, subnets, vmGroups, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		applicationGateway, exists := applicationGateways[test.ApplicationGatewayName]
+		if !exists {
+			t.Fatalf("expected application gateway %s to exist", test.ApplicationGatewayName)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		pulumi.All(applicationGateway.Name, applicationGateway.Sku.Name(), applicationGateway.UrlPathMaps.Index(pulumi.Int(0)).Name()).ApplyT(func(actuals []interface{}) error {
+			defer wg.Done()
+
+			if actual := actuals[0].(string); actual != test.ApplicationGatewayName {
+				t.Errorf("mismatch name. expected: %s, actual: %s", test.ApplicationGatewayName, actual)
+			}
+
+			if actual := actuals[1].(string); actual != test.ApplicationGatewaySKU {
+				t.Errorf("mismatch SKU. expected: %s, actual: %s", test.ApplicationGatewaySKU, actual)
+			}
+
+			if expected, actual := "web-path-map", actuals[2].(string); actual != expected {
+				t.Errorf("mismatch URL path map. expected: %s, actual: %s", expected, actual)
+			}
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateApplicationGateway(t *testing.T) {
+	valid := func() *ApplicationGatewayInput {
+		return &ApplicationGatewayInput{
+			BackendPools: []*BackendPoolInput{{Name: "web"}},
+			Certificates: []*CertificateInput{{Data: "data", Name: "cert"}},
+			HTTPSettings: []*HTTPSettingInput{{Name: "http", Probe: "http"}},
+			Listeners:    []*ListenerInput{{Certificate: "cert", Name: "https", Protocol: "Https"}},
+			Name:         "appgw",
+			Probes:       []*ProbeInput{{Name: "http"}},
+			Rules: []*RuleInput{{BackendPool: "web", HTTPSetting: "http", Listener: "https", Name: "web",
+				PathRules: []*PathRuleInput{{BackendPool: "web", HTTPSetting: "http", Name: "api", Paths: []string{"/api/*"}}}}},
+			SKU:    "WAF_v2",
+			Subnet: "appgw-subnet",
+			WAF:    &WAFInput{Mode: "Prevention", RuleSetVersion: "3.1"},
+		}
+	}
+
+	subnetUsers := map[string]string{
+		"subnet-00":        "load balancer internal",
+		"subnet-01":        "virtual machine group web",
+		"endpoints-subnet": "private endpoint vault",
+	}
+
+	var testCases = []struct {
+		name     string
+		mutate   func(*ApplicationGatewayInput)
+		expected bool
+	}{
+		{name: "valid", mutate: func(*ApplicationGatewayInput) {}, expected: true},
+		{name: "reserved subnet", mutate: func(i *ApplicationGatewayInput) { i.Subnet = "GatewaySubnet" }},
+		{name: "load balancer subnet", mutate: func(i *ApplicationGatewayInput) { i.Subnet = "subnet-00" }},
+		{name: "virtual machine subnet", mutate: func(i *ApplicationGatewayInput) { i.Subnet = "subnet-01" }},
+		{name: "private endpoint subnet", mutate: func(i *ApplicationGatewayInput) { i.Subnet = "endpoints-subnet" }},
+		{name: "unknown sku", mutate: func(i *ApplicationGatewayInput) { i.SKU = "Standard" }},
+		{name: "waf without waf sku", mutate: func(i *ApplicationGatewayInput) { i.SKU = "Standard_v2" }},
+		{name: "waf sku without waf", mutate: func(i *ApplicationGatewayInput) { i.WAF = nil }},
+		{name: "unknown waf mode", mutate: func(i *ApplicationGatewayInput) { i.WAF.Mode = "Blocking" }},
+		{name: "certificate data and key vault", mutate: func(i *ApplicationGatewayInput) {
+			i.Certificates[0].KeyVaultSecretID = "https://vault.vault.azure.net/secrets/cert"
+		}},
+		{name: "key vault without identity", mutate: func(i *ApplicationGatewayInput) {
+			i.Certificates[0] = &CertificateInput{KeyVaultSecretID: "https://vault.vault.azure.net/secrets/cert", Name: "cert"}
+		}},
+		{name: "key vault with identity", expected: true, mutate: func(i *ApplicationGatewayInput) {
+			i.Certificates[0] = &CertificateInput{KeyVaultSecretID: "https://vault.vault.azure.net/secrets/cert", Name: "cert"}
+			i.Identity = "identity-id"
+		}},
+		{name: "https without certificate", mutate: func(i *ApplicationGatewayInput) { i.Listeners[0].Certificate = "" }},
+		{name: "http with certificate", mutate: func(i *ApplicationGatewayInput) { i.Listeners[0].Protocol = "Http" }},
+		{name: "unknown probe", mutate: func(i *ApplicationGatewayInput) { i.HTTPSettings[0].Probe = "https" }},
+		{name: "unknown listener", mutate: func(i *ApplicationGatewayInput) { i.Rules[0].Listener = "http" }},
+		{name: "unknown backend pool", mutate: func(i *ApplicationGatewayInput) { i.Rules[0].PathRules[0].BackendPool = "api" }},
+		{name: "unknown http setting", mutate: func(i *ApplicationGatewayInput) { i.Rules[0].HTTPSetting = "https" }},
+		{name: "relative path", mutate: func(i *ApplicationGatewayInput) { i.Rules[0].PathRules[0].Paths = []string{"api/*"} }},
+	}
+
+	for _, tc := range testCases {
+		input := valid()
+		tc.mutate(input)
+
+		if err := validateApplicationGateway(input, subnetUsers); (err == nil) != tc.expected {
+			t.Errorf("mismatch validation (%s). expected valid: %t, actual error: %v", tc.name, tc.expected, err)
+		}
+	}
+}
+
+func TestSubnetUsers(t *testing.T) {
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		users, err := subnetUsers(cfg)
+		if err != nil {
+			return err
+		}
+
+		var expected = map[string]string{
+			"subnet-01":             "virtual machine group " + test.VirtualMachineName,
+			"subnet-02":             "virtual machine group " + test.VirtualMachineName,
+			test.FirewallSubnetName: "firewall " + test.FirewallName,
+		}
+		for subnet, user := range expected {
+			if actual := users[subnet]; actual != user {
+				t.Errorf("mismatch user of subnet %s. expected: %s, actual: %s", subnet, user, actual)
+			}
+		}
+
+		if user, exists := users[test.ApplicationGatewaySubnetName]; exists {
+			t.Errorf("expected subnet %s to be dedicated, used by %s", test.ApplicationGatewaySubnetName, user)
+		}
+
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
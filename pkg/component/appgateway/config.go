@@ -0,0 +1,108 @@
+package appgateway
+
+// ApplicationGatewayInput describes an L7 application gateway in a dedicated
+// subnet, with a public frontend IP. SKU is either Standard_v2 or WAF_v2.
+type ApplicationGatewayInput struct {
+	BackendPools []*BackendPoolInput
+	Capacity     int
+	Certificates []*CertificateInput
+	HTTPSettings []*HTTPSettingInput `json:"httpSettings"`
+	Identity     string
+	Listeners    []*ListenerInput
+	Name         string
+	Probes       []*ProbeInput
+	PublicIP     string
+	Rules        []*RuleInput
+	SKU          string `json:"sku"`
+	Subnet       string
+	WAF          *WAFInput `json:"waf"`
+	Zones        []string
+}
+
+// BackendPoolInput describes a backend pool made up of the instances of VM
+// groups, and of FQDNs.
+type BackendPoolInput struct {
+	FQDNs    []string `json:"fqdns"`
+	Name     string
+	VMGroups []string `json:"vmGroups"`
+}
+
+// CertificateInput describes a TLS certificate, either as a base64-encoded
+// PFX with its password, which should be set as secrets, or as the ID of a Key
+// Vault secret. Key Vault certificates require the user-assigned identity of
+// the application gateway.
+type CertificateInput struct {
+	Data             string
+	KeyVaultSecretID string `json:"keyVaultSecretID"`
+	Name             string
+	Password         string
+}
+
+// HTTPSettingInput describes how the application gateway connects to its
+// backends.
+type HTTPSettingInput struct {
+	CookieBasedAffinity bool
+	HostName            string
+	Name                string
+	Path                string
+	Port                int
+	Probe               string
+	Protocol            string
+	RequestTimeout      int
+}
+
+// ListenerInput describes a listener on the frontend IP of the application
+// gateway. HTTPS listeners require a certificate.
+type ListenerInput struct {
+	Certificate string
+	HostName    string
+	Name        string
+	Port        int
+	Protocol    string
+}
+
+// ProbeInput describes a custom health probe.
+type ProbeInput struct {
+	Host               string
+	Interval           int
+	Name               string
+	Path               string
+	Protocol           string
+	Timeout            int
+	UnhealthyThreshold int
+}
+
+// RuleInput describes a request routing rule. Requests that don't match any of
+// its path rules are sent to its backend pool.
+type RuleInput struct {
+	BackendPool string
+	HTTPSetting string `json:"httpSetting"`
+	Listener    string
+	Name        string
+	PathRules   []*PathRuleInput
+}
+
+// PathRuleInput describes a path-based routing rule.
+type PathRuleInput struct {
+	BackendPool string
+	HTTPSetting string `json:"httpSetting"`
+	Name        string
+	Paths       []string
+}
+
+// WAFInput describes the web application firewall of a WAF_v2 application
+// gateway. Mode is either Detection or Prevention.
+type WAFInput struct {
+	DisabledRuleGroups   []*DisabledRuleGroupInput
+	FileUploadLimitMB    int `json:"fileUploadLimitMB"`
+	MaxRequestBodySizeKB int `json:"maxRequestBodySizeKB"`
+	Mode                 string
+	RuleSetVersion       string
+}
+
+// DisabledRuleGroupInput describes an OWASP rule group, or some of its rules,
+// that the web application firewall doesn't enforce.
+type DisabledRuleGroupInput struct {
+	Name  string
+	Rules []int
+}
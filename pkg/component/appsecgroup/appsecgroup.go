@@ -20,6 +20,30 @@ func Reconcile(
 
 	appSecGroups := map[string]*network.ApplicationSecurityGroup{}
 	for _, input := range appSecGroupsInput {
+		if input.Existing != nil {
+			id, err := input.Existing.Resolve(input.Name, "application security group", func(name, resourceGroup string) (string, error) {
+				result, err := network.LookupApplicationSecurityGroup(ctx, &network.LookupApplicationSecurityGroupArgs{
+					Name:              name,
+					ResourceGroupName: resourceGroup,
+				})
+				if err != nil {
+					return "", err
+				}
+				return result.Id, nil
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			appSecGroup, err := network.GetApplicationSecurityGroup(ctx, input.Name, id, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			appSecGroups[input.Name] = appSecGroup
+			continue
+		}
+
 		appSecGroup, err := network.NewApplicationSecurityGroup(ctx, input.Name,
 			&network.ApplicationSecurityGroupArgs{
 				Location:          resourceGroup.Location,
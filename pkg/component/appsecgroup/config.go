@@ -1,5 +1,8 @@
 package appsecgroup
 
+import "github.com/ihcsim/pulumi-azure/v2/pkg/existing"
+
 type ApplicationSecurityGroupInput struct {
-	Name string
+	Existing *existing.Input
+	Name     string
 }
@@ -0,0 +1,123 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/ihcsim/pulumi-azure/v2/pkg/convert"
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/backup"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/recoveryservices"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+// Reconcile creates the recovery services vault and its backup policies. The
+// vault is returned keyed by its name, if it's configured.
+func Reconcile(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	resourceGroup *core.ResourceGroup,
+	tags pulumi.StringMap) (map[string]*backup.PolicyVM, map[string]*recoveryservices.Vault, error) {
+
+	backupPolicyInput := []*BackupPolicyInput{}
+	if err := cfg.GetObject("backupPolicies", &backupPolicyInput); err != nil {
+		return nil, nil, err
+	}
+
+	var vaultInput *RecoveryServicesVaultInput
+	if err := cfg.GetObject("recoveryServicesVault", &vaultInput); err != nil {
+		return nil, nil, err
+	}
+
+	for _, input := range backupPolicyInput {
+		if err := validateBackupPolicy(input); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var (
+		backupPolicies = map[string]*backup.PolicyVM{}
+		vaults         = map[string]*recoveryservices.Vault{}
+	)
+	if vaultInput == nil {
+		if len(backupPolicyInput) > 0 {
+			return nil, nil, pulumierr.MissingConfigErr{"recoveryServicesVault", "recovery services vault"}
+		}
+		return backupPolicies, vaults, nil
+	}
+
+	vault, err := recoveryservices.NewVault(ctx, vaultInput.Name, &recoveryservices.VaultArgs{
+		Location:          resourceGroup.Location,
+		Name:              pulumi.String(vaultInput.Name),
+		ResourceGroupName: resourceGroup.Name,
+		Sku:               pulumi.String(vaultInput.SKU),
+		SoftDeleteEnabled: pulumi.Bool(!vaultInput.SoftDeleteDisabled),
+		Tags:              tags,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	vaults[vaultInput.Name] = vault
+
+	for _, input := range backupPolicyInput {
+		args := &backup.PolicyVMArgs{
+			Backup: backup.PolicyVMBackupArgs{
+				Frequency: pulumi.String(input.Frequency),
+				Time:      pulumi.String(input.Time),
+				Weekdays:  convert.ToStringArray(input.Weekdays),
+			},
+			Name:              pulumi.String(input.Name),
+			RecoveryVaultName: vault.Name,
+			ResourceGroupName: resourceGroup.Name,
+			Tags:              tags,
+		}
+
+		if input.RetentionDays > 0 {
+			args.RetentionDaily = backup.PolicyVMRetentionDailyArgs{
+				Count: pulumi.Int(input.RetentionDays),
+			}
+		}
+
+		if input.RetentionWeeks > 0 {
+			args.RetentionWeekly = backup.PolicyVMRetentionWeeklyArgs{
+				Count:    pulumi.Int(input.RetentionWeeks),
+				Weekdays: convert.ToStringArray(input.RetentionWeekdays),
+			}
+		}
+
+		if len(input.Timezone) > 0 {
+			args.Timezone = pulumi.String(input.Timezone)
+		}
+
+		backupPolicy, err := backup.NewPolicyVM(ctx, input.Name, args)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		backupPolicies[input.Name] = backupPolicy
+	}
+
+	return backupPolicies, vaults, nil
+}
+
+// validateBackupPolicy ensures that the retention counts of the backup policy
+// are within the limits of Azure, so that the policy isn't rejected after the
+// vault is created.
+func validateBackupPolicy(input *BackupPolicyInput) error {
+	if input.RetentionDays != 0 && (input.RetentionDays < 7 || input.RetentionDays > 9999) {
+		return pulumierr.InvalidConfigErr{input.Name, "backup policy",
+			fmt.Sprintf("retentionDays must be between 7 and 9999, got %d", input.RetentionDays)}
+	}
+
+	if input.RetentionWeeks != 0 && (input.RetentionWeeks < 1 || input.RetentionWeeks > 5163) {
+		return pulumierr.InvalidConfigErr{input.Name, "backup policy",
+			fmt.Sprintf("retentionWeeks must be between 1 and 5163, got %d", input.RetentionWeeks)}
+	}
+
+	if input.RetentionWeeks > 0 && len(input.RetentionWeekdays) == 0 {
+		return pulumierr.MissingConfigErr{input.Name, "backup policy retention weekdays"}
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package backup
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ihcsim/pulumi-azure/v2/pkg/mock"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/test"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/backup"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+func TestReconcile(t *testing.T) {
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		var (
+			cfg  = config.New(ctx, test.ConfigNamespace)
+			tags = test.Tags
+		)
+
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		backupPolicies, vaults, err := Reconcile(ctx, cfg, resourceGroup, tags)
+		if err != nil {
+			return err
+		}
+
+		vault, exists := vaults[test.RecoveryServicesVaultName]
+		if !exists {
+			t.Fatalf("missing recovery services vault: %s", test.RecoveryServicesVaultName)
+		}
+
+		backupPolicy, exists := backupPolicies[test.BackupPolicyName]
+		if !exists {
+			t.Fatalf("missing backup policy: %s", test.BackupPolicyName)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		vault.SoftDeleteEnabled.ApplyT(func(actual *bool) error {
+			defer wg.Done()
+
+			// soft delete is enabled unless it's disabled explicitly
+			if actual == nil || !*actual {
+				t.Errorf("expected soft delete of recovery services vault %s to be enabled", test.RecoveryServicesVaultName)
+			}
+
+			return nil
+		})
+
+		wg.Add(1)
+		pulumi.All(
+			backupPolicy.Backup,
+			backupPolicy.Name,
+			backupPolicy.RecoveryVaultName,
+			backupPolicy.ResourceGroupName,
+			backupPolicy.RetentionDaily).ApplyT(func(actuals []interface{}) error {
+			defer wg.Done()
+
+			if actual := actuals[0].(backup.PolicyVMBackup); actual.Frequency != test.BackupPolicyFrequency || actual.Time != test.BackupPolicyTime {
+				t.Errorf("backup schedule mismatch. expected: %s at %s, actual: %s at %s",
+					test.BackupPolicyFrequency, test.BackupPolicyTime, actual.Frequency, actual.Time)
+			}
+
+			if actual := actuals[1].(string); actual != test.BackupPolicyName {
+				t.Errorf("names mismatch. expected: %s, actual: %s", test.BackupPolicyName, actual)
+			}
+
+			if actual := actuals[2].(string); actual != test.RecoveryServicesVaultName {
+				t.Errorf("recovery vault names mismatch. expected: %s, actual: %s", test.RecoveryServicesVaultName, actual)
+			}
+
+			if actual := actuals[3].(string); actual != test.ResourceGroupName {
+				t.Errorf("resource groups mismatch. expected: %s, actual: %s", test.ResourceGroupName, actual)
+			}
+
+			if actual := actuals[4].(*backup.PolicyVMRetentionDaily); actual == nil || actual.Count != test.BackupPolicyRetentionDays {
+				t.Errorf("daily retention mismatch. expected: %d, actual: %v", test.BackupPolicyRetentionDays, actual)
+			}
+
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateBackupPolicy(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		input    *BackupPolicyInput
+		expected bool
+	}{
+		{
+			name:     "daily retention",
+			input:    &BackupPolicyInput{Name: test.BackupPolicyName, RetentionDays: 7},
+			expected: true,
+		},
+		{
+			name:     "weekly retention",
+			input:    &BackupPolicyInput{Name: test.BackupPolicyName, RetentionWeekdays: []string{"Sunday"}, RetentionWeeks: 5163},
+			expected: true,
+		},
+		{
+			name:  "daily retention too short",
+			input: &BackupPolicyInput{Name: test.BackupPolicyName, RetentionDays: 6},
+		},
+		{
+			name:  "daily retention too long",
+			input: &BackupPolicyInput{Name: test.BackupPolicyName, RetentionDays: 10000},
+		},
+		{
+			name:  "negative weekly retention",
+			input: &BackupPolicyInput{Name: test.BackupPolicyName, RetentionWeekdays: []string{"Sunday"}, RetentionWeeks: -1},
+		},
+		{
+			name:  "weekly retention too long",
+			input: &BackupPolicyInput{Name: test.BackupPolicyName, RetentionWeekdays: []string{"Sunday"}, RetentionWeeks: 5164},
+		},
+		{
+			name:  "weekly retention without weekdays",
+			input: &BackupPolicyInput{Name: test.BackupPolicyName, RetentionWeeks: 4},
+		},
+	}
+
+	for _, tc := range testCases {
+		if err := validateBackupPolicy(tc.input); (err == nil) != tc.expected {
+			t.Errorf("mismatch validation (%s). expected valid: %t, actual error: %v", tc.name, tc.expected, err)
+		}
+	}
+}
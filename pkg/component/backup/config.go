@@ -0,0 +1,24 @@
+package backup
+
+// BackupPolicyInput describes a VM backup policy. RetentionDays must be
+// between 7 and 9999, and RetentionWeeks between 1 and 5163, with the
+// RetentionWeekdays of the weekly backups to keep.
+type BackupPolicyInput struct {
+	Frequency         string
+	Name              string
+	RetentionDays     int
+	RetentionWeekdays []string
+	RetentionWeeks    int
+	Time              string
+	Timezone          string
+	Weekdays          []string
+}
+
+// RecoveryServicesVaultInput describes the recovery services vault. Soft
+// delete keeps deleted backups for 14 days, and is only turned off by
+// SoftDeleteDisabled.
+type RecoveryServicesVaultInput struct {
+	Name               string
+	SKU                string `json:"sku"`
+	SoftDeleteDisabled bool
+}
@@ -1,8 +1,6 @@
 package bastion
 
 import (
-	"strings"
-
 	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/compute"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
@@ -16,6 +14,7 @@ func Reconcile(
 	cfg *config.Config,
 	publicIPs map[string]*network.PublicIp,
 	resourceGroup *core.ResourceGroup,
+	subnets map[string]*network.Subnet,
 	virtualNetworks map[string]*network.VirtualNetwork,
 	tags pulumi.StringMap) ([]*compute.BastionHost, error) {
 
@@ -26,24 +25,14 @@ func Reconcile(
 
 	bastionHosts := []*compute.BastionHost{}
 	for _, input := range bastionHostInput {
-		virtualNetwork, exists := virtualNetworks[input.VirtualNetwork]
-		if !exists {
+		if _, exists := virtualNetworks[input.VirtualNetwork]; !exists {
 			return nil, pulumierr.MissingConfigErr{input.VirtualNetwork, "virtual network"}
 		}
 
-		subnetID := virtualNetwork.Subnets.ApplyString(func(subnets []network.VirtualNetworkSubnet) (string, error) {
-			for _, subnet := range subnets {
-				if strings.Contains(subnet.Name, input.Subnet) {
-					if subnet.Id == nil {
-						return "", pulumierr.MissingConfigErr{input.Subnet, "subnet ID"}
-					}
-
-					return *subnet.Id, nil
-				}
-			}
-
-			return "", pulumierr.MissingConfigErr{input.Subnet, "subnet"}
-		})
+		subnet, exists := subnets[input.Subnet]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{input.Subnet, "subnet"}
+		}
 
 		publicIP, exists := publicIPs[input.PublicIP]
 		if !exists {
@@ -55,7 +44,7 @@ func Reconcile(
 				IpConfiguration: compute.BastionHostIpConfigurationArgs{
 					Name:              pulumi.String(input.Name),
 					PublicIpAddressId: publicIP.ID(),
-					SubnetId:          subnetID,
+					SubnetId:          subnet.ID(),
 				},
 				Location:          resourceGroup.Location,
 				Name:              pulumi.String(input.Name),
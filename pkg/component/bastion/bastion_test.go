@@ -25,12 +25,17 @@ func TestReconcile(t *testing.T) {
 			return err
 		}
 
+		subnets, err := test.MockSubnets(ctx)
+		if err != nil {
+			return err
+		}
+
 		virtualNetworks, err := test.MockVirtualNetworks(ctx)
 		if err != nil {
 			return err
 		}
 
-		bastions, err := Reconcile(ctx, cfg, publicIPs, resourceGroup, virtualNetworks, test.Tags)
+		bastions, err := Reconcile(ctx, cfg, publicIPs, resourceGroup, subnets, virtualNetworks, test.Tags)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,101 @@
+package compute
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/loadbalancer"
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+)
+
+// backendAddressPoolAssociations adds the network interface of a VM instance
+// to the backend pools of the load balancers that list its VM group as a
+// backend host. The IPv6 configuration of a dual-stack instance is added to
+// the IPv6 backend pool. The associations depend on the VM, so that they
+// complete only once a replaced VM is back.
+func backendAddressPoolAssociations(
+	ctx *pulumi.Context,
+	vmGroup string,
+	instance *VMInstance,
+	loadBalancers map[string]*loadbalancer.LoadBalancer) ([]*network.NetworkInterfaceBackendAddressPoolAssociation, error) {
+
+	names := []string{}
+	for name := range loadBalancers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	associations := []*network.NetworkInterfaceBackendAddressPoolAssociation{}
+	for _, name := range names {
+		loadBalancer := loadBalancers[name]
+		if !isBackendHost(loadBalancer, vmGroup) {
+			continue
+		}
+
+		associationName := fmt.Sprintf("%s-%s-association", name, instance.Name)
+		association, err := network.NewNetworkInterfaceBackendAddressPoolAssociation(ctx, associationName,
+			&network.NetworkInterfaceBackendAddressPoolAssociationArgs{
+				BackendAddressPoolId: loadBalancer.BackendAddressPool.ID(),
+				IpConfigurationName:  pulumi.String(instance.IPConfigurationName),
+				NetworkInterfaceId:   instance.NetworkInterface.ID(),
+			}, pulumi.DependsOn([]pulumi.Resource{instance.virtualMachine()}))
+		if err != nil {
+			return nil, err
+		}
+		associations = append(associations, association)
+
+		if loadBalancer.IPv6BackendAddressPool == nil {
+			continue
+		}
+
+		if len(instance.IPv6ConfigurationName) == 0 {
+			return nil, pulumierr.InvalidConfigErr{name, "load balancer",
+				fmt.Sprintf("backend host %s has no IPv6 configuration", instance.Name)}
+		}
+
+		associationName = fmt.Sprintf("%s-%s-ipv6-association", name, instance.Name)
+		association, err = network.NewNetworkInterfaceBackendAddressPoolAssociation(ctx, associationName,
+			&network.NetworkInterfaceBackendAddressPoolAssociationArgs{
+				BackendAddressPoolId: loadBalancer.IPv6BackendAddressPool.ID(),
+				IpConfigurationName:  pulumi.String(instance.IPv6ConfigurationName),
+				NetworkInterfaceId:   instance.NetworkInterface.ID(),
+			}, pulumi.DependsOn([]pulumi.Resource{instance.virtualMachine()}))
+		if err != nil {
+			return nil, err
+		}
+		associations = append(associations, association)
+	}
+
+	return associations, nil
+}
+
+// validateBackendHosts ensures that the backend hosts of the load balancers
+// are known VM groups, before any VM is created.
+func validateBackendHosts(loadBalancers map[string]*loadbalancer.LoadBalancer, virtualMachineInput []*VirtualMachineInput) error {
+	vmGroups := map[string]bool{}
+	for _, input := range virtualMachineInput {
+		vmGroups[input.Name] = true
+	}
+
+	for _, loadBalancer := range loadBalancers {
+		for _, backendHost := range loadBalancer.BackendHosts {
+			if !vmGroups[backendHost] {
+				return pulumierr.MissingConfigErr{backendHost, "virtual machine group"}
+			}
+		}
+	}
+
+	return nil
+}
+
+func isBackendHost(loadBalancer *loadbalancer.LoadBalancer, vmGroup string) bool {
+	for _, backendHost := range loadBalancer.BackendHosts {
+		if backendHost == vmGroup {
+			return true
+		}
+	}
+
+	return false
+}
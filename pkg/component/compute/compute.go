@@ -2,40 +2,63 @@ package compute
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math"
+	"strconv"
 	"strings"
 
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/loadbalancer"
+	vnet "github.com/ihcsim/pulumi-azure/v2/pkg/component/network"
 	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/backup"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/compute"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/proximity"
 	"github.com/pulumi/pulumi/sdk/go/pulumi"
 	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+	"golang.org/x/crypto/ssh"
 )
 
 func Reconcile(
 	ctx *pulumi.Context,
 	cfg *config.Config,
 	appSecGroups map[string]*network.ApplicationSecurityGroup,
+	backupPolicies map[string]*backup.PolicyVM,
+	loadBalancers map[string]*loadbalancer.LoadBalancer,
 	resourceGroup *core.ResourceGroup,
+	securityGroups map[string]pulumi.IDOutput,
+	subnets map[string]*network.Subnet,
 	virtualNetworks map[string]*network.VirtualNetwork,
-	tags pulumi.StringMap) (map[string]*compute.VirtualMachine, error) {
+	tags pulumi.StringMap) (map[string]*VMGroup, error) {
 
-	availabilitySets, err := availabilitySets(ctx, cfg, resourceGroup, tags)
+	proximityPlacementGroups, err := proximityPlacementGroups(ctx, cfg, resourceGroup, tags)
 	if err != nil {
 		return nil, err
 	}
 
-	osProfiles, err := osProfiles(ctx, cfg)
+	availabilitySets, err := availabilitySets(ctx, cfg, proximityPlacementGroups, resourceGroup, tags)
 	if err != nil {
 		return nil, err
 	}
 
-	osProfilesLinux, err := osProfilesLinux(ctx, cfg)
+	dedicatedHosts, err := dedicatedHosts(ctx, cfg, resourceGroup, tags)
 	if err != nil {
 		return nil, err
 	}
 
+	credentials := newCredentials(ctx, cfg)
+	osProfiles, err := osProfiles(ctx, cfg, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	osProfilesLinux, err := osProfilesLinux(ctx, cfg, credentials)
+	if err != nil {
+		return nil, err
+	}
+	credentials.export()
+
 	storageImageReferences, err := storageImageReferences(ctx, cfg)
 	if err != nil {
 		return nil, err
@@ -46,15 +69,26 @@ func Reconcile(
 		return nil, err
 	}
 
+	autoShutdownSchedules, defaultAutoShutdownSchedule, autoShutdownEnabled, err := autoShutdownSchedules(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	virtualMachineInput := []*VirtualMachineInput{}
 	if err := cfg.TryObject("virtualMachines", &virtualMachineInput); err != nil {
 		return nil, err
 	}
 
-	virtualMachines := map[string]*compute.VirtualMachine{}
+	if err := validateBackendHosts(loadBalancers, virtualMachineInput); err != nil {
+		return nil, err
+	}
+
+	var (
+		checkedSecurityGroups = map[string]bool{}
+		vmGroups              = map[string]*VMGroup{}
+	)
 	for _, input := range virtualMachineInput {
-		virtualNetwork, exists := virtualNetworks[input.VirtualNetwork]
-		if !exists {
+		if _, exists := virtualNetworks[input.VirtualNetwork]; !exists {
 			return nil, pulumierr.MissingConfigErr{input.VirtualNetwork, "virtual network"}
 		}
 
@@ -79,9 +113,22 @@ func Reconcile(
 		}
 		storageOSDisk.Name = pulumi.String(input.Name)
 
-		availabilitySet, exists := availabilitySets[input.AvailabilitySet]
-		if !exists {
-			return nil, pulumierr.MissingConfigErr{input.AvailabilitySet, "availability set"}
+		var (
+			availabilitySet           *compute.AvailabilitySet
+			dedicatedHost             *dedicatedHost
+			proximityPlacementGroupID pulumi.StringPtrInput
+		)
+		if len(input.DedicatedHost) > 0 {
+			if err := validateDedicatedHostPlacement(input, dedicatedHosts); err != nil {
+				return nil, err
+			}
+			dedicatedHost = dedicatedHosts[input.DedicatedHost]
+		} else {
+			availabilitySet, exists = availabilitySets[input.AvailabilitySet]
+			if !exists {
+				return nil, pulumierr.MissingConfigErr{input.AvailabilitySet, "availability set"}
+			}
+			proximityPlacementGroupID = availabilitySet.ProximityPlacementGroupId
 		}
 
 		appSecGroup, exists := appSecGroups[input.AppSecGroup]
@@ -89,79 +136,321 @@ func Reconcile(
 			return nil, pulumierr.MissingConfigErr{input.AppSecGroup, "application security group"}
 		}
 
-		var (
-			paddingLen         = int(math.Round(float64(input.Count)/10)) + 1
-			instanceNamePrefix = fmt.Sprintf("%s-%s", input.Name, strings.Repeat("0", paddingLen))
-		)
+		var backupPolicy *backup.PolicyVM
+		if len(input.BackupPolicy) > 0 {
+			backupPolicy, exists = backupPolicies[input.BackupPolicy]
+			if !exists {
+				return nil, pulumierr.MissingConfigErr{input.BackupPolicy, "backup policy"}
+			}
+		}
+
+		autoShutdownSchedule := input.AutoShutdown
+		if len(autoShutdownSchedule) == 0 {
+			autoShutdownSchedule = defaultAutoShutdownSchedule
+		}
+
+		autoShutdownInput, exists := autoShutdownSchedules[autoShutdownSchedule]
+		if !exists && len(autoShutdownSchedule) > 0 {
+			return nil, pulumierr.MissingConfigErr{autoShutdownSchedule, "auto-shutdown schedule"}
+		}
+
+		if len(input.ProximityPlacementGroup) > 0 {
+			proximityPlacementGroup, exists := proximityPlacementGroups[input.ProximityPlacementGroup]
+			if !exists {
+				return nil, pulumierr.MissingConfigErr{input.ProximityPlacementGroup, "proximity placement group"}
+			}
+			proximityPlacementGroupID = proximityPlacementGroup.ToStringOutput().ToStringPtrOutput()
+		}
+
+		instanceNamePrefix := instanceNamePrefix(input)
+		if err := validateOverrides(input, instanceNamePrefix); err != nil {
+			return nil, err
+		}
+
+		if err := validateRollingUpdate(input); err != nil {
+			return nil, err
+		}
+
+		vmGroup := &VMGroup{
+			AppSecGroup:     appSecGroup,
+			AvailabilitySet: availabilitySet,
+			Name:            input.Name,
+			VirtualNetwork:  input.VirtualNetwork,
+		}
 
 		for i := 0; i < input.Count; i++ {
 			var (
 				instanceName = pulumi.String(fmt.Sprintf("%s%d", instanceNamePrefix, i))
-				targetSubnet = fmt.Sprintf("subnet-0%d", i)
+				override     = instanceOverride(input, i, string(instanceName))
+				targetSubnet = instanceSubnet(i, override)
+				vmSize       = input.VMSize
+				customData   = input.CustomData
+				instanceTags = tags
 			)
-			subnetID := virtualNetwork.Subnets.ApplyString(func(subnets []network.VirtualNetworkSubnet) (string, error) {
-				for _, subnet := range subnets {
-					if strings.Contains(subnet.Name, targetSubnet) {
-						if subnet.Id == nil {
-							return "", pulumierr.MissingConfigErr{targetSubnet, "subnet ID"}
-						}
-						return *subnet.Id, nil
-					}
+
+			if len(override.VMSize) > 0 {
+				vmSize = override.VMSize
+			}
+
+			if len(override.CustomData) > 0 {
+				customData = strings.TrimPrefix(fmt.Sprintf("%s\n%s", customData, override.CustomData), "\n")
+			}
+
+			if len(override.Tags) > 0 {
+				instanceTags = pulumi.StringMap{}
+				for k, v := range tags {
+					instanceTags[k] = v
 				}
-				return "", nil
-			})
+				for k, v := range override.Tags {
+					instanceTags[k] = pulumi.String(v)
+				}
+			}
+
+			subnet, exists := subnets[targetSubnet]
+			if !exists {
+				return nil, pulumierr.MissingConfigErr{targetSubnet, "subnet"}
+			}
 
-			netInf, err := primaryNetworkInterface(ctx, cfg, appSecGroup, resourceGroup, instanceName, subnetID, tags)
+			if err := validateSecurityGroups(ctx, cfg, appSecGroups, input.NetworkInterface, targetSubnet, checkedSecurityGroups); err != nil {
+				return nil, err
+			}
+
+			rollingUpdateOptions := rollingUpdateOptions(input, vmGroup.Instances, i)
+
+			ipConfigurationName := fmt.Sprintf("%s-primary-ipconfig", instanceName)
+			netInf, ipv6ConfigurationName, err := primaryNetworkInterface(ctx, cfg, appSecGroup, resourceGroup, securityGroups, input.NetworkInterface, instanceName, ipConfigurationName, subnet.ID(), instanceTags, rollingUpdateOptions...)
 			if err != nil {
 				return nil, err
 			}
 
-			if len(input.CustomData) > 0 {
-				osProfile.CustomData = pulumi.Sprintf("%s\n%s", osProfile.CustomData, input.CustomData)
+			instanceOSProfile := osProfile
+			if len(customData) > 0 {
+				instanceOSProfile.CustomData = pulumi.Sprintf("%s\n%s", osProfile.CustomData, customData)
 			}
+			instanceOSProfile.ComputerName = instanceName
 
-			osProfile.ComputerName = instanceName
-			storageOSDisk.Name = instanceName
-
-			virtualMachine, err := compute.NewVirtualMachine(ctx, string(instanceName), &compute.VirtualMachineArgs{
-				AvailabilitySetId:         availabilitySet,
-				Location:                  resourceGroup.Location,
-				Name:                      instanceName,
-				OsProfile:                 osProfile,
-				OsProfileLinuxConfig:      osProfileLinux,
-				PrimaryNetworkInterfaceId: netInf.ID(),
-				NetworkInterfaceIds:       pulumi.StringArray{netInf.ID()},
-				StorageImageReference:     storageImageReference,
-				ResourceGroupName:         resourceGroup.Name,
-				StorageOsDisk:             storageOSDisk,
-				Tags:                      tags,
-				VmSize:                    pulumi.String(input.VMSize),
-			})
+			instanceStorageOSDisk := storageOSDisk
+			instanceStorageOSDisk.Name = instanceName
+			if override.DiskSizeGB > 0 {
+				instanceStorageOSDisk.DiskSizeGb = pulumi.Int(override.DiskSizeGB)
+			}
+
+			instance := &VMInstance{
+				IPConfigurationName:   ipConfigurationName,
+				IPv6ConfigurationName: ipv6ConfigurationName,
+				Name:                  string(instanceName),
+				NetworkInterface:      netInf,
+				PrivateIPAddress:      netInf.PrivateIpAddress,
+			}
+
+			if dedicatedHost != nil {
+				instance.LinuxVirtualMachine, err = dedicatedHostVirtualMachine(ctx, dedicatedHost, instanceName, instanceOSProfile, osProfileLinux, storageImageReference, instanceStorageOSDisk, netInf.ID(), proximityPlacementGroupID, resourceGroup, instanceTags, vmSize, rollingUpdateOptions...)
+			} else {
+				instance.VirtualMachine, err = compute.NewVirtualMachine(ctx, string(instanceName), &compute.VirtualMachineArgs{
+					AvailabilitySetId:         availabilitySet.ID(),
+					Location:                  resourceGroup.Location,
+					Name:                      instanceName,
+					OsProfile:                 instanceOSProfile,
+					OsProfileLinuxConfig:      osProfileLinux,
+					PrimaryNetworkInterfaceId: netInf.ID(),
+					NetworkInterfaceIds:       pulumi.StringArray{netInf.ID()},
+					ProximityPlacementGroupId: proximityPlacementGroupID,
+					StorageImageReference:     storageImageReference,
+					ResourceGroupName:         resourceGroup.Name,
+					StorageOsDisk:             instanceStorageOSDisk,
+					Tags:                      instanceTags,
+					VmSize:                    pulumi.String(vmSize),
+				}, rollingUpdateOptions...)
+			}
 			if err != nil {
 				return nil, err
 			}
+			virtualMachine := instance.virtualMachine()
+
+			if autoShutdownInput != nil {
+				if err := autoShutdown(ctx, autoShutdownInput, autoShutdownEnabled, resourceGroup, virtualMachine.ID(), string(instanceName), instanceTags); err != nil {
+					return nil, err
+				}
+			}
 
-			virtualMachines[string(instanceName)] = virtualMachine
+			if backupPolicy != nil {
+				if _, err := backup.NewProtectedVM(ctx, string(instanceName), &backup.ProtectedVMArgs{
+					BackupPolicyId:    backupPolicy.ID(),
+					RecoveryVaultName: backupPolicy.RecoveryVaultName,
+					ResourceGroupName: resourceGroup.Name,
+					SourceVmId:        virtualMachine.ID(),
+					Tags:              instanceTags,
+				}); err != nil {
+					return nil, err
+				}
+			}
+
+			instance.BackendAddressPoolAssociations, err = backendAddressPoolAssociations(ctx, input.Name, instance, loadBalancers)
+			if err != nil {
+				return nil, err
+			}
+
+			vmGroup.Instances = append(vmGroup.Instances, instance)
+		}
+
+		vmGroups[input.Name] = vmGroup
+	}
+
+	return vmGroups, nil
+}
+
+// InstanceSubnets returns the subnets of the instances of the virtual machine
+// group, in instance order.
+func InstanceSubnets(input *VirtualMachineInput) []string {
+	var (
+		prefix  = instanceNamePrefix(input)
+		subnets = []string{}
+	)
+	for i := 0; i < input.Count; i++ {
+		override := instanceOverride(input, i, fmt.Sprintf("%s%d", prefix, i))
+		subnets = append(subnets, instanceSubnet(i, override))
+	}
+
+	return subnets
+}
+
+func instanceNamePrefix(input *VirtualMachineInput) string {
+	paddingLen := int(math.Round(float64(input.Count)/10)) + 1
+	return fmt.Sprintf("%s-%s", input.Name, strings.Repeat("0", paddingLen))
+}
+
+// instanceSubnet returns the subnet of the i-th instance of a virtual machine
+// group, which is subnet-0<i> unless its override sets another one.
+func instanceSubnet(i int, override *VirtualMachineOverrideInput) string {
+	if len(override.Subnet) > 0 {
+		return override.Subnet
+	}
+
+	return fmt.Sprintf("subnet-0%d", i)
+}
+
+// instanceOverride returns the override of the i-th instance of the virtual
+// machine group. The index-keyed override is applied first, followed by the
+// name-keyed override.
+func instanceOverride(input *VirtualMachineInput, i int, instanceName string) *VirtualMachineOverrideInput {
+	merged := &VirtualMachineOverrideInput{}
+	for _, key := range []string{strconv.Itoa(i), instanceName} {
+		override, exists := input.Overrides[key]
+		if !exists || override == nil {
+			continue
+		}
+
+		if len(override.CustomData) > 0 {
+			merged.CustomData = override.CustomData
+		}
+
+		if override.DiskSizeGB > 0 {
+			merged.DiskSizeGB = override.DiskSizeGB
+		}
+
+		if len(override.Subnet) > 0 {
+			merged.Subnet = override.Subnet
+		}
+
+		if len(override.VMSize) > 0 {
+			merged.VMSize = override.VMSize
+		}
+
+		for k, v := range override.Tags {
+			if merged.Tags == nil {
+				merged.Tags = map[string]string{}
+			}
+			merged.Tags[k] = v
 		}
 	}
 
-	return virtualMachines, nil
+	return merged
+}
+
+// validateOverrides ensures that every override key refers to an instance of
+// the virtual machine group, either by index or by name.
+func validateOverrides(input *VirtualMachineInput, instanceNamePrefix string) error {
+	for key := range input.Overrides {
+		valid := false
+		for i := 0; i < input.Count; i++ {
+			if key == strconv.Itoa(i) || key == fmt.Sprintf("%s%d", instanceNamePrefix, i) {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			return pulumierr.MissingConfigErr{key, "virtual machine instance"}
+		}
+	}
+
+	return nil
+}
+
+func validateRollingUpdate(input *VirtualMachineInput) error {
+	if input.RollingUpdate == nil {
+		return nil
+	}
+
+	if input.RollingUpdate.MaxUnavailable < 1 {
+		return pulumierr.InvalidConfigErr{input.Name, "virtual machine", "rolling update maxUnavailable must be at least 1"}
+	}
+
+	return nil
+}
+
+// rollingUpdateOptions returns the resource options of the VM and network
+// interface of the i-th instance of a VM group with a rolling update. They are
+// deleted before they are replaced, as their names are fixed, and the network
+// interface can't be attached to two VMs. They also depend on the VM and
+// backend pool associations of the instance maxUnavailable positions before.
+// Replacing the group then proceeds in batches of maxUnavailable instances,
+// where each batch waits for the previous batch to be replaced and back in its
+// backend pools.
+func rollingUpdateOptions(input *VirtualMachineInput, instances []*VMInstance, i int) []pulumi.ResourceOption {
+	if input.RollingUpdate == nil {
+		return nil
+	}
+
+	options := []pulumi.ResourceOption{pulumi.DeleteBeforeReplace(true)}
+	if dependencies := rollingUpdateDependencies(input, instances, i); len(dependencies) > 0 {
+		options = append(options, pulumi.DependsOn(dependencies))
+	}
+
+	return options
+}
+
+// rollingUpdateDependencies returns the VM and backend pool associations of
+// the instance maxUnavailable positions before the i-th instance.
+func rollingUpdateDependencies(input *VirtualMachineInput, instances []*VMInstance, i int) []pulumi.Resource {
+	predecessor := i - input.RollingUpdate.MaxUnavailable
+	if predecessor < 0 {
+		return nil
+	}
+
+	dependencies := []pulumi.Resource{instances[predecessor].virtualMachine()}
+	for _, association := range instances[predecessor].BackendAddressPoolAssociations {
+		dependencies = append(dependencies, association)
+	}
+
+	return dependencies
 }
 
 func availabilitySets(
 	ctx *pulumi.Context,
 	cfg *config.Config,
+	proximityPlacementGroups map[string]pulumi.IDOutput,
 	resourceGroup *core.ResourceGroup,
-	tags pulumi.StringMap) (map[string]pulumi.IDOutput, error) {
+	tags pulumi.StringMap) (map[string]*compute.AvailabilitySet, error) {
 
 	availabilitySetInput := []*AvailabilitySetInput{}
 	if err := cfg.TryObject("availabilitySets", &availabilitySetInput); err != nil {
 		return nil, err
 	}
 
-	availabilitySets := map[string]pulumi.IDOutput{}
+	availabilitySets := map[string]*compute.AvailabilitySet{}
 	for _, input := range availabilitySetInput {
-		availabilitySet, err := compute.NewAvailabilitySet(ctx, input.Name, &compute.AvailabilitySetArgs{
+		args := &compute.AvailabilitySetArgs{
 			Location:                  resourceGroup.Location,
 			Managed:                   pulumi.Bool(input.Managed),
 			Name:                      pulumi.String(input.Name),
@@ -169,20 +458,60 @@ func availabilitySets(
 			PlatformUpdateDomainCount: pulumi.Int(input.PlatformUpdateDomainCount),
 			ResourceGroupName:         resourceGroup.Name,
 			Tags:                      tags,
-		})
+		}
+
+		if len(input.ProximityPlacementGroup) > 0 {
+			proximityPlacementGroup, exists := proximityPlacementGroups[input.ProximityPlacementGroup]
+			if !exists {
+				return nil, pulumierr.MissingConfigErr{input.ProximityPlacementGroup, "proximity placement group"}
+			}
+			args.ProximityPlacementGroupId = proximityPlacementGroup
+		}
+
+		availabilitySet, err := compute.NewAvailabilitySet(ctx, input.Name, args)
 		if err != nil {
 			return nil, err
 		}
 
-		availabilitySets[input.Name] = availabilitySet.ID()
+		availabilitySets[input.Name] = availabilitySet
 	}
 
 	return availabilitySets, nil
 }
 
+func proximityPlacementGroups(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	resourceGroup *core.ResourceGroup,
+	tags pulumi.StringMap) (map[string]pulumi.IDOutput, error) {
+
+	proximityPlacementGroupInput := []*ProximityPlacementGroupInput{}
+	if err := cfg.GetObject("proximityPlacementGroups", &proximityPlacementGroupInput); err != nil {
+		return nil, err
+	}
+
+	proximityPlacementGroups := map[string]pulumi.IDOutput{}
+	for _, input := range proximityPlacementGroupInput {
+		proximityPlacementGroup, err := proximity.NewPlacementGroup(ctx, input.Name, &proximity.PlacementGroupArgs{
+			Location:          resourceGroup.Location,
+			Name:              pulumi.String(input.Name),
+			ResourceGroupName: resourceGroup.Name,
+			Tags:              tags,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		proximityPlacementGroups[input.Name] = proximityPlacementGroup.ID()
+	}
+
+	return proximityPlacementGroups, nil
+}
+
 func osProfiles(
 	ctx *pulumi.Context,
-	cfg *config.Config) (map[string]compute.VirtualMachineOsProfileArgs, error) {
+	cfg *config.Config,
+	credentials *credentials) (map[string]compute.VirtualMachineOsProfileArgs, error) {
 
 	osProfileInput := []*OSProfileInput{}
 	if err := cfg.TryObject("osProfiles", &osProfileInput); err != nil {
@@ -191,8 +520,17 @@ func osProfiles(
 
 	osProfiles := map[string]compute.VirtualMachineOsProfileArgs{}
 	for _, input := range osProfileInput {
+		var adminPassword pulumi.StringPtrInput = pulumi.String(input.AdminPassword)
+		if len(input.AdminPassword) == 0 {
+			generated, err := credentials.adminPassword(input.Name, input.Rotation)
+			if err != nil {
+				return nil, err
+			}
+			adminPassword = generated.ToStringPtrOutput()
+		}
+
 		osProfiles[input.Name] = compute.VirtualMachineOsProfileArgs{
-			AdminPassword: pulumi.String(input.AdminPassword),
+			AdminPassword: adminPassword,
 			AdminUsername: pulumi.String(input.AdminUsername),
 			CustomData:    pulumi.String(input.CustomData),
 		}
@@ -203,7 +541,8 @@ func osProfiles(
 
 func osProfilesLinux(
 	ctx *pulumi.Context,
-	cfg *config.Config) (map[string]compute.VirtualMachineOsProfileLinuxConfigArgs, error) {
+	cfg *config.Config,
+	credentials *credentials) (map[string]compute.VirtualMachineOsProfileLinuxConfigArgs, error) {
 
 	osProfileLinuxInput := []*OSProfileLinuxInput{}
 	if err := cfg.TryObject("osProfilesLinux", &osProfileLinuxInput); err != nil {
@@ -212,20 +551,136 @@ func osProfilesLinux(
 
 	osProfilesLinux := map[string]compute.VirtualMachineOsProfileLinuxConfigArgs{}
 	for _, input := range osProfileLinuxInput {
+		sshKeys, err := sshKeys(input)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(sshKeys) == 0 {
+			generated, err := credentials.sshPublicKey(input.Name, input.Rotation)
+			if err != nil {
+				return nil, err
+			}
+
+			sshKeys = append(sshKeys, compute.VirtualMachineOsProfileLinuxConfigSshKeyArgs{
+				KeyData: generated,
+				Path:    pulumi.String(input.SSHKeyPath),
+			})
+		}
+
 		osProfilesLinux[input.Name] = compute.VirtualMachineOsProfileLinuxConfigArgs{
 			DisablePasswordAuthentication: pulumi.Bool(input.DisablePasswordAuthentication),
-			SshKeys: compute.VirtualMachineOsProfileLinuxConfigSshKeyArray{
-				compute.VirtualMachineOsProfileLinuxConfigSshKeyArgs{
-					KeyData: pulumi.String(input.SSHKeyData),
-					Path:    pulumi.String(input.SSHKeyPath),
-				},
-			},
+			SshKeys:                       sshKeys,
 		}
 	}
 
 	return osProfilesLinux, nil
 }
 
+// sshKeys collects the SSH public keys of the Linux OS profile from its inline
+// key data, key files and authorized_keys files. Every key is validated, and
+// the same key can't be added more than once.
+func sshKeys(input *OSProfileLinuxInput) (compute.VirtualMachineOsProfileLinuxConfigSshKeyArray, error) {
+	keyInput := input.SSHKeys
+	if len(input.SSHKeyData) > 0 {
+		keyInput = append([]*SSHKeyInput{{KeyData: input.SSHKeyData}}, keyInput...)
+	}
+
+	var (
+		sshKeys      = compute.VirtualMachineOsProfileLinuxConfigSshKeyArray{}
+		fingerprints = map[string]struct{}{}
+	)
+	for _, key := range keyInput {
+		path := key.Path
+		if len(path) == 0 {
+			path = input.SSHKeyPath
+		}
+
+		publicKeys, err := loadSSHKeys(input.Name, key)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, publicKey := range publicKeys {
+			fingerprint := ssh.FingerprintSHA256(publicKey.key)
+			if _, exists := fingerprints[fingerprint]; exists {
+				return nil, pulumierr.InvalidConfigErr{input.Name, "osprofile-linux", fmt.Sprintf("duplicate SSH key %s", fingerprint)}
+			}
+			fingerprints[fingerprint] = struct{}{}
+
+			sshKeys = append(sshKeys, compute.VirtualMachineOsProfileLinuxConfigSshKeyArgs{
+				KeyData: pulumi.String(publicKey.data),
+				Path:    pulumi.String(path),
+			})
+		}
+	}
+
+	return sshKeys, nil
+}
+
+// authorizedKey is a validated SSH public key, along with its original
+// authorized keys line, so that its comment is kept on the VM.
+type authorizedKey struct {
+	data string
+	key  ssh.PublicKey
+}
+
+func loadSSHKeys(profile string, input *SSHKeyInput) ([]authorizedKey, error) {
+	sources := 0
+	for _, field := range []string{input.KeyData, input.KeyFile, input.AuthorizedKeysFile} {
+		if len(field) > 0 {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, pulumierr.InvalidConfigErr{profile, "osprofile-linux", "SSH key requires exactly one of keyData, keyFile or authorizedKeysFile"}
+	}
+
+	var (
+		data   = []byte(input.KeyData)
+		source = "inline key data"
+	)
+	for _, file := range []string{input.KeyFile, input.AuthorizedKeysFile} {
+		if len(file) == 0 {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		data, source = content, file
+	}
+
+	publicKeys := []authorizedKey{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		publicKey, _, options, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, pulumierr.InvalidConfigErr{profile, "osprofile-linux", fmt.Sprintf("malformed SSH public key in %s: %s", source, err)}
+		}
+
+		if len(options) > 0 {
+			return nil, pulumierr.InvalidConfigErr{profile, "osprofile-linux", fmt.Sprintf("SSH key options in %s aren't supported", source)}
+		}
+		publicKeys = append(publicKeys, authorizedKey{data: line, key: publicKey})
+	}
+
+	if len(publicKeys) == 0 {
+		return nil, pulumierr.InvalidConfigErr{profile, "osprofile-linux", fmt.Sprintf("no SSH public key found in %s", source)}
+	}
+
+	if len(input.AuthorizedKeysFile) == 0 && len(publicKeys) > 1 {
+		return nil, pulumierr.InvalidConfigErr{profile, "osprofile-linux", fmt.Sprintf("expected a single SSH public key in %s", source)}
+	}
+
+	return publicKeys, nil
+}
+
 func storageImageReferences(
 	ctx *pulumi.Context,
 	cfg *config.Config) (map[string]compute.VirtualMachineStorageImageReferenceArgs, error) {
@@ -259,36 +714,62 @@ func storageOSDisks(
 
 	storageOSDisks := map[string]compute.VirtualMachineStorageOsDiskArgs{}
 	for _, input := range storageOSDiskInput {
-		storageOSDisks[input.Name] = compute.VirtualMachineStorageOsDiskArgs{
+		args := compute.VirtualMachineStorageOsDiskArgs{
 			CreateOption: pulumi.String(input.CreateOption),
 			DiskSizeGb:   pulumi.Int(input.DiskSizeGB),
 			OsType:       pulumi.String(input.OSType),
 		}
+
+		if len(input.Caching) > 0 {
+			args.Caching = pulumi.String(input.Caching)
+		}
+
+		if len(input.ManagedDiskType) > 0 {
+			args.ManagedDiskType = pulumi.String(input.ManagedDiskType)
+		}
+
+		storageOSDisks[input.Name] = args
 	}
 
 	return storageOSDisks, nil
 }
 
+// primaryNetworkInterface creates the primary network interface of a VM from
+// the named network interface config, and associates it with the application
+// security group of the VM group and its own network security group, if any.
 func primaryNetworkInterface(
 	ctx *pulumi.Context,
 	cfg *config.Config,
 	appSecGroup *network.ApplicationSecurityGroup,
 	resourceGroup *core.ResourceGroup,
+	securityGroups map[string]pulumi.IDOutput,
+	networkInterface string,
 	virtualMachine pulumi.String,
-	subnetID pulumi.StringOutput,
-	tags pulumi.StringMap) (*network.NetworkInterface, error) {
+	ipConfigurationName string,
+	subnetID pulumi.IDOutput,
+	tags pulumi.StringMap,
+	opts ...pulumi.ResourceOption) (*network.NetworkInterface, string, error) {
 
 	networkInterfaceInput := []*NetworkInterfaceInput{}
 	if err := cfg.TryObject("networkInterfaces", &networkInterfaceInput); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	ipConfigurationInput := []*IPConfigurationInput{}
 	if err := cfg.TryObject("ipConfiguration", &ipConfigurationInput); err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	ipConfigurations := map[string]*IPConfigurationInput{}
+	for _, input := range ipConfigurationInput {
+		ipConfigurations[input.Name] = input
 	}
 
 	for _, infInput := range networkInterfaceInput {
+		if len(networkInterface) > 0 && infInput.Name != networkInterface {
+			continue
+		}
+
 		var ipConfigs network.NetworkInterfaceIpConfigurationArray
 
 		for _, ipConfigInput := range ipConfigurationInput {
@@ -298,7 +779,7 @@ func primaryNetworkInterface(
 			}
 
 			ipConfigs = append(ipConfigs, network.NetworkInterfaceIpConfigurationArgs{
-				Name:                       pulumi.Sprintf("%s-primary-ipconfig", virtualMachine),
+				Name:                       pulumi.String(ipConfigurationName),
 				Primary:                    pulumi.Bool(ipConfigInput.Primary),
 				PrivateIpAddressAllocation: pulumi.String(ipConfigInput.PrivateIPAddressAllocation),
 				PrivateIpAddressVersion:    pulumi.String(ipConfigInput.PrivateIPAddressVersion),
@@ -306,6 +787,27 @@ func primaryNetworkInterface(
 			})
 		}
 
+		var ipv6ConfigurationName string
+		for _, name := range infInput.IPConfigurations {
+			ipConfigInput, exists := ipConfigurations[name]
+			if !exists {
+				return nil, "", pulumierr.MissingConfigErr{name, "IP configuration"}
+			}
+
+			secondaryName := fmt.Sprintf("%s-%s", virtualMachine, name)
+			if ipConfigInput.PrivateIPAddressVersion == "IPv6" {
+				ipv6ConfigurationName = secondaryName
+			}
+
+			ipConfigs = append(ipConfigs, network.NetworkInterfaceIpConfigurationArgs{
+				Name:                       pulumi.String(secondaryName),
+				Primary:                    pulumi.Bool(false),
+				PrivateIpAddressAllocation: pulumi.String(ipConfigInput.PrivateIPAddressAllocation),
+				PrivateIpAddressVersion:    pulumi.String(ipConfigInput.PrivateIPAddressVersion),
+				SubnetId:                   subnetID,
+			})
+		}
+
 		args := &network.NetworkInterfaceArgs{
 			IpConfigurations:  ipConfigs,
 			Location:          resourceGroup.Location,
@@ -314,9 +816,9 @@ func primaryNetworkInterface(
 		}
 
 		netInfName := fmt.Sprintf("%s-primary", virtualMachine)
-		netInf, err := network.NewNetworkInterface(ctx, netInfName, args)
+		netInf, err := network.NewNetworkInterface(ctx, netInfName, args, opts...)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		if _, err := network.NewNetworkInterfaceApplicationSecurityGroupAssociation(ctx, netInfName,
@@ -324,11 +826,87 @@ func primaryNetworkInterface(
 				ApplicationSecurityGroupId: appSecGroup.ID(),
 				NetworkInterfaceId:         netInf.ID(),
 			}); err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
-		return netInf, nil
+		if len(infInput.SecurityGroup) > 0 {
+			securityGroupID, exists := securityGroups[infInput.SecurityGroup]
+			if !exists {
+				return nil, "", pulumierr.MissingConfigErr{infInput.SecurityGroup, "network security group"}
+			}
+
+			if _, err := network.NewNetworkInterfaceSecurityGroupAssociation(ctx, netInfName,
+				&network.NetworkInterfaceSecurityGroupAssociationArgs{
+					NetworkInterfaceId:     netInf.ID(),
+					NetworkSecurityGroupId: securityGroupID,
+				}); err != nil {
+				return nil, "", err
+			}
+		}
+
+		return netInf, ipv6ConfigurationName, nil
+	}
+
+	return nil, "", pulumierr.MissingConfigErr{string(virtualMachine), "primary network interface"}
+}
+
+// validateSecurityGroups warns about the rules of the network security group
+// of a network interface that contradict the ones of its subnet. Each pair of
+// network interface and subnet is only checked once.
+func validateSecurityGroups(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	appSecGroups map[string]*network.ApplicationSecurityGroup,
+	networkInterface string,
+	subnet string,
+	checked map[string]bool) error {
+
+	key := networkInterface + "/" + subnet
+	if checked[key] {
+		return nil
+	}
+	checked[key] = true
+
+	networkInterfaceInput := []*NetworkInterfaceInput{}
+	if err := cfg.TryObject("networkInterfaces", &networkInterfaceInput); err != nil {
+		return err
+	}
+
+	var networkInterfaceSecurityGroup string
+	for _, input := range networkInterfaceInput {
+		if len(networkInterface) == 0 || input.Name == networkInterface {
+			networkInterfaceSecurityGroup = input.SecurityGroup
+			break
+		}
+	}
+
+	subnetInput := []*vnet.SubnetInput{}
+	if err := cfg.TryObject("subnets", &subnetInput); err != nil {
+		return err
+	}
+
+	var subnetSecurityGroup string
+	for _, input := range subnetInput {
+		if input.Name == subnet {
+			subnetSecurityGroup = input.SecurityGroup
+			break
+		}
+	}
+
+	if len(networkInterfaceSecurityGroup) == 0 || len(subnetSecurityGroup) == 0 {
+		return nil
+	}
+
+	conflicts, err := vnet.SecurityGroupConflicts(cfg, appSecGroups, subnetSecurityGroup, networkInterfaceSecurityGroup)
+	if err != nil {
+		return err
+	}
+
+	for _, conflict := range conflicts {
+		if err := ctx.Log.Warn(fmt.Sprintf("network interface %s in subnet %s: %s", networkInterface, subnet, conflict), nil); err != nil {
+			return err
+		}
 	}
 
-	return nil, pulumierr.MissingConfigErr{string(virtualMachine), "primary network interface"}
+	return nil
 }
@@ -1,17 +1,54 @@
 package compute
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"sync"
 	"testing"
 
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/loadbalancer"
 	"github.com/ihcsim/pulumi-azure/v2/pkg/mock"
 	"github.com/ihcsim/pulumi-azure/v2/pkg/test"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/compute"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/lb"
+	"github.com/pulumi/pulumi/sdk/go/common/resource"
 	"github.com/pulumi/pulumi/sdk/go/pulumi"
 	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+	"golang.org/x/crypto/ssh"
 )
 
+// protectedVMMocks records the inputs of the backup protected VMs, keyed by
+// their logical names.
+type protectedVMMocks struct {
+	mock.Mocks
+	sync.Mutex
+	protectedVMs map[string]resource.PropertyMap
+}
+
+func (m *protectedVMMocks) NewResource(
+	typeToken, name string,
+	inputs resource.PropertyMap,
+	provider, id string) (string, resource.PropertyMap, error) {
+
+	if typeToken == "azure:backup/protectedVM:ProtectedVM" {
+		m.Lock()
+		m.protectedVMs[name] = inputs
+		m.Unlock()
+	}
+
+	return m.Mocks.NewResource(typeToken, name, inputs, provider, id)
+}
+
 func TestReconcile(t *testing.T) {
+	var (
+		mocks         = &protectedVMMocks{protectedVMs: map[string]resource.PropertyMap{}}
+		instanceNames []string
+	)
 	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
 		cfg := config.New(ctx, test.ConfigNamespace)
 
@@ -25,21 +62,58 @@ func TestReconcile(t *testing.T) {
 			return err
 		}
 
+		backupPolicies, err := test.MockBackupPolicies(ctx)
+		if err != nil {
+			return err
+		}
+
+		subnets, err := test.MockSubnets(ctx)
+		if err != nil {
+			return err
+		}
+
 		virtualNetworks, err := test.MockVirtualNetworks(ctx)
 		if err != nil {
 			return err
 		}
 
-		virtualMachines, err := Reconcile(ctx, cfg, appSecGroups, resourceGroup, virtualNetworks, test.Tags)
+		securityGroups, err := test.MockNetworkSecurityGroups(ctx)
 		if err != nil {
 			return err
 		}
 
-		virtualMachine, exists := virtualMachines[test.VirtualMachineInstanceName]
+		vmGroups, err := Reconcile(ctx, cfg, appSecGroups, backupPolicies, nil, resourceGroup, securityGroups, subnets, virtualNetworks, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		vmGroup, exists := vmGroups[test.VirtualMachineName]
 		if !exists {
-			return fmt.Errorf("missing virtual machine: %s", test.VirtualMachineName)
+			return fmt.Errorf("missing virtual machine group: %s", test.VirtualMachineName)
+		}
+
+		if actual := len(vmGroup.Instances); actual != 3 {
+			return fmt.Errorf("mismatch number of instances. expected: 3, actual: %d", actual)
+		}
+
+		for _, instance := range vmGroup.Instances {
+			instanceNames = append(instanceNames, instance.Name)
+		}
+
+		instance := vmGroup.Instances[0]
+		if instance.Name != test.VirtualMachineInstanceName {
+			return fmt.Errorf("mismatch instance name. expected: %s, actual: %s", test.VirtualMachineInstanceName, instance.Name)
 		}
 
+		if expected := test.VirtualMachineInstanceName + "-primary-ipconfig"; instance.IPConfigurationName != expected {
+			return fmt.Errorf("mismatch IP configuration name. expected: %s, actual: %s", expected, instance.IPConfigurationName)
+		}
+
+		if expected := test.VirtualMachineInstanceName + "-" + test.IPConfigurationIPv6Name; instance.IPv6ConfigurationName != expected {
+			return fmt.Errorf("mismatch IPv6 configuration name. expected: %s, actual: %s", expected, instance.IPv6ConfigurationName)
+		}
+		virtualMachine := instance.VirtualMachine
+
 		var wg sync.WaitGroup
 		wg.Add(1)
 		pulumi.All(
@@ -52,7 +126,8 @@ func TestReconcile(t *testing.T) {
 			virtualMachine.ResourceGroupName,
 			virtualMachine.StorageImageReference,
 			virtualMachine.StorageOsDisk,
-			virtualMachine.VmSize).ApplyT(func(actuals []interface{}) error {
+			virtualMachine.VmSize,
+			virtualMachine.ProximityPlacementGroupId).ApplyT(func(actuals []interface{}) error {
 
 			defer wg.Done()
 
@@ -80,12 +155,798 @@ func TestReconcile(t *testing.T) {
 				t.Errorf("mismatch vm size. expected: %s, actual: %s", test.VirtualMachineSize, actual)
 			}
 
+			if actual := actuals[10].(*string); actual == nil || *actual != test.ProximityPlacementGroupName+"_id" {
+				t.Errorf("mismatch proximity placement group. expected: %s, actual: %v", test.ProximityPlacementGroupName+"_id", actual)
+			}
+
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mocks)); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := len(mocks.protectedVMs); actual != len(instanceNames) {
+		t.Errorf("mismatch number of protected VMs. expected: %d, actual: %d", len(instanceNames), actual)
+	}
+
+	for _, name := range instanceNames {
+		protectedVM, exists := mocks.protectedVMs[name]
+		if !exists {
+			t.Errorf("missing protected VM: %s", name)
+			continue
+		}
+
+		if expected, actual := test.BackupPolicyName+"_id", protectedVM["backupPolicyId"].StringValue(); actual != expected {
+			t.Errorf("mismatch backup policy. expected: %s, actual: %s", expected, actual)
+		}
+
+		if actual := protectedVM["recoveryVaultName"].StringValue(); actual != test.RecoveryServicesVaultName {
+			t.Errorf("mismatch recovery services vault. expected: %s, actual: %s", test.RecoveryServicesVaultName, actual)
+		}
+
+		if expected, actual := name+"_id", protectedVM["sourceVmId"].StringValue(); actual != expected {
+			t.Errorf("mismatch source VM. expected: %s, actual: %s", expected, actual)
+		}
+	}
+}
+
+func TestReconcileWithOverrides(t *testing.T) {
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		appSecGroups, err := test.MockApplicationSecurityGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		backupPolicies, err := test.MockBackupPolicies(ctx)
+		if err != nil {
+			return err
+		}
+
+		subnets, err := test.MockSubnets(ctx)
+		if err != nil {
+			return err
+		}
+
+		virtualNetworks, err := test.MockVirtualNetworks(ctx)
+		if err != nil {
+			return err
+		}
+
+		securityGroups, err := test.MockNetworkSecurityGroups(ctx)
+		if err != nil {
+			return err
+		}
+
+		vmGroups, err := Reconcile(ctx, cfg, appSecGroups, backupPolicies, nil, resourceGroup, securityGroups, subnets, virtualNetworks, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		vmGroup, exists := vmGroups[test.VirtualMachineName]
+		if !exists {
+			return fmt.Errorf("missing virtual machine group: %s", test.VirtualMachineName)
+		}
+
+		virtualMachine := vmGroup.Instances[1].VirtualMachine
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		pulumi.All(virtualMachine.Tags, virtualMachine.VmSize).ApplyT(func(actuals []interface{}) error {
+			defer wg.Done()
+
+			tags := actuals[0].(map[string]string)
+			if actual := tags["debug"]; actual != "true" {
+				t.Errorf("mismatch override tag. expected: true, actual: %s", actual)
+			}
+
+			if actual := tags["key"]; actual != "value" {
+				t.Errorf("mismatch common tag. expected: value, actual: %s", actual)
+			}
+
+			if actual := actuals[1].(string); actual != test.VirtualMachineOverrideSize {
+				t.Errorf("mismatch vm size. expected: %s, actual: %s", test.VirtualMachineOverrideSize, actual)
+			}
+
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReconcileDedicatedHost(t *testing.T) {
+	// place the test VM group onto the test dedicated host, instead of its
+	// availability set
+	dedicatedHostConfig := map[string]string{}
+	for key, value := range test.Config {
+		dedicatedHostConfig[key] = value
+	}
+
+	virtualMachinesKey := fmt.Sprintf("%s:virtualMachines", test.ConfigNamespace)
+	virtualMachines := []map[string]interface{}{}
+	if err := json.Unmarshal([]byte(test.Config[virtualMachinesKey]), &virtualMachines); err != nil {
+		t.Fatal(err)
+	}
+	delete(virtualMachines[0], "availabilitySet")
+	virtualMachines[0]["dedicatedHost"] = test.DedicatedHostName
+
+	data, err := json.Marshal(virtualMachines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dedicatedHostConfig[virtualMachinesKey] = string(data)
+
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		appSecGroups, err := test.MockApplicationSecurityGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		backupPolicies, err := test.MockBackupPolicies(ctx)
+		if err != nil {
+			return err
+		}
+
+		subnets, err := test.MockSubnets(ctx)
+		if err != nil {
+			return err
+		}
+
+		virtualNetworks, err := test.MockVirtualNetworks(ctx)
+		if err != nil {
+			return err
+		}
+
+		securityGroups, err := test.MockNetworkSecurityGroups(ctx)
+		if err != nil {
+			return err
+		}
+
+		vmGroups, err := Reconcile(ctx, cfg, appSecGroups, backupPolicies, nil, resourceGroup, securityGroups, subnets, virtualNetworks, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		vmGroup, exists := vmGroups[test.VirtualMachineName]
+		if !exists {
+			return fmt.Errorf("missing virtual machine group: %s", test.VirtualMachineName)
+		}
+
+		if vmGroup.AvailabilitySet != nil {
+			t.Errorf("expected VM group %s on a dedicated host to have no availability set", test.VirtualMachineName)
+		}
+
+		instance := vmGroup.Instances[0]
+		if instance.VirtualMachine != nil || instance.LinuxVirtualMachine == nil {
+			return fmt.Errorf("expected instance %s to be a Linux virtual machine", instance.Name)
+		}
+		virtualMachine := instance.LinuxVirtualMachine
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		pulumi.All(
+			virtualMachine.AdminSshKeys,
+			virtualMachine.CustomData,
+			virtualMachine.DedicatedHostId,
+			virtualMachine.OsDisk,
+			virtualMachine.Zone).ApplyT(func(actuals []interface{}) error {
+			defer wg.Done()
+
+			adminSSHKeys := actuals[0].([]compute.LinuxVirtualMachineAdminSshKey)
+			expectedKeys := []string{test.OSProfileLinuxSSHKeyData, test.OSProfileLinuxSSHKeyDataAlt}
+			if len(adminSSHKeys) != len(expectedKeys) {
+				t.Fatalf("mismatch number of admin SSH keys. expected: %d, actual: %d", len(expectedKeys), len(adminSSHKeys))
+			}
+
+			for i, expected := range expectedKeys {
+				if actual := adminSSHKeys[i]; actual.PublicKey != expected || actual.Username != test.OSProfileAdminUsername {
+					t.Errorf("mismatch admin SSH key. expected: %s@%s, actual: %s@%s", test.OSProfileAdminUsername, expected, actual.Username, actual.PublicKey)
+				}
+			}
+
+			customData, err := base64.StdEncoding.DecodeString(*actuals[1].(*string))
+			if err != nil {
+				t.Errorf("expected base64-encoded custom data: %s", err)
+			}
+
+			if expected := fmt.Sprintf("%s\n%s", test.OSProfileCustomData, test.VirtualMachineCustomData); string(customData) != expected {
+				t.Errorf("mismatch custom data. expected: %q, actual: %q", expected, customData)
+			}
+
+			if actual := actuals[2].(*string); actual == nil || *actual != test.DedicatedHostName+"_id" {
+				t.Errorf("mismatch dedicated host. expected: %s, actual: %v", test.DedicatedHostName+"_id", actual)
+			}
+
+			if actual := actuals[3].(compute.LinuxVirtualMachineOsDisk); actual.Caching != defaultOSDiskCaching || actual.StorageAccountType != defaultOSDiskStorageAccountType {
+				t.Errorf("mismatch OS disk. expected: %s %s, actual: %s %s", defaultOSDiskCaching, defaultOSDiskStorageAccountType, actual.Caching, actual.StorageAccountType)
+			}
+
+			if actual := actuals[4].(*string); actual == nil || *actual != test.DedicatedHostGroupZone {
+				t.Errorf("mismatch zone. expected: %s, actual: %v", test.DedicatedHostGroupZone, actual)
+			}
+
 			return nil
 		})
 
+		wg.Wait()
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, dedicatedHostConfig, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateDedicatedHost(t *testing.T) {
+	hostGroups := map[string]*DedicatedHostGroupInput{
+		test.DedicatedHostGroupName: {Name: test.DedicatedHostGroupName, PlatformFaultDomainCount: 2},
+	}
+
+	var testCases = []struct {
+		name      string
+		input     *DedicatedHostInput
+		expectErr bool
+	}{
+		{
+			name:  "valid fault domain",
+			input: &DedicatedHostInput{HostGroup: test.DedicatedHostGroupName, Name: test.DedicatedHostName, PlatformFaultDomain: 1},
+		},
+		{
+			name:      "fault domain out of range",
+			input:     &DedicatedHostInput{HostGroup: test.DedicatedHostGroupName, Name: test.DedicatedHostName, PlatformFaultDomain: 2},
+			expectErr: true,
+		},
+		{
+			name:      "missing host group",
+			input:     &DedicatedHostInput{HostGroup: "missing", Name: test.DedicatedHostName},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateDedicatedHost(tc.input, hostGroups); (err != nil) != tc.expectErr {
+				t.Errorf("mismatch error. expected error: %t, actual: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateDedicatedHostPlacement(t *testing.T) {
+	dedicatedHosts := map[string]*dedicatedHost{
+		test.DedicatedHostName: {zone: test.DedicatedHostGroupZone},
+	}
+
+	var testCases = []struct {
+		name      string
+		input     *VirtualMachineInput
+		expectErr bool
+	}{
+		{
+			name:  "dedicated host",
+			input: &VirtualMachineInput{DedicatedHost: test.DedicatedHostName, Name: test.VirtualMachineName},
+		},
+		{
+			name:      "dedicated host and availability set",
+			input:     &VirtualMachineInput{AvailabilitySet: test.AvailabilitySetName, DedicatedHost: test.DedicatedHostName, Name: test.VirtualMachineName},
+			expectErr: true,
+		},
+		{
+			name:      "missing dedicated host",
+			input:     &VirtualMachineInput{DedicatedHost: "missing", Name: test.VirtualMachineName},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateDedicatedHostPlacement(tc.input, dedicatedHosts); (err != nil) != tc.expectErr {
+				t.Errorf("mismatch error. expected error: %t, actual: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestSSHKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssh-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		keyFile            = filepath.Join(dir, "id_ed25519.pub")
+		authorizedKeysFile = filepath.Join(dir, "authorized_keys")
+	)
+	if err := ioutil.WriteFile(keyFile, []byte(test.OSProfileLinuxSSHKeyDataAlt+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	authorizedKeys := "# operators\n" + test.OSProfileLinuxSSHKeyData + "\n\n" + test.OSProfileLinuxSSHKeyDataAlt + "\n"
+	if err := ioutil.WriteFile(authorizedKeysFile, []byte(authorizedKeys), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		name            string
+		input           *OSProfileLinuxInput
+		expectedLen     int
+		expectedKeyData []string
+		expectErr       bool
+	}{
+		{
+			name: "inline key data",
+			input: &OSProfileLinuxInput{
+				SSHKeyData: test.OSProfileLinuxSSHKeyData,
+				SSHKeyPath: test.OSProfileLinuxSSHKeyPath,
+			},
+			expectedLen:     1,
+			expectedKeyData: []string{test.OSProfileLinuxSSHKeyData},
+		},
+		{
+			name: "inline key data and key file",
+			input: &OSProfileLinuxInput{
+				SSHKeyData: test.OSProfileLinuxSSHKeyData,
+				SSHKeyPath: test.OSProfileLinuxSSHKeyPath,
+				SSHKeys:    []*SSHKeyInput{{KeyFile: keyFile}},
+			},
+			expectedLen: 2,
+		},
+		{
+			name: "authorized keys file",
+			input: &OSProfileLinuxInput{
+				SSHKeyPath: test.OSProfileLinuxSSHKeyPath,
+				SSHKeys:    []*SSHKeyInput{{AuthorizedKeysFile: authorizedKeysFile}},
+			},
+			expectedLen:     2,
+			expectedKeyData: []string{test.OSProfileLinuxSSHKeyData, test.OSProfileLinuxSSHKeyDataAlt},
+		},
+		{
+			name: "duplicate keys",
+			input: &OSProfileLinuxInput{
+				SSHKeyData: test.OSProfileLinuxSSHKeyData,
+				SSHKeyPath: test.OSProfileLinuxSSHKeyPath,
+				SSHKeys:    []*SSHKeyInput{{AuthorizedKeysFile: authorizedKeysFile}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "malformed key",
+			input: &OSProfileLinuxInput{
+				SSHKeys: []*SSHKeyInput{{KeyData: "ssh-rsa not-a-key"}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "key options",
+			input: &OSProfileLinuxInput{
+				SSHKeys: []*SSHKeyInput{{KeyData: `no-pty ` + test.OSProfileLinuxSSHKeyData}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "multiple sources",
+			input: &OSProfileLinuxInput{
+				SSHKeys: []*SSHKeyInput{{KeyData: test.OSProfileLinuxSSHKeyData, KeyFile: keyFile}},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sshKeys, err := sshKeys(tc.input)
+			if tc.expectErr {
+				if err == nil {
+					t.Error("expected error didn't occur")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := len(sshKeys); actual != tc.expectedLen {
+				t.Errorf("mismatch number of SSH keys. expected: %d, actual: %d", tc.expectedLen, actual)
+			}
+
+			for i, expected := range tc.expectedKeyData {
+				keyData := sshKeys[i].(compute.VirtualMachineOsProfileLinuxConfigSshKeyArgs).KeyData
+				if actual := string(keyData.(pulumi.String)); actual != expected {
+					t.Errorf("mismatch SSH key data. expected: %s, actual: %s", expected, actual)
+				}
+			}
+		})
+	}
+}
+
+// previousCredentialsMocks mocks a stack whose previous update generated the
+// admin password of the test OS profile with a rotation counter of 0.
+type previousCredentialsMocks struct {
+	mock.Mocks
+}
+
+func (m previousCredentialsMocks) NewResource(
+	typeToken, name string,
+	inputs resource.PropertyMap,
+	provider, id string) (string, resource.PropertyMap, error) {
+
+	if typeToken == "pulumi:pulumi:StackReference" {
+		expected := fmt.Sprintf("%s/%s/%s", test.Organization, test.Project, test.Stack)
+		if actual := inputs["name"].StringValue(); actual != expected {
+			return "", nil, fmt.Errorf("mismatch stack reference name. expected: %s, actual: %s", expected, actual)
+		}
+
+		inputs["outputs"] = resource.NewPropertyValue(map[string]interface{}{
+			generatedAdminPasswordsOutput: map[string]interface{}{
+				test.OSProfileGeneratedName: map[string]interface{}{
+					"adminPassword": test.OSProfileAdminPassword,
+					"rotation":      "0",
+				},
+			},
+		})
+		return name + "_id", inputs, nil
+	}
+
+	return m.Mocks.NewResource(typeToken, name, inputs, provider, id)
+}
+
+func TestGeneratedCredentials(t *testing.T) {
+	var testCases = []struct {
+		name             string
+		mocks            pulumi.MockResourceMonitor
+		expectedPassword string
+	}{
+		{name: "new stack", mocks: mock.Mocks(0)},
+		{name: "previous update", mocks: previousCredentialsMocks{}, expectedPassword: test.OSProfileAdminPassword},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				var (
+					cfg         = config.New(ctx, test.ConfigNamespace)
+					credentials = newCredentials(ctx, cfg)
+				)
+
+				osProfiles, err := osProfiles(ctx, cfg, credentials)
+				if err != nil {
+					return err
+				}
+
+				osProfilesLinux, err := osProfilesLinux(ctx, cfg, credentials)
+				if err != nil {
+					return err
+				}
+
+				osProfile, exists := osProfiles[test.OSProfileGeneratedName]
+				if !exists {
+					return fmt.Errorf("missing osprofile: %s", test.OSProfileGeneratedName)
+				}
+
+				osProfileLinux, exists := osProfilesLinux[test.OSProfileLinuxGeneratedName]
+				if !exists {
+					return fmt.Errorf("missing osprofile-linux: %s", test.OSProfileLinuxGeneratedName)
+				}
+
+				if actual := len(osProfileLinux.SshKeys.(compute.VirtualMachineOsProfileLinuxConfigSshKeyArray)); actual != 1 {
+					return fmt.Errorf("mismatch number of SSH keys. expected: 1, actual: %d", actual)
+				}
+				sshKey := osProfileLinux.SshKeys.(compute.VirtualMachineOsProfileLinuxConfigSshKeyArray)[0].(compute.VirtualMachineOsProfileLinuxConfigSshKeyArgs)
+
+				var wg sync.WaitGroup
+				wg.Add(1)
+				pulumi.All(osProfile.AdminPassword, sshKey.KeyData).ApplyT(func(actuals []interface{}) error {
+					defer wg.Done()
+
+					password := *actuals[0].(*string)
+					if len(tc.expectedPassword) > 0 && password != tc.expectedPassword {
+						t.Errorf("mismatch admin password. expected: %s, actual: %s", tc.expectedPassword, password)
+					}
+
+					if actual := len(password); len(tc.expectedPassword) == 0 && actual != passwordLength {
+						t.Errorf("mismatch admin password length. expected: %d, actual: %d", passwordLength, actual)
+					}
+
+					if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(actuals[1].(string))); err != nil {
+						t.Errorf("malformed generated SSH public key: %s", err)
+					}
+
+					return nil
+				})
+
+				wg.Wait()
+				return nil
+			}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, tc.mocks)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPreviousCredential(t *testing.T) {
+	const profile = "test-profile"
+
+	var testCases = []struct {
+		name      string
+		outputs   map[string]interface{}
+		rotation  int
+		expected  string
+		expectErr bool
+	}{
+		{name: "new stack", outputs: map[string]interface{}{}},
+		{
+			name: "previous update",
+			outputs: map[string]interface{}{
+				generatedAdminPasswordsOutput: map[string]interface{}{
+					profile: map[string]interface{}{"adminPassword": "previous", "rotation": "1"},
+				},
+				generatedCredentialsOutput: map[string]interface{}{
+					generatedAdminPasswordsOutput: map[string]interface{}{profile: "1"},
+				},
+			},
+			rotation: 1,
+			expected: "previous",
+		},
+		{
+			name: "rotated",
+			outputs: map[string]interface{}{
+				generatedAdminPasswordsOutput: map[string]interface{}{
+					profile: map[string]interface{}{"adminPassword": "previous", "rotation": "0"},
+				},
+				generatedCredentialsOutput: map[string]interface{}{
+					generatedAdminPasswordsOutput: map[string]interface{}{profile: "0"},
+				},
+			},
+			rotation: 1,
+		},
+		{
+			name: "unreadable",
+			outputs: map[string]interface{}{
+				generatedCredentialsOutput: map[string]interface{}{
+					generatedAdminPasswordsOutput: map[string]interface{}{profile: "0"},
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			credential, found, err := previousCredential(tc.outputs, generatedAdminPasswordsOutput, profile, tc.rotation)
+			if tc.expectErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if expected := len(tc.expected) > 0; found != expected {
+				t.Errorf("mismatch found. expected: %t, actual: %t", expected, found)
+			}
+
+			if actual := credential["adminPassword"]; actual != tc.expected {
+				t.Errorf("mismatch admin password. expected: %s, actual: %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAutoShutdownParameters(t *testing.T) {
+	input := &AutoShutdownInput{
+		Name:              test.AutoShutdownName,
+		NotificationEmail: "ops@example.com",
+		Time:              test.AutoShutdownTime,
+		Timezone:          test.AutoShutdownTimezone,
+	}
+
+	var testCases = []struct {
+		enabled        bool
+		expectedStatus string
+	}{
+		{enabled: true, expectedStatus: "Enabled"},
+		{enabled: false, expectedStatus: "Disabled"},
+	}
+
+	for _, tc := range testCases {
+		body, err := autoShutdownParameters(input, tc.enabled, test.VirtualMachineInstanceName, test.VirtualMachineInstanceName+"_id", test.Location, map[string]string{"key": "value"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parameters := map[string]struct {
+			Value interface{} `json:"value"`
+		}{}
+		if err := json.Unmarshal([]byte(body), &parameters); err != nil {
+			t.Fatal(err)
+		}
+
+		var expected = map[string]interface{}{
+			"notificationStatus":        "Enabled",
+			"notificationTimeInMinutes": float64(defaultNotificationTimeInMinutes),
+			"status":                    tc.expectedStatus,
+			"time":                      test.AutoShutdownTime,
+			"timeZoneId":                test.AutoShutdownTimezone,
+			"virtualMachineId":          test.VirtualMachineInstanceName + "_id",
+			"virtualMachineName":        test.VirtualMachineInstanceName,
+		}
+		for name, value := range expected {
+			if actual := parameters[name].Value; actual != value {
+				t.Errorf("mismatch parameter %s. expected: %v, actual: %v", name, value, actual)
+			}
+		}
+	}
+}
+
+func TestRollingUpdateOptions(t *testing.T) {
+	const loadBalancerName = "test-load-balancer"
+
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		appSecGroups, err := test.MockApplicationSecurityGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		backupPolicies, err := test.MockBackupPolicies(ctx)
+		if err != nil {
+			return err
+		}
+
+		subnets, err := test.MockSubnets(ctx)
+		if err != nil {
+			return err
+		}
+
+		virtualNetworks, err := test.MockVirtualNetworks(ctx)
+		if err != nil {
+			return err
+		}
+
+		securityGroups, err := test.MockNetworkSecurityGroups(ctx)
+		if err != nil {
+			return err
+		}
+
+		loadBalancer, err := lb.NewLoadBalancer(ctx, loadBalancerName, &lb.LoadBalancerArgs{
+			Name:              pulumi.String(loadBalancerName),
+			ResourceGroupName: resourceGroup.Name,
+		})
+		if err != nil {
+			return err
+		}
+
+		backendAddressPool, err := lb.NewBackendAddressPool(ctx, loadBalancerName, &lb.BackendAddressPoolArgs{
+			LoadbalancerId:    loadBalancer.ID(),
+			ResourceGroupName: resourceGroup.Name,
+		})
+		if err != nil {
+			return err
+		}
+
+		loadBalancers := map[string]*loadbalancer.LoadBalancer{
+			loadBalancerName: {
+				BackendAddressPool: backendAddressPool,
+				BackendHosts:       []string{test.VirtualMachineName},
+				LoadBalancer:       loadBalancer,
+				Name:               loadBalancerName,
+			},
+		}
+
+		vmGroups, err := Reconcile(ctx, cfg, appSecGroups, backupPolicies, loadBalancers, resourceGroup, securityGroups, subnets, virtualNetworks, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		vmGroup, exists := vmGroups[test.VirtualMachineName]
+		if !exists {
+			return fmt.Errorf("missing virtual machine group: %s", test.VirtualMachineName)
+		}
+
+		input := &VirtualMachineInput{RollingUpdate: &RollingUpdateInput{MaxUnavailable: 1}}
+		if actual := rollingUpdateDependencies(input, vmGroup.Instances, 0); len(actual) != 0 {
+			t.Errorf("mismatch dependencies of the first instance. expected: none, actual: %d", len(actual))
+		}
+
+		var wg sync.WaitGroup
+		for i := 1; i < len(vmGroup.Instances); i++ {
+			var (
+				predecessor = vmGroup.Instances[i-1].Name
+				expected    = []string{predecessor, fmt.Sprintf("%s-%s-association", loadBalancerName, predecessor)}
+				urns        = []interface{}{}
+			)
+			for _, dependency := range rollingUpdateDependencies(input, vmGroup.Instances, i) {
+				urns = append(urns, dependency.URN())
+			}
+
+			wg.Add(1)
+			pulumi.All(urns...).ApplyT(func(actuals []interface{}) error {
+				defer wg.Done()
+
+				names := []string{}
+				for _, actual := range actuals {
+					names = append(names, resource.URN(actual.(pulumi.URN)).Name().String())
+				}
+
+				if !reflect.DeepEqual(names, expected) {
+					t.Errorf("mismatch dependencies. expected: %v, actual: %v", expected, names)
+				}
+
+				return nil
+			})
+		}
+
 		wg.Wait()
 		return nil
 	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
 		t.Error(err)
 	}
+
+	input := &VirtualMachineInput{
+		Count:         5,
+		Name:          test.VirtualMachineName,
+		RollingUpdate: &RollingUpdateInput{MaxUnavailable: 0},
+	}
+	if err := validateRollingUpdate(input); err == nil {
+		t.Error("expected error didn't occur")
+	}
+
+	input.RollingUpdate = nil
+	if actual := rollingUpdateOptions(input, nil, 1); len(actual) != 0 {
+		t.Errorf("mismatch number of options without rolling update. expected: 0, actual: %d", len(actual))
+	}
+}
+
+func TestInstanceSubnets(t *testing.T) {
+	var testCases = []struct {
+		input    *VirtualMachineInput
+		expected []string
+	}{
+		{
+			input:    &VirtualMachineInput{Count: 2, Name: "web"},
+			expected: []string{"subnet-00", "subnet-01"},
+		},
+		{
+			input: &VirtualMachineInput{Count: 3, Name: "web", Overrides: map[string]*VirtualMachineOverrideInput{
+				"1":      {Subnet: "subnet-app"},
+				"web-02": {Subnet: "subnet-db"},
+			}},
+			expected: []string{"subnet-00", "subnet-app", "subnet-db"},
+		},
+	}
+
+	for _, tc := range testCases {
+		if actual := InstanceSubnets(tc.input); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("mismatch subnets. expected: %v, actual: %v", tc.expected, actual)
+		}
+	}
 }
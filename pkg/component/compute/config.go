@@ -1,10 +1,42 @@
 package compute
 
+// AutoShutdownInput describes a daily auto-shutdown schedule of VMs. Time is in
+// the HHMM format, and Timezone is a Windows time zone ID, e.g. "Pacific
+// Standard Time". A notification is sent NotificationMinutes before shutdown,
+// if an email or a webhook URL is provided. Scheduled start isn't supported,
+// as DevTest schedules only shut VMs down.
+type AutoShutdownInput struct {
+	Name                   string
+	NotificationEmail      string
+	NotificationMinutes    int
+	NotificationWebhookURL string `json:"notificationWebhookURL"`
+	Time                   string
+	Timezone               string
+}
+
 type AvailabilitySetInput struct {
 	Managed                   bool
 	Name                      string
 	PlatformFaultDomainCount  int
 	PlatformUpdateDomainCount int
+	ProximityPlacementGroup   string
+}
+
+// DedicatedHostGroupInput describes a group of dedicated hosts.
+type DedicatedHostGroupInput struct {
+	Name                     string
+	PlatformFaultDomainCount int
+	Zone                     string
+}
+
+// DedicatedHostInput describes a dedicated host in a dedicated host group.
+type DedicatedHostInput struct {
+	AutoReplaceOnFailure bool
+	HostGroup            string
+	LicenseType          string
+	Name                 string
+	PlatformFaultDomain  int
+	SKU                  string `json:"sku"`
 }
 
 type IPConfigurationInput struct {
@@ -14,23 +46,60 @@ type IPConfigurationInput struct {
 	PrivateIPAddressVersion    string
 }
 
+// NetworkInterfaceInput describes a network interface. IPConfiguration is its
+// primary IP configuration, and IPConfigurations are secondary ones. A
+// secondary IPv6 configuration makes the network interface dual-stack. The
+// optional SecurityGroup is a network security group associated with the
+// network interface, for host-specific rules on top of the ones of its subnet.
 type NetworkInterfaceInput struct {
-	IPConfiguration string `json:"ipConfiguration"`
-	Name            string
+	IPConfiguration  string   `json:"ipConfiguration"`
+	IPConfigurations []string `json:"ipConfigurations"`
+	Name             string
+	SecurityGroup    string
 }
 
+// OSProfileLinuxInput describes the Linux configuration of a VM. If no SSH
+// keys are provided, an SSH key pair is generated and exported as a secret
+// stack output. Incrementing Rotation regenerates the key pair.
 type OSProfileLinuxInput struct {
 	DisablePasswordAuthentication bool
 	Name                          string
+	Rotation                      int
 	SSHKeyData                    string
 	SSHKeyPath                    string
+	SSHKeys                       []*SSHKeyInput `json:"sshKeys"`
+}
+
+// SSHKeyInput describes one or more SSH public keys to be added to the VM.
+// Exactly one of KeyData, KeyFile or AuthorizedKeysFile must be set. The keys
+// of an authorized_keys-style file are all added to the same path. If Path is
+// empty, the SSHKeyPath of the enclosing Linux OS profile is used.
+type SSHKeyInput struct {
+	AuthorizedKeysFile string
+	KeyData            string
+	KeyFile            string
+	Path               string
 }
 
+// OSProfileInput describes the OS configuration of a VM. If AdminPassword is
+// empty, a password is generated and exported as a secret stack output.
+// Incrementing Rotation regenerates the password.
 type OSProfileInput struct {
 	AdminPassword string
 	AdminUsername string
 	CustomData    string
 	Name          string
+	Rotation      int
+}
+
+type ProximityPlacementGroupInput struct {
+	Name string
+}
+
+// RollingUpdateInput enables rolling replacement of the instances of a VM
+// group, where at most MaxUnavailable instances are replaced at a time.
+type RollingUpdateInput struct {
+	MaxUnavailable int
 }
 
 type StorageImageReferenceInput struct {
@@ -41,24 +110,50 @@ type StorageImageReferenceInput struct {
 	Version   string
 }
 
+// StorageOSDiskInput describes the OS disk of a VM. Caching and
+// ManagedDiskType are optional. The OS disks of VMs on dedicated hosts default
+// to ReadWrite caching and Standard_LRS managed disks.
 type StorageOSDiskInput struct {
-	CreateOption string
-	DiskSizeGB   int
-	Name         string
-	OSType       string
+	Caching         string
+	CreateOption    string
+	DiskSizeGB      int
+	ManagedDiskType string
+	Name            string
+	OSType          string
 }
 
+// VirtualMachineInput describes a group of VM instances. Instances are placed
+// either in AvailabilitySet, or onto DedicatedHost, in the availability zone of
+// its host group.
 type VirtualMachineInput struct {
-	AppSecGroup           string
-	AvailabilitySet       string
-	Count                 int
-	CustomData            string
-	Name                  string
-	NetworkInterface      string
-	OSProfile             string
-	OSProfileLinux        string
-	StorageImageReference string
-	StorageOSDisk         string
-	VirtualNetwork        string
-	VMSize                string `json:"vmSize"`
+	AppSecGroup             string
+	AutoShutdown            string
+	AvailabilitySet         string
+	BackupPolicy            string
+	Count                   int
+	CustomData              string
+	DedicatedHost           string
+	Name                    string
+	NetworkInterface        string
+	OSProfile               string
+	OSProfileLinux          string
+	Overrides               map[string]*VirtualMachineOverrideInput
+	ProximityPlacementGroup string
+	RollingUpdate           *RollingUpdateInput
+	StorageImageReference   string
+	StorageOSDisk           string
+	VirtualNetwork          string
+	VMSize                  string `json:"vmSize"`
+}
+
+// VirtualMachineOverrideInput holds the properties that can be overridden for
+// a single instance of a virtual machine group. Overrides are keyed by either
+// the instance index (e.g. "2") or the instance name (e.g. "web-02"). When both
+// keys are present, the name-keyed override is applied last.
+type VirtualMachineOverrideInput struct {
+	CustomData string
+	DiskSizeGB int `json:"diskSizeGB"`
+	Subnet     string
+	Tags       map[string]string
+	VMSize     string `json:"vmSize"`
 }
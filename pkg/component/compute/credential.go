@@ -0,0 +1,264 @@
+package compute
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/stackref"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	generatedAdminPasswordsOutput = "generatedAdminPasswords"
+	generatedCredentialsOutput    = "generatedCredentials"
+	generatedSSHKeysOutput        = "generatedSSHKeys"
+
+	// previewCredential stands in for credentials that are generated during
+	// an update, so that previews don't generate any keys.
+	previewCredential = "[generated on update]"
+
+	passwordLength = 24
+	sshKeyBits     = 4096
+
+	passwordLowerChars   = "abcdefghijkmnopqrstuvwxyz"
+	passwordUpperChars   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	passwordDigitChars   = "23456789"
+	passwordSpecialChars = "!#%*()-_=+[]{}"
+)
+
+// credentials generates the admin passwords and SSH key pairs of OS profiles
+// that don't define their own. Generated credentials are exported as secret
+// stack outputs, and are read back from the previous outputs of the stack,
+// referenced by its fully qualified name, on subsequent updates so that they
+// remain stable. The rotation counters of the generated credentials are
+// exported as a plain output. A credential is regenerated only when the
+// rotation counter of its OS profile changes; if a credential recorded there
+// can't be read back, the update fails instead of rotating the VMs.
+type credentials struct {
+	ctx       *pulumi.Context
+	cfg       *config.Config
+	previous  *pulumi.StackReference
+	passwords pulumi.Map
+	rotations map[string]pulumi.StringMap
+	sshKeys   pulumi.Map
+}
+
+func newCredentials(ctx *pulumi.Context, cfg *config.Config) *credentials {
+	return &credentials{
+		ctx:       ctx,
+		cfg:       cfg,
+		passwords: pulumi.Map{},
+		rotations: map[string]pulumi.StringMap{},
+		sshKeys:   pulumi.Map{},
+	}
+}
+
+// adminPassword returns the generated admin password of the named OS profile.
+func (c *credentials) adminPassword(profile string, rotation int) (pulumi.StringOutput, error) {
+	generated, err := c.generated(generatedAdminPasswordsOutput, profile, rotation, []string{"adminPassword"}, func() (map[string]string, error) {
+		password, err := generatePassword()
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]string{"adminPassword": password}, nil
+	})
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	c.passwords[profile] = generated
+	return generated.MapIndex(pulumi.String("adminPassword")), nil
+}
+
+// sshPublicKey returns the public key of the generated SSH key pair of the
+// named Linux OS profile.
+func (c *credentials) sshPublicKey(profile string, rotation int) (pulumi.StringOutput, error) {
+	generated, err := c.generated(generatedSSHKeysOutput, profile, rotation, []string{"sshPrivateKey", "sshPublicKey"}, func() (map[string]string, error) {
+		privateKey, publicKey, err := generateSSHKeyPair()
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]string{
+			"sshPrivateKey": privateKey,
+			"sshPublicKey":  publicKey,
+		}, nil
+	})
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	c.sshKeys[profile] = generated
+	return generated.MapIndex(pulumi.String("sshPublicKey")), nil
+}
+
+// export adds all the generated credentials, and their rotation counters, to
+// the stack outputs.
+func (c *credentials) export() {
+	if len(c.passwords) > 0 {
+		c.ctx.Export(generatedAdminPasswordsOutput, pulumi.ToSecret(c.passwords))
+	}
+
+	if len(c.sshKeys) > 0 {
+		c.ctx.Export(generatedSSHKeysOutput, pulumi.ToSecret(c.sshKeys))
+	}
+
+	if len(c.rotations) > 0 {
+		rotations := pulumi.Map{}
+		for output, profiles := range c.rotations {
+			rotations[output] = profiles
+		}
+		c.ctx.Export(generatedCredentialsOutput, rotations)
+	}
+}
+
+func (c *credentials) generated(
+	output string,
+	profile string,
+	rotation int,
+	keys []string,
+	generate func() (map[string]string, error)) (pulumi.StringMapOutput, error) {
+
+	if c.previous == nil {
+		previous, err := stackref.Previous(c.ctx, c.cfg, c.ctx.Stack()+"-previous-credentials")
+		if err != nil {
+			return pulumi.StringMapOutput{}, err
+		}
+		c.previous = previous
+	}
+
+	if _, exists := c.rotations[output]; !exists {
+		c.rotations[output] = pulumi.StringMap{}
+	}
+	c.rotations[output][profile] = pulumi.String(strconv.Itoa(rotation))
+
+	dryRun := c.ctx.DryRun()
+	generated := c.previous.Outputs.ApplyT(func(outputs map[string]interface{}) (map[string]string, error) {
+		credential, found, err := previousCredential(outputs, output, profile, rotation)
+		if err != nil || found {
+			return credential, err
+		}
+
+		if dryRun {
+			credential := map[string]string{}
+			for _, key := range keys {
+				credential[key] = previewCredential
+			}
+			return credential, nil
+		}
+
+		credential, err = generate()
+		if err != nil {
+			return nil, err
+		}
+		credential["rotation"] = strconv.Itoa(rotation)
+		return credential, nil
+	}).(pulumi.StringMapOutput)
+
+	return pulumi.ToSecret(generated).(pulumi.StringMapOutput), nil
+}
+
+// previousCredential returns the credential of the OS profile from the
+// previous outputs of the stack, if it was generated with the same rotation
+// counter. It fails if the rotation counters of the previous outputs record
+// the credential, but the credential itself can't be read.
+func previousCredential(outputs map[string]interface{}, output, profile string, rotation int) (map[string]string, bool, error) {
+	if profiles, ok := outputs[output].(map[string]interface{}); ok {
+		if previous, ok := profiles[profile].(map[string]interface{}); ok &&
+			previous["rotation"] == strconv.Itoa(rotation) {
+
+			credential := map[string]string{}
+			for k, v := range previous {
+				if s, ok := v.(string); ok {
+					credential[k] = s
+				}
+			}
+			return credential, true, nil
+		}
+	}
+
+	if rotations, ok := outputs[generatedCredentialsOutput].(map[string]interface{}); ok {
+		if profiles, ok := rotations[output].(map[string]interface{}); ok &&
+			profiles[profile] == strconv.Itoa(rotation) {
+
+			return nil, false, pulumierr.InvalidConfigErr{profile, "OS profile",
+				fmt.Sprintf("the generated credential can't be read from the %s stack output, increment the rotation counter to regenerate it", output)}
+		}
+	}
+
+	return nil, false, nil
+}
+
+// generatePassword returns a random password that satisfies the Azure VM
+// password complexity requirements.
+func generatePassword() (string, error) {
+	classes := []string{passwordLowerChars, passwordUpperChars, passwordDigitChars, passwordSpecialChars}
+
+	password := []byte{}
+	for _, class := range classes {
+		c, err := randomChar(class)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	all := strings.Join(classes, "")
+	for len(password) < passwordLength {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	if err != nil {
+		return 0, err
+	}
+
+	return chars[i.Int64()], nil
+}
+
+// generateSSHKeyPair returns a PEM-encoded RSA private key and its public key
+// in the authorized_keys format.
+func generateSSHKeyPair() (string, string, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, sshKeyBits)
+	if err != nil {
+		return "", "", err
+	}
+
+	publicKey, err := ssh.NewPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+
+	return string(privateKeyPEM), strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))), nil
+}
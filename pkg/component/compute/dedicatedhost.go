@@ -0,0 +1,257 @@
+package compute
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/compute"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+const (
+	defaultOSDiskCaching            = "ReadWrite"
+	defaultOSDiskStorageAccountType = "Standard_LRS"
+)
+
+// dedicatedHost is a dedicated host, along with the availability zone of its
+// host group. VMs placed onto the host must be in the same zone.
+type dedicatedHost struct {
+	id   pulumi.IDOutput
+	zone string
+}
+
+// dedicatedHosts creates the dedicated host groups and their hosts, keyed by
+// host names.
+func dedicatedHosts(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	resourceGroup *core.ResourceGroup,
+	tags pulumi.StringMap) (map[string]*dedicatedHost, error) {
+
+	hostGroupInput := []*DedicatedHostGroupInput{}
+	if err := cfg.GetObject("dedicatedHostGroups", &hostGroupInput); err != nil {
+		return nil, err
+	}
+
+	hostInput := []*DedicatedHostInput{}
+	if err := cfg.GetObject("dedicatedHosts", &hostInput); err != nil {
+		return nil, err
+	}
+
+	var (
+		hostGroupInputs = map[string]*DedicatedHostGroupInput{}
+		hostGroups      = map[string]pulumi.IDOutput{}
+	)
+	for _, input := range hostGroupInput {
+		if input.PlatformFaultDomainCount < 1 || input.PlatformFaultDomainCount > 3 {
+			return nil, pulumierr.InvalidConfigErr{input.Name, "dedicated host group", "platformFaultDomainCount must be between 1 and 3"}
+		}
+
+		args := &compute.DedicatedHostGroupArgs{
+			Location:                 resourceGroup.Location,
+			Name:                     pulumi.String(input.Name),
+			PlatformFaultDomainCount: pulumi.Int(input.PlatformFaultDomainCount),
+			ResourceGroupName:        resourceGroup.Name,
+			Tags:                     tags,
+		}
+
+		if len(input.Zone) > 0 {
+			args.Zones = pulumi.String(input.Zone)
+		}
+
+		hostGroup, err := compute.NewDedicatedHostGroup(ctx, input.Name, args)
+		if err != nil {
+			return nil, err
+		}
+
+		hostGroupInputs[input.Name] = input
+		hostGroups[input.Name] = hostGroup.ID()
+	}
+
+	dedicatedHosts := map[string]*dedicatedHost{}
+	for _, input := range hostInput {
+		if err := validateDedicatedHost(input, hostGroupInputs); err != nil {
+			return nil, err
+		}
+
+		args := &compute.DedicatedHostArgs{
+			AutoReplaceOnFailure: pulumi.Bool(input.AutoReplaceOnFailure),
+			DedicatedHostGroupId: hostGroups[input.HostGroup],
+			Location:             resourceGroup.Location,
+			Name:                 pulumi.String(input.Name),
+			PlatformFaultDomain:  pulumi.Int(input.PlatformFaultDomain),
+			SkuName:              pulumi.String(input.SKU),
+			Tags:                 tags,
+		}
+
+		if len(input.LicenseType) > 0 {
+			args.LicenseType = pulumi.String(input.LicenseType)
+		}
+
+		host, err := compute.NewDedicatedHost(ctx, input.Name, args)
+		if err != nil {
+			return nil, err
+		}
+
+		dedicatedHosts[input.Name] = &dedicatedHost{
+			id:   host.ID(),
+			zone: hostGroupInputs[input.HostGroup].Zone,
+		}
+	}
+
+	return dedicatedHosts, nil
+}
+
+// validateDedicatedHost ensures that the host group of the dedicated host
+// exists, and has the fault domain of the host.
+func validateDedicatedHost(input *DedicatedHostInput, hostGroups map[string]*DedicatedHostGroupInput) error {
+	hostGroup, exists := hostGroups[input.HostGroup]
+	if !exists {
+		return pulumierr.MissingConfigErr{input.HostGroup, "dedicated host group"}
+	}
+
+	if input.PlatformFaultDomain < 0 || input.PlatformFaultDomain >= hostGroup.PlatformFaultDomainCount {
+		return pulumierr.InvalidConfigErr{input.Name, "dedicated host",
+			fmt.Sprintf("platformFaultDomain must be less than the platformFaultDomainCount %d of host group %s", hostGroup.PlatformFaultDomainCount, input.HostGroup)}
+	}
+
+	return nil
+}
+
+// validateDedicatedHostPlacement ensures that the dedicated host of the VM
+// group exists. VMs on a dedicated host can't be in an availability set, as
+// the host group decides their fault domains.
+func validateDedicatedHostPlacement(input *VirtualMachineInput, dedicatedHosts map[string]*dedicatedHost) error {
+	if _, exists := dedicatedHosts[input.DedicatedHost]; !exists {
+		return pulumierr.MissingConfigErr{input.DedicatedHost, "dedicated host"}
+	}
+
+	if len(input.AvailabilitySet) > 0 {
+		return pulumierr.InvalidConfigErr{input.Name, "virtual machine", "VMs on a dedicated host can't be in an availability set"}
+	}
+
+	return nil
+}
+
+// dedicatedHostVirtualMachine creates a VM instance on a dedicated host. The
+// VirtualMachine resource can't be placed onto a dedicated host, so these
+// instances are LinuxVirtualMachine resources, created from the same OS
+// profiles, image and OS disk. Their SSH keys are added to the authorized_keys
+// file of the admin user, regardless of their paths.
+func dedicatedHostVirtualMachine(
+	ctx *pulumi.Context,
+	host *dedicatedHost,
+	instanceName pulumi.String,
+	osProfile compute.VirtualMachineOsProfileArgs,
+	osProfileLinux compute.VirtualMachineOsProfileLinuxConfigArgs,
+	storageImageReference compute.VirtualMachineStorageImageReferenceArgs,
+	storageOSDisk compute.VirtualMachineStorageOsDiskArgs,
+	networkInterfaceID pulumi.IDOutput,
+	proximityPlacementGroupID pulumi.StringPtrInput,
+	resourceGroup *core.ResourceGroup,
+	tags pulumi.StringMap,
+	vmSize string,
+	opts ...pulumi.ResourceOption) (*compute.LinuxVirtualMachine, error) {
+
+	adminSSHKeys := pulumi.All(osProfileLinux.SshKeys, osProfile.AdminUsername).ApplyT(
+		func(args []interface{}) []compute.LinuxVirtualMachineAdminSshKey {
+			adminSSHKeys := []compute.LinuxVirtualMachineAdminSshKey{}
+			for _, key := range args[0].([]compute.VirtualMachineOsProfileLinuxConfigSshKey) {
+				adminSSHKeys = append(adminSSHKeys, compute.LinuxVirtualMachineAdminSshKey{
+					PublicKey: key.KeyData,
+					Username:  args[1].(string),
+				})
+			}
+			return adminSSHKeys
+		}).(compute.LinuxVirtualMachineAdminSshKeyArrayOutput)
+
+	customData := osProfile.CustomData.ToStringPtrOutput().ApplyT(func(customData *string) *string {
+		if customData == nil || len(*customData) == 0 {
+			return nil
+		}
+
+		encoded := base64.StdEncoding.EncodeToString([]byte(*customData))
+		return &encoded
+	}).(pulumi.StringPtrOutput)
+
+	osDisk := storageOSDisk.ToVirtualMachineStorageOsDiskOutput().ApplyT(
+		func(disk compute.VirtualMachineStorageOsDisk) compute.LinuxVirtualMachineOsDisk {
+			return linuxOSDisk(disk)
+		}).(compute.LinuxVirtualMachineOsDiskOutput)
+
+	sourceImageReference := storageImageReference.ToVirtualMachineStorageImageReferenceOutput().ApplyT(
+		func(image compute.VirtualMachineStorageImageReference) compute.LinuxVirtualMachineSourceImageReference {
+			return linuxSourceImageReference(image)
+		}).(compute.LinuxVirtualMachineSourceImageReferenceOutput)
+
+	args := &compute.LinuxVirtualMachineArgs{
+		AdminPassword:                 osProfile.AdminPassword,
+		AdminSshKeys:                  adminSSHKeys,
+		AdminUsername:                 osProfile.AdminUsername,
+		ComputerName:                  instanceName,
+		CustomData:                    customData,
+		DedicatedHostId:               host.id.ToStringOutput().ToStringPtrOutput(),
+		DisablePasswordAuthentication: osProfileLinux.DisablePasswordAuthentication.ToBoolOutput().ToBoolPtrOutput(),
+		Location:                      resourceGroup.Location,
+		Name:                          instanceName,
+		NetworkInterfaceIds:           pulumi.StringArray{networkInterfaceID},
+		OsDisk:                        osDisk,
+		ProximityPlacementGroupId:     proximityPlacementGroupID,
+		ResourceGroupName:             resourceGroup.Name,
+		Size:                          pulumi.String(vmSize),
+		SourceImageReference:          sourceImageReference,
+		Tags:                          tags,
+	}
+
+	if len(host.zone) > 0 {
+		args.Zone = pulumi.String(host.zone)
+	}
+
+	return compute.NewLinuxVirtualMachine(ctx, string(instanceName), args, opts...)
+}
+
+// linuxOSDisk returns the LinuxVirtualMachine OS disk of a VirtualMachine OS
+// disk. Its caching defaults to ReadWrite, and its storage account type to
+// Standard_LRS.
+func linuxOSDisk(disk compute.VirtualMachineStorageOsDisk) compute.LinuxVirtualMachineOsDisk {
+	osDisk := compute.LinuxVirtualMachineOsDisk{
+		Caching:            defaultOSDiskCaching,
+		Name:               &disk.Name,
+		StorageAccountType: defaultOSDiskStorageAccountType,
+	}
+
+	if disk.Caching != nil && len(*disk.Caching) > 0 {
+		osDisk.Caching = *disk.Caching
+	}
+
+	if disk.DiskSizeGb != nil && *disk.DiskSizeGb > 0 {
+		osDisk.DiskSizeGb = disk.DiskSizeGb
+	}
+
+	if disk.ManagedDiskType != nil && len(*disk.ManagedDiskType) > 0 {
+		osDisk.StorageAccountType = *disk.ManagedDiskType
+	}
+
+	return osDisk
+}
+
+// linuxSourceImageReference returns the LinuxVirtualMachine source image of a
+// VirtualMachine storage image reference.
+func linuxSourceImageReference(image compute.VirtualMachineStorageImageReference) compute.LinuxVirtualMachineSourceImageReference {
+	value := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+
+	return compute.LinuxVirtualMachineSourceImageReference{
+		Offer:     value(image.Offer),
+		Publisher: value(image.Publisher),
+		Sku:       value(image.Sku),
+		Version:   value(image.Version),
+	}
+}
@@ -0,0 +1,160 @@
+package compute
+
+import (
+	"encoding/json"
+	"fmt"
+
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+// autoShutdownTemplate is the ARM template of a DevTest global VM shutdown
+// schedule. The SDK doesn't provide a resource for these schedules, so they
+// are created as template deployments. Note that removing a template
+// deployment doesn't remove the schedule; it is removed with its VM. So
+// schedules are disabled through their status instead of being removed.
+const autoShutdownTemplate = `{
+  "$schema": "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
+  "contentVersion": "1.0.0.0",
+  "parameters": {
+    "emailRecipient": {"type": "string"},
+    "location": {"type": "string"},
+    "notificationStatus": {"type": "string"},
+    "notificationTimeInMinutes": {"type": "int"},
+    "tags": {"type": "object"},
+    "time": {"type": "string"},
+    "timeZoneId": {"type": "string"},
+    "virtualMachineId": {"type": "string"},
+    "status": {"type": "string"},
+    "virtualMachineName": {"type": "string"},
+    "webhookUrl": {"type": "string"}
+  },
+  "resources": [{
+    "type": "Microsoft.DevTestLab/schedules",
+    "apiVersion": "2018-09-15",
+    "name": "[concat('shutdown-computevm-', parameters('virtualMachineName'))]",
+    "location": "[parameters('location')]",
+    "tags": "[parameters('tags')]",
+    "properties": {
+      "status": "[parameters('status')]",
+      "taskType": "ComputeVmShutdownTask",
+      "dailyRecurrence": {"time": "[parameters('time')]"},
+      "timeZoneId": "[parameters('timeZoneId')]",
+      "targetResourceId": "[parameters('virtualMachineId')]",
+      "notificationSettings": {
+        "status": "[parameters('notificationStatus')]",
+        "timeInMinutes": "[parameters('notificationTimeInMinutes')]",
+        "emailRecipient": "[parameters('emailRecipient')]",
+        "webhookUrl": "[parameters('webhookUrl')]"
+      }
+    }
+  }]
+}`
+
+const defaultNotificationTimeInMinutes = 30
+
+// autoShutdownSchedules returns the auto-shutdown schedules of the stack, the
+// name of the schedule applied to VM groups that don't specify one, and
+// whether the schedules are enabled. If auto-shutdown is disabled for the
+// stack, the schedules are still deployed, with a disabled status, so that
+// the schedules of existing VMs stop too.
+func autoShutdownSchedules(cfg *config.Config) (map[string]*AutoShutdownInput, string, bool, error) {
+	autoShutdownInput := []*AutoShutdownInput{}
+	if err := cfg.GetObject("autoShutdownSchedules", &autoShutdownInput); err != nil {
+		return nil, "", false, err
+	}
+
+	schedules := map[string]*AutoShutdownInput{}
+	for _, input := range autoShutdownInput {
+		schedules[input.Name] = input
+	}
+
+	defaultSchedule := cfg.Get("defaultAutoShutdownSchedule")
+	if _, exists := schedules[defaultSchedule]; len(defaultSchedule) > 0 && !exists {
+		return nil, "", false, pulumierr.MissingConfigErr{defaultSchedule, "auto-shutdown schedule"}
+	}
+
+	return schedules, defaultSchedule, !cfg.GetBool("autoShutdownDisabled"), nil
+}
+
+func autoShutdown(
+	ctx *pulumi.Context,
+	input *AutoShutdownInput,
+	enabled bool,
+	resourceGroup *core.ResourceGroup,
+	virtualMachineID pulumi.IDOutput,
+	instanceName string,
+	tags pulumi.StringMap) error {
+
+	parameters := pulumi.All(virtualMachineID, resourceGroup.Location, tags).ApplyT(
+		func(args []interface{}) (string, error) {
+			return autoShutdownParameters(input, enabled, instanceName, string(args[0].(pulumi.ID)), args[1].(string), args[2].(map[string]string))
+		}).(pulumi.StringOutput)
+
+	deploymentName := fmt.Sprintf("%s-auto-shutdown", instanceName)
+	_, err := core.NewTemplateDeployment(ctx, deploymentName, &core.TemplateDeploymentArgs{
+		DeploymentMode:    pulumi.String("Incremental"),
+		Name:              pulumi.String(deploymentName),
+		ParametersBody:    parameters,
+		ResourceGroupName: resourceGroup.Name,
+		TemplateBody:      pulumi.String(autoShutdownTemplate),
+	})
+
+	return err
+}
+
+// autoShutdownParameters returns the parameters of the auto-shutdown template
+// deployment of a VM instance.
+func autoShutdownParameters(
+	input *AutoShutdownInput,
+	enabled bool,
+	instanceName string,
+	virtualMachineID string,
+	location string,
+	tags map[string]string) (string, error) {
+
+	var (
+		notificationStatus        = "Disabled"
+		notificationTimeInMinutes = input.NotificationMinutes
+		status                    = "Disabled"
+	)
+	if enabled {
+		status = "Enabled"
+	}
+
+	if len(input.NotificationEmail) > 0 || len(input.NotificationWebhookURL) > 0 {
+		notificationStatus = "Enabled"
+	}
+
+	if notificationTimeInMinutes == 0 {
+		notificationTimeInMinutes = defaultNotificationTimeInMinutes
+	}
+
+	parameters := map[string]interface{}{
+		"emailRecipient":            input.NotificationEmail,
+		"location":                  location,
+		"notificationStatus":        notificationStatus,
+		"notificationTimeInMinutes": notificationTimeInMinutes,
+		"tags":                      tags,
+		"time":                      input.Time,
+		"status":                    status,
+		"timeZoneId":                input.Timezone,
+		"virtualMachineId":          virtualMachineID,
+		"virtualMachineName":        instanceName,
+		"webhookUrl":                input.NotificationWebhookURL,
+	}
+
+	body := map[string]interface{}{}
+	for name, value := range parameters {
+		body[name] = map[string]interface{}{"value": value}
+	}
+
+	content, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
@@ -0,0 +1,56 @@
+package compute
+
+import (
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/compute"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+)
+
+// VMGroup is the set of VM instances created from a virtual machine config
+// entry, along with the resources shared by the instances. AvailabilitySet is
+// nil if the instances are on a dedicated host. VirtualNetwork is the name of
+// the virtual network of the instances.
+type VMGroup struct {
+	AppSecGroup     *network.ApplicationSecurityGroup
+	AvailabilitySet *compute.AvailabilitySet
+	Instances       []*VMInstance
+	Name            string
+	VirtualNetwork  string
+}
+
+// VMInstance is a VM instance of a VMGroup, and its primary network interface.
+// Instances on a dedicated host have a LinuxVirtualMachine instead of a
+// VirtualMachine. IPv6ConfigurationName is empty unless the network interface
+// is dual-stack.
+// BackendAddressPoolAssociations add the network interface to the backend
+// pools of the load balancers of the VM group.
+type VMInstance struct {
+	BackendAddressPoolAssociations []*network.NetworkInterfaceBackendAddressPoolAssociation
+	IPConfigurationName            string
+	IPv6ConfigurationName          string
+	LinuxVirtualMachine            *compute.LinuxVirtualMachine
+	Name                           string
+	NetworkInterface               *network.NetworkInterface
+	PrivateIPAddress               pulumi.StringOutput
+	VirtualMachine                 *compute.VirtualMachine
+}
+
+// virtualMachine returns the VM resource of the instance.
+func (i *VMInstance) virtualMachine() pulumi.CustomResource {
+	if i.LinuxVirtualMachine != nil {
+		return i.LinuxVirtualMachine
+	}
+
+	return i.VirtualMachine
+}
+
+// PrivateIPAddresses returns the private IP addresses of the instances of the
+// group, keyed by instance names.
+func (g *VMGroup) PrivateIPAddresses() pulumi.StringMap {
+	privateIPAddresses := pulumi.StringMap{}
+	for _, instance := range g.Instances {
+		privateIPAddresses[instance.Name] = instance.PrivateIPAddress
+	}
+
+	return privateIPAddresses
+}
@@ -0,0 +1,74 @@
+package firewall
+
+// FirewallInput describes an Azure Firewall in the AzureFirewallSubnet of a
+// virtual network, with its rule collections. The default routes of the
+// RouteSubnets are sent to the firewall through a generated route table.
+type FirewallInput struct {
+	ApplicationRuleCollections []*ApplicationRuleCollectionInput
+	Name                       string
+	NATRuleCollections         []*NATRuleCollectionInput `json:"natRuleCollections"`
+	NetworkRuleCollections     []*NetworkRuleCollectionInput
+	PublicIP                   string
+	RouteSubnets               []string
+	Subnet                     string
+	Zones                      []string
+}
+
+// ApplicationRuleCollectionInput describes a collection of FQDN filtering
+// rules. Action is either Allow or Deny.
+type ApplicationRuleCollectionInput struct {
+	Action   string
+	Name     string
+	Priority int
+	Rules    []*ApplicationRuleInput
+}
+
+// ApplicationRuleInput describes an FQDN filtering rule. Protocols are in the
+// <type>:<port> form, e.g. Https:443.
+type ApplicationRuleInput struct {
+	Description     string
+	FQDNTags        []string `json:"fqdnTags"`
+	Name            string
+	Protocols       []string
+	SourceAddresses []string
+	TargetFQDNs     []string `json:"targetFqdns"`
+}
+
+// NATRuleCollectionInput describes a collection of DNAT rules.
+type NATRuleCollectionInput struct {
+	Name     string
+	Priority int
+	Rules    []*NATRuleInput
+}
+
+// NATRuleInput describes a DNAT rule. The destination address defaults to the
+// public IP address of the firewall.
+type NATRuleInput struct {
+	Description          string
+	DestinationAddresses []string
+	DestinationPorts     []string
+	Name                 string
+	Protocols            []string
+	SourceAddresses      []string
+	TranslatedAddress    string
+	TranslatedPort       string
+}
+
+// NetworkRuleCollectionInput describes a collection of network filtering
+// rules. Action is either Allow or Deny.
+type NetworkRuleCollectionInput struct {
+	Action   string
+	Name     string
+	Priority int
+	Rules    []*NetworkRuleInput
+}
+
+// NetworkRuleInput describes a network filtering rule.
+type NetworkRuleInput struct {
+	Description          string
+	DestinationAddresses []string
+	DestinationPorts     []string
+	Name                 string
+	Protocols            []string
+	SourceAddresses      []string
+}
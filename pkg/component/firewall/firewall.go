@@ -0,0 +1,400 @@
+package firewall
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	vnet "github.com/ihcsim/pulumi-azure/v2/pkg/component/network"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/convert"
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+const (
+	// firewallSubnetName is the name Azure requires of the subnet of a
+	// firewall.
+	firewallSubnetName = "AzureFirewallSubnet"
+
+	minPriority = 100
+	maxPriority = 65000
+)
+
+var (
+	knownApplicationProtocols = map[string]bool{"Http": true, "Https": true, "Mssql": true}
+	knownNATProtocols         = map[string]bool{"TCP": true, "UDP": true}
+	knownNetworkProtocols     = map[string]bool{"Any": true, "ICMP": true, "TCP": true, "UDP": true}
+
+	// knownFilterActions are the actions of network and application rule
+	// collections, and knownNATActions are those of NAT rule collections.
+	knownFilterActions = map[string]bool{"Allow": true, "Deny": true}
+	knownNATActions    = map[string]bool{"Dnat": true}
+)
+
+func Reconcile(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	publicIPs map[string]*network.PublicIp,
+	resourceGroup *core.ResourceGroup,
+	subnets map[string]*network.Subnet,
+	tags pulumi.StringMap) (map[string]*network.Firewall, error) {
+
+	firewallInput := []*FirewallInput{}
+	if err := cfg.GetObject("firewalls", &firewallInput); err != nil {
+		return nil, err
+	}
+
+	subnetInput := []*vnet.SubnetInput{}
+	if err := cfg.GetObject("subnets", &subnetInput); err != nil {
+		return nil, err
+	}
+
+	firewalls := map[string]*network.Firewall{}
+	for _, input := range firewallInput {
+		if err := validateFirewall(input, subnetInput); err != nil {
+			return nil, err
+		}
+
+		subnet, exists := subnets[input.Subnet]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{input.Subnet, "subnet"}
+		}
+
+		publicIP, exists := publicIPs[input.PublicIP]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{input.PublicIP, "public IP"}
+		}
+
+		args := &network.FirewallArgs{
+			IpConfigurations: network.FirewallIpConfigurationArray{
+				network.FirewallIpConfigurationArgs{
+					Name:              pulumi.String(fmt.Sprintf("%s-ip-config", input.Name)),
+					PublicIpAddressId: publicIP.ID(),
+					SubnetId:          subnet.ID(),
+				},
+			},
+			Location:          resourceGroup.Location,
+			Name:              pulumi.String(input.Name),
+			ResourceGroupName: resourceGroup.Name,
+			Tags:              tags,
+		}
+
+		if len(input.Zones) > 0 {
+			args.Zones = convert.ToStringArray(input.Zones)
+		}
+
+		firewall, err := network.NewFirewall(ctx, input.Name, args)
+		if err != nil {
+			return nil, err
+		}
+		firewalls[input.Name] = firewall
+
+		if err := ruleCollections(ctx, input, firewall, publicIP, resourceGroup); err != nil {
+			return nil, err
+		}
+
+		if err := defaultRoutes(ctx, input, firewall, resourceGroup, subnets, tags); err != nil {
+			return nil, err
+		}
+	}
+
+	return firewalls, nil
+}
+
+// ruleCollections creates the network, application and NAT rule collections
+// of the firewall.
+func ruleCollections(
+	ctx *pulumi.Context,
+	input *FirewallInput,
+	firewall *network.Firewall,
+	publicIP *network.PublicIp,
+	resourceGroup *core.ResourceGroup) error {
+
+	for _, collection := range input.NetworkRuleCollections {
+		rules := network.FirewallNetworkRuleCollectionRuleArray{}
+		for _, rule := range collection.Rules {
+			rules = append(rules, network.FirewallNetworkRuleCollectionRuleArgs{
+				Description:          pulumi.String(rule.Description),
+				DestinationAddresses: convert.ToStringArray(rule.DestinationAddresses),
+				DestinationPorts:     convert.ToStringArray(rule.DestinationPorts),
+				Name:                 pulumi.String(rule.Name),
+				Protocols:            convert.ToStringArray(rule.Protocols),
+				SourceAddresses:      convert.ToStringArray(rule.SourceAddresses),
+			})
+		}
+
+		if _, err := network.NewFirewallNetworkRuleCollection(ctx, collection.Name,
+			&network.FirewallNetworkRuleCollectionArgs{
+				Action:            pulumi.String(collection.Action),
+				AzureFirewallName: firewall.Name,
+				Name:              pulumi.String(collection.Name),
+				Priority:          pulumi.Int(collection.Priority),
+				ResourceGroupName: resourceGroup.Name,
+				Rules:             rules,
+			}); err != nil {
+			return err
+		}
+	}
+
+	for _, collection := range input.ApplicationRuleCollections {
+		rules := network.FirewallApplicationRuleCollectionRuleArray{}
+		for _, rule := range collection.Rules {
+			protocols := network.FirewallApplicationRuleCollectionRuleProtocolArray{}
+			for _, protocol := range rule.Protocols {
+				protocolType, port, _ := applicationProtocol(protocol)
+				protocols = append(protocols, network.FirewallApplicationRuleCollectionRuleProtocolArgs{
+					Port: pulumi.Int(port),
+					Type: pulumi.String(protocolType),
+				})
+			}
+
+			args := network.FirewallApplicationRuleCollectionRuleArgs{
+				Description:     pulumi.String(rule.Description),
+				Name:            pulumi.String(rule.Name),
+				SourceAddresses: convert.ToStringArray(rule.SourceAddresses),
+			}
+
+			if len(rule.FQDNTags) > 0 {
+				args.FqdnTags = convert.ToStringArray(rule.FQDNTags)
+			} else {
+				args.Protocols = protocols
+				args.TargetFqdns = convert.ToStringArray(rule.TargetFQDNs)
+			}
+
+			rules = append(rules, args)
+		}
+
+		if _, err := network.NewFirewallApplicationRuleCollection(ctx, collection.Name,
+			&network.FirewallApplicationRuleCollectionArgs{
+				Action:            pulumi.String(collection.Action),
+				AzureFirewallName: firewall.Name,
+				Name:              pulumi.String(collection.Name),
+				Priority:          pulumi.Int(collection.Priority),
+				ResourceGroupName: resourceGroup.Name,
+				Rules:             rules,
+			}); err != nil {
+			return err
+		}
+	}
+
+	for _, collection := range input.NATRuleCollections {
+		rules := network.FirewallNatRuleCollectionRuleArray{}
+		for _, rule := range collection.Rules {
+			var destinationAddresses pulumi.StringArrayInput = pulumi.StringArray{publicIP.IpAddress}
+			if len(rule.DestinationAddresses) > 0 {
+				destinationAddresses = convert.ToStringArray(rule.DestinationAddresses)
+			}
+
+			rules = append(rules, network.FirewallNatRuleCollectionRuleArgs{
+				Description:          pulumi.String(rule.Description),
+				DestinationAddresses: destinationAddresses,
+				DestinationPorts:     convert.ToStringArray(rule.DestinationPorts),
+				Name:                 pulumi.String(rule.Name),
+				Protocols:            convert.ToStringArray(rule.Protocols),
+				SourceAddresses:      convert.ToStringArray(rule.SourceAddresses),
+				TranslatedAddress:    pulumi.String(rule.TranslatedAddress),
+				TranslatedPort:       pulumi.String(rule.TranslatedPort),
+			})
+		}
+
+		if _, err := network.NewFirewallNatRuleCollection(ctx, collection.Name,
+			&network.FirewallNatRuleCollectionArgs{
+				Action:            pulumi.String("Dnat"),
+				AzureFirewallName: firewall.Name,
+				Name:              pulumi.String(collection.Name),
+				Priority:          pulumi.Int(collection.Priority),
+				ResourceGroupName: resourceGroup.Name,
+				Rules:             rules,
+			}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// defaultRoutes creates a route table with a default route to the private IP
+// address of the firewall, and associates it with the route subnets of the
+// firewall.
+func defaultRoutes(
+	ctx *pulumi.Context,
+	input *FirewallInput,
+	firewall *network.Firewall,
+	resourceGroup *core.ResourceGroup,
+	subnets map[string]*network.Subnet,
+	tags pulumi.StringMap) error {
+
+	if len(input.RouteSubnets) == 0 {
+		return nil
+	}
+
+	routeTableName := fmt.Sprintf("%s-route-table", input.Name)
+	routeTable, err := network.NewRouteTable(ctx, routeTableName, &network.RouteTableArgs{
+		Location:          resourceGroup.Location,
+		Name:              pulumi.String(routeTableName),
+		ResourceGroupName: resourceGroup.Name,
+		Routes: network.RouteTableRouteArray{
+			network.RouteTableRouteArgs{
+				AddressPrefix:      pulumi.String("0.0.0.0/0"),
+				Name:               pulumi.String(fmt.Sprintf("%s-default-route", input.Name)),
+				NextHopInIpAddress: firewall.IpConfigurations.Index(pulumi.Int(0)).PrivateIpAddress(),
+				NextHopType:        pulumi.String("VirtualAppliance"),
+			},
+		},
+		Tags: tags,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range input.RouteSubnets {
+		subnet, exists := subnets[name]
+		if !exists {
+			return pulumierr.MissingConfigErr{name, "subnet"}
+		}
+
+		associationName := fmt.Sprintf("%s-%s", routeTableName, name)
+		if _, err := network.NewSubnetRouteTableAssociation(ctx, associationName,
+			&network.SubnetRouteTableAssociationArgs{
+				RouteTableId: routeTable.ID(),
+				SubnetId:     subnet.ID(),
+			}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateFirewall ensures that the firewall is in the AzureFirewallSubnet,
+// its rule collections have valid actions, priorities and protocols, and its
+// route subnets don't have their own route tables.
+func validateFirewall(input *FirewallInput, subnetInput []*vnet.SubnetInput) error {
+	if input.Subnet != firewallSubnetName {
+		return pulumierr.InvalidConfigErr{input.Name, "firewall",
+			fmt.Sprintf("subnet must be named %s, got %s", firewallSubnetName, input.Subnet)}
+	}
+
+	if len(input.PublicIP) == 0 {
+		return pulumierr.MissingConfigErr{input.Name, "firewall public IP"}
+	}
+
+	for _, collection := range input.NetworkRuleCollections {
+		if err := validateRuleCollection(collection.Name, collection.Action, collection.Priority, knownFilterActions); err != nil {
+			return err
+		}
+
+		for _, rule := range collection.Rules {
+			if err := validateProtocols(rule.Name, rule.Protocols, knownNetworkProtocols); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, collection := range input.ApplicationRuleCollections {
+		if err := validateRuleCollection(collection.Name, collection.Action, collection.Priority, knownFilterActions); err != nil {
+			return err
+		}
+
+		for _, rule := range collection.Rules {
+			if (len(rule.FQDNTags) > 0) == (len(rule.TargetFQDNs) > 0) {
+				return pulumierr.InvalidConfigErr{rule.Name, "firewall application rule",
+					"exactly one of fqdnTags and targetFqdns must be set"}
+			}
+
+			if len(rule.TargetFQDNs) > 0 && len(rule.Protocols) == 0 {
+				return pulumierr.MissingConfigErr{rule.Name, "firewall application rule protocols"}
+			}
+
+			for _, protocol := range rule.Protocols {
+				if _, _, err := applicationProtocol(protocol); err != nil {
+					return pulumierr.InvalidConfigErr{rule.Name, "firewall application rule", err.Error()}
+				}
+			}
+		}
+	}
+
+	for _, collection := range input.NATRuleCollections {
+		if err := validateRuleCollection(collection.Name, "Dnat", collection.Priority, knownNATActions); err != nil {
+			return err
+		}
+
+		for _, rule := range collection.Rules {
+			if err := validateProtocols(rule.Name, rule.Protocols, knownNATProtocols); err != nil {
+				return err
+			}
+
+			if len(rule.TranslatedAddress) == 0 || len(rule.TranslatedPort) == 0 {
+				return pulumierr.MissingConfigErr{rule.Name, "firewall NAT rule translated address and port"}
+			}
+		}
+	}
+
+	for _, name := range input.RouteSubnets {
+		for _, subnet := range subnetInput {
+			if subnet.Name != name {
+				continue
+			}
+
+			if len(subnet.RouteTable) > 0 {
+				return pulumierr.InvalidConfigErr{input.Name, "firewall",
+					fmt.Sprintf("route subnet %s already has route table %s", name, subnet.RouteTable)}
+			}
+
+			// the route table association would be reset by the next
+			// deployment of the subnet's template
+			if subnet.TemplateDeployed() {
+				return pulumierr.InvalidConfigErr{input.Name, "firewall",
+					fmt.Sprintf("route subnet %s is deployed from a template, as it's dual-stack or has service endpoint policies", name)}
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateRuleCollection(name, action string, priority int, known map[string]bool) error {
+	if !known[action] {
+		return pulumierr.InvalidConfigErr{name, "firewall rule collection", fmt.Sprintf("unknown action %q", action)}
+	}
+
+	if priority < minPriority || priority > maxPriority {
+		return pulumierr.InvalidConfigErr{name, "firewall rule collection",
+			fmt.Sprintf("priority must be between %d and %d, got %d", minPriority, maxPriority, priority)}
+	}
+
+	return nil
+}
+
+func validateProtocols(name string, protocols []string, known map[string]bool) error {
+	if len(protocols) == 0 {
+		return pulumierr.MissingConfigErr{name, "firewall rule protocols"}
+	}
+
+	for _, protocol := range protocols {
+		if !known[protocol] {
+			return pulumierr.InvalidConfigErr{name, "firewall rule", fmt.Sprintf("unknown protocol %q", protocol)}
+		}
+	}
+
+	return nil
+}
+
+// applicationProtocol parses an application rule protocol in the
+// <type>:<port> form.
+func applicationProtocol(protocol string) (string, int, error) {
+	fields := strings.Split(protocol, ":")
+	if len(fields) != 2 || !knownApplicationProtocols[fields[0]] {
+		return "", 0, fmt.Errorf("invalid protocol %q: expected <Http|Https|Mssql>:<port>", protocol)
+	}
+
+	port, err := strconv.Atoi(fields[1])
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port in protocol %q", protocol)
+	}
+
+	return fields[0], port, nil
+}
@@ -0,0 +1,192 @@
+package firewall
+
+import (
+	"sync"
+	"testing"
+
+	vnet "github.com/ihcsim/pulumi-azure/v2/pkg/component/network"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/mock"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/test"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+func TestReconcile(t *testing.T) {
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		publicIPs, err := test.MockPublicIPs(ctx)
+		if err != nil {
+			return err
+		}
+
+		subnets, err := test.MockSubnets(ctx)
+		if err != nil {
+			return err
+		}
+
+		firewalls, err := Reconcile(ctx, cfg, publicIPs, resourceGroup, subnets, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		firewall, exists := firewalls[test.FirewallName]
+		if !exists {
+			t.Fatalf("expected firewall %s to exist", test.FirewallName)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		pulumi.All(firewall.Name, firewall.ResourceGroupName).ApplyT(func(actuals []interface{}) error {
+			defer wg.Done()
+
+			if actual := actuals[0].(string); actual != test.FirewallName {
+				t.Errorf("mismatch name. expected: %s, actual: %s", test.FirewallName, actual)
+			}
+
+			if actual := actuals[1].(string); actual != test.ResourceGroupName {
+				t.Errorf("mismatch resource group name. expected: %s, actual: %s", test.ResourceGroupName, actual)
+			}
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateFirewall(t *testing.T) {
+	subnetInput := []*vnet.SubnetInput{
+		{Name: "subnet-00"},
+		{Name: "subnet-01", RouteTable: "custom"},
+		{Name: "subnet-02", IPv6AddressPrefix: "ace:cab:deca:deed::/64"},
+		{Name: "subnet-03", ServiceEndpointPolicies: []string{"storage"}},
+	}
+
+	var testCases = []struct {
+		name     string
+		input    *FirewallInput
+		expected bool
+	}{
+		{
+			name: "valid",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "AzureFirewallSubnet", RouteSubnets: []string{"subnet-00"},
+				NetworkRuleCollections: []*NetworkRuleCollectionInput{{Action: "Allow", Name: "net", Priority: 100,
+					Rules: []*NetworkRuleInput{{Name: "dns", Protocols: []string{"UDP"}}}}},
+				ApplicationRuleCollections: []*ApplicationRuleCollectionInput{{Action: "Deny", Name: "app", Priority: 200,
+					Rules: []*ApplicationRuleInput{{Name: "updates", FQDNTags: []string{"WindowsUpdate"}}}}},
+				NATRuleCollections: []*NATRuleCollectionInput{{Name: "nat", Priority: 100,
+					Rules: []*NATRuleInput{{Name: "ssh", Protocols: []string{"TCP"}, TranslatedAddress: "10.0.0.4", TranslatedPort: "22"}}}}},
+			expected: true,
+		},
+		{
+			name:  "wrong subnet",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "subnet-00"},
+		},
+		{
+			name:  "missing public IP",
+			input: &FirewallInput{Name: "fw", Subnet: "AzureFirewallSubnet"},
+		},
+		{
+			name: "unknown action",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "AzureFirewallSubnet",
+				NetworkRuleCollections: []*NetworkRuleCollectionInput{{Action: "Permit", Name: "net", Priority: 100}}},
+		},
+		{
+			name: "dnat network rule collection",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "AzureFirewallSubnet",
+				NetworkRuleCollections: []*NetworkRuleCollectionInput{{Action: "Dnat", Name: "net", Priority: 100}}},
+		},
+		{
+			name: "dnat application rule collection",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "AzureFirewallSubnet",
+				ApplicationRuleCollections: []*ApplicationRuleCollectionInput{{Action: "Dnat", Name: "app", Priority: 200}}},
+		},
+		{
+			name: "priority out of range",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "AzureFirewallSubnet",
+				NetworkRuleCollections: []*NetworkRuleCollectionInput{{Action: "Allow", Name: "net", Priority: 99}}},
+		},
+		{
+			name: "unknown network protocol",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "AzureFirewallSubnet",
+				NetworkRuleCollections: []*NetworkRuleCollectionInput{{Action: "Allow", Name: "net", Priority: 100,
+					Rules: []*NetworkRuleInput{{Name: "dns", Protocols: []string{"SCTP"}}}}}},
+		},
+		{
+			name: "fqdn tags and target fqdns",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "AzureFirewallSubnet",
+				ApplicationRuleCollections: []*ApplicationRuleCollectionInput{{Action: "Allow", Name: "app", Priority: 200,
+					Rules: []*ApplicationRuleInput{{Name: "updates", FQDNTags: []string{"WindowsUpdate"}, TargetFQDNs: []string{"*.ubuntu.com"}}}}}},
+		},
+		{
+			name: "invalid application protocol",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "AzureFirewallSubnet",
+				ApplicationRuleCollections: []*ApplicationRuleCollectionInput{{Action: "Allow", Name: "app", Priority: 200,
+					Rules: []*ApplicationRuleInput{{Name: "updates", Protocols: []string{"Https"}, TargetFQDNs: []string{"*.ubuntu.com"}}}}}},
+		},
+		{
+			name: "missing NAT translation",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "AzureFirewallSubnet",
+				NATRuleCollections: []*NATRuleCollectionInput{{Name: "nat", Priority: 100,
+					Rules: []*NATRuleInput{{Name: "ssh", Protocols: []string{"TCP"}}}}}},
+		},
+		{
+			name:  "route subnet with route table",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "AzureFirewallSubnet", RouteSubnets: []string{"subnet-01"}},
+		},
+		{
+			name:  "dual-stack route subnet",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "AzureFirewallSubnet", RouteSubnets: []string{"subnet-02"}},
+		},
+		{
+			name:  "route subnet with service endpoint policies",
+			input: &FirewallInput{Name: "fw", PublicIP: "ip", Subnet: "AzureFirewallSubnet", RouteSubnets: []string{"subnet-03"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		if err := validateFirewall(tc.input, subnetInput); (err == nil) != tc.expected {
+			t.Errorf("mismatch validation (%s). expected valid: %t, actual error: %v", tc.name, tc.expected, err)
+		}
+	}
+}
+
+func TestApplicationProtocol(t *testing.T) {
+	var testCases = []struct {
+		protocol     string
+		expectedType string
+		expectedPort int
+	}{
+		{protocol: "Https:443", expectedType: "Https", expectedPort: 443},
+		{protocol: "Mssql:1433", expectedType: "Mssql", expectedPort: 1433},
+		{protocol: "Https"},
+		{protocol: "Ftp:21"},
+		{protocol: "Http:http"},
+	}
+
+	for _, tc := range testCases {
+		actualType, actualPort, err := applicationProtocol(tc.protocol)
+		if tc.expectedType == "" {
+			if err == nil {
+				t.Errorf("expected error (%s)", tc.protocol)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error (%s): %s", tc.protocol, err)
+		}
+
+		if actualType != tc.expectedType || actualPort != tc.expectedPort {
+			t.Errorf("mismatch protocol (%s). expected: %s:%d, actual: %s:%d", tc.protocol, tc.expectedType, tc.expectedPort, actualType, actualPort)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package loadbalancer
 
+// LoadBalancerInput describes a load balancer. A load balancer without a
+// PublicIP is internal. IPv6PublicIP adds an IPv6 frontend to a public load
+// balancer, whose backends are the IPv6 configurations of dual-stack VMs.
 type LoadBalancerInput struct {
 	BackendPort      int
 	BackendHosts     []string
 	FrontendPort     int
+	IPv6PublicIP     string `json:"ipv6PublicIP"`
 	Name             string
 	ProbePort        int
 	ProbeProtocol    string
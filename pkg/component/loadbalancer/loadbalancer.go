@@ -2,10 +2,8 @@ package loadbalancer
 
 import (
 	"fmt"
-	"strings"
 
 	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
-	"github.com/pulumi/pulumi-azure/sdk/go/azure/compute"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/lb"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
@@ -13,27 +11,55 @@ import (
 	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
 )
 
+// LoadBalancer is a load balancer created from a load balancer config entry.
+// An internal load balancer has a private frontend IP address in a subnet,
+// instead of a public IP. The instances of the VM groups in BackendHosts are
+// added to the backend pools by the compute component, along with the VMs.
+// IPv6BackendAddressPool is nil unless the load balancer has an IPv6
+// frontend.
+type LoadBalancer struct {
+	BackendAddressPool     *lb.BackendAddressPool
+	BackendHosts           []string
+	Internal               bool
+	IPv6BackendAddressPool *lb.BackendAddressPool
+	LoadBalancer           *lb.LoadBalancer
+	Name                   string
+}
+
+// Reconcile creates the load balancers of the stack, with their backend pools,
+// probes and rules. It runs before the compute component, so that the VMs of
+// a rolling update can wait for their predecessors to be back in the backend
+// pools.
 func Reconcile(
 	ctx *pulumi.Context,
 	cfg *config.Config,
 	publicIPs map[string]*network.PublicIp,
 	resourceGroup *core.ResourceGroup,
-	virtualMachines map[string]*compute.VirtualMachine,
-	tags pulumi.StringMap) (map[string]*lb.LoadBalancer, error) {
+	subnets map[string]*network.Subnet,
+	tags pulumi.StringMap) (map[string]*LoadBalancer, error) {
 
 	loadBalancerInput := []*LoadBalancerInput{}
 	if err := cfg.TryObject("loadBalancers", &loadBalancerInput); err != nil {
 		return nil, err
 	}
 
-	loadBalancers := map[string]*lb.LoadBalancer{}
+	loadBalancers := map[string]*LoadBalancer{}
 	for _, input := range loadBalancerInput {
-		frontendIPConfiguration, err := frontendIPConfiguration(input, publicIPs)
+		frontendIPConfiguration, err := frontendIPConfiguration(input, publicIPs, subnets)
 		if err != nil {
 			return nil, err
 		}
 		frontendIPConfigurations := lb.LoadBalancerFrontendIpConfigurationArray{frontendIPConfiguration}
 
+		ipv6FrontendIPConfiguration, err := ipv6FrontendIPConfiguration(input, publicIPs)
+		if err != nil {
+			return nil, err
+		}
+
+		if ipv6FrontendIPConfiguration != nil {
+			frontendIPConfigurations = append(frontendIPConfigurations, ipv6FrontendIPConfiguration)
+		}
+
 		loadBalancer, err := lb.NewLoadBalancer(ctx, input.Name,
 			&lb.LoadBalancerArgs{
 				FrontendIpConfigurations: frontendIPConfigurations,
@@ -46,32 +72,12 @@ func Reconcile(
 		if err != nil {
 			return nil, err
 		}
-		loadBalancers[input.Name] = loadBalancer
 
 		backendAddressPool, err := backendAddressPool(ctx, input, loadBalancer, resourceGroup)
 		if err != nil {
 			return nil, err
 		}
 
-		for vmName, virtualMachine := range virtualMachines {
-			for _, backendHost := range input.BackendHosts {
-				if !strings.HasPrefix(vmName, backendHost) {
-					continue
-				}
-
-				associationName := fmt.Sprintf("%s-%s-association", input.Name, vmName)
-				if _, err := network.NewNetworkInterfaceBackendAddressPoolAssociation(ctx, associationName,
-					&network.NetworkInterfaceBackendAddressPoolAssociationArgs{
-						BackendAddressPoolId: backendAddressPool.ID(),
-						IpConfigurationName:  pulumi.Sprintf("%s-primary-ipconfig", vmName),
-						NetworkInterfaceId:   pulumi.StringOutput(virtualMachine.PrimaryNetworkInterfaceId),
-					}); err != nil {
-					return nil, err
-				}
-			}
-
-		}
-
 		probe, err := probe(ctx, input, loadBalancer, resourceGroup)
 		if err != nil {
 			return nil, err
@@ -80,24 +86,126 @@ func Reconcile(
 		if _, err := rule(ctx, input, backendAddressPool, frontendIPConfiguration, loadBalancer, probe, resourceGroup); err != nil {
 			return nil, err
 		}
+
+		var ipv6BackendAddressPool *lb.BackendAddressPool
+		if ipv6FrontendIPConfiguration != nil {
+			ipv6BackendAddressPool, err = ipv6Backend(ctx, input, ipv6FrontendIPConfiguration, loadBalancer, probe, resourceGroup)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		loadBalancers[input.Name] = &LoadBalancer{
+			BackendAddressPool:     backendAddressPool,
+			BackendHosts:           input.BackendHosts,
+			Internal:               len(input.PublicIP) == 0,
+			IPv6BackendAddressPool: ipv6BackendAddressPool,
+			LoadBalancer:           loadBalancer,
+			Name:                   input.Name,
+		}
 	}
 
 	return loadBalancers, nil
 }
 
-func frontendIPConfiguration(input *LoadBalancerInput, publicIPs map[string]*network.PublicIp) (*lb.LoadBalancerFrontendIpConfigurationArgs, error) {
+// frontendIPConfiguration returns the frontend IP configuration of the load
+// balancer. Load balancers without a public IP are internal, with a dynamic
+// private IP address in their subnet.
+func frontendIPConfiguration(
+	input *LoadBalancerInput,
+	publicIPs map[string]*network.PublicIp,
+	subnets map[string]*network.Subnet) (*lb.LoadBalancerFrontendIpConfigurationArgs, error) {
+
+	frontendIPConfigurationName := fmt.Sprintf("%s-frontend-config", input.Name)
+	if len(input.PublicIP) == 0 {
+		subnet, exists := subnets[input.Subnet]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{input.Subnet, "subnet"}
+		}
+
+		return &lb.LoadBalancerFrontendIpConfigurationArgs{
+			Name:                       pulumi.String(frontendIPConfigurationName),
+			PrivateIpAddressAllocation: pulumi.String("Dynamic"),
+			SubnetId:                   subnet.ID(),
+		}, nil
+	}
+
 	publicIP, exists := publicIPs[input.PublicIP]
 	if !exists {
 		return nil, pulumierr.MissingConfigErr{input.PublicIP, "public IP"}
 	}
 
-	frontendIPConfigurationName := fmt.Sprintf("%s-frontend-config", input.Name)
 	return &lb.LoadBalancerFrontendIpConfigurationArgs{
 		Name:              pulumi.String(frontendIPConfigurationName),
 		PublicIpAddressId: publicIP.ID(),
 	}, nil
 }
 
+// ipv6FrontendIPConfiguration returns the IPv6 frontend IP configuration of
+// the load balancer, or nil if it has no IPv6 public IP. Only public load
+// balancers can have an IPv6 frontend.
+func ipv6FrontendIPConfiguration(
+	input *LoadBalancerInput,
+	publicIPs map[string]*network.PublicIp) (*lb.LoadBalancerFrontendIpConfigurationArgs, error) {
+
+	if len(input.IPv6PublicIP) == 0 {
+		return nil, nil
+	}
+
+	if len(input.PublicIP) == 0 {
+		return nil, pulumierr.InvalidConfigErr{input.Name, "load balancer", "an IPv6 frontend requires a public load balancer"}
+	}
+
+	publicIP, exists := publicIPs[input.IPv6PublicIP]
+	if !exists {
+		return nil, pulumierr.MissingConfigErr{input.IPv6PublicIP, "public IP"}
+	}
+
+	return &lb.LoadBalancerFrontendIpConfigurationArgs{
+		Name:              pulumi.String(fmt.Sprintf("%s-ipv6-frontend-config", input.Name)),
+		PublicIpAddressId: publicIP.ID(),
+	}, nil
+}
+
+// ipv6Backend creates the IPv6 backend pool of the load balancer, for the
+// IPv6 configurations of its backend hosts, and its IPv6 rule.
+func ipv6Backend(
+	ctx *pulumi.Context,
+	input *LoadBalancerInput,
+	frontendIPConfiguration *lb.LoadBalancerFrontendIpConfigurationArgs,
+	loadBalancer *lb.LoadBalancer,
+	probe *lb.Probe,
+	resourceGroup *core.ResourceGroup) (*lb.BackendAddressPool, error) {
+
+	backendAddressPoolName := fmt.Sprintf("%s-ipv6-backend-pool", input.Name)
+	backendAddressPool, err := lb.NewBackendAddressPool(ctx, backendAddressPoolName, &lb.BackendAddressPoolArgs{
+		LoadbalancerId:    loadBalancer.ID(),
+		Name:              pulumi.String(backendAddressPoolName),
+		ResourceGroupName: resourceGroup.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ruleName := fmt.Sprintf("%s-rule-web-ipv6", input.Name)
+	_, err = lb.NewRule(ctx, ruleName, &lb.RuleArgs{
+		BackendAddressPoolId:        backendAddressPool.ID(),
+		BackendPort:                 pulumi.Int(input.BackendPort),
+		FrontendIpConfigurationName: frontendIPConfiguration.Name,
+		FrontendPort:                pulumi.Int(input.FrontendPort),
+		LoadbalancerId:              loadBalancer.ID(),
+		Name:                        pulumi.String(ruleName),
+		ProbeId:                     probe.ID(),
+		Protocol:                    pulumi.String(input.Protocol),
+		ResourceGroupName:           resourceGroup.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return backendAddressPool, nil
+}
+
 func backendAddressPool(
 	ctx *pulumi.Context,
 	input *LoadBalancerInput,
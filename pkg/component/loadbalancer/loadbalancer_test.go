@@ -0,0 +1,172 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ihcsim/pulumi-azure/v2/pkg/mock"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/test"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/lb"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+func TestReconcile(t *testing.T) {
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		publicIPs, err := mockPublicIPs(ctx, resourceGroup)
+		if err != nil {
+			return err
+		}
+
+		loadBalancers, err := Reconcile(ctx, cfg, publicIPs, resourceGroup, nil, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		loadBalancer, exists := loadBalancers[test.LoadBalancerName]
+		if !exists {
+			t.Fatalf("missing load balancer: %s", test.LoadBalancerName)
+		}
+
+		if loadBalancer.Internal {
+			t.Errorf("expected load balancer %s to be public", test.LoadBalancerName)
+		}
+
+		if actual := loadBalancer.BackendHosts; len(actual) != 1 || actual[0] != test.VirtualMachineName {
+			t.Errorf("mismatch backend hosts. expected: [%s], actual: %v", test.VirtualMachineName, actual)
+		}
+
+		if loadBalancer.IPv6BackendAddressPool == nil {
+			t.Fatalf("missing IPv6 backend pool of load balancer %s", test.LoadBalancerName)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		pulumi.All(
+			loadBalancer.LoadBalancer.FrontendIpConfigurations,
+			loadBalancer.BackendAddressPool.Name,
+			loadBalancer.IPv6BackendAddressPool.Name).ApplyT(func(actuals []interface{}) error {
+			defer wg.Done()
+
+			frontendIPConfigurations := actuals[0].([]lb.LoadBalancerFrontendIpConfiguration)
+			expectedFrontends := []string{
+				fmt.Sprintf("%s-frontend-config", test.LoadBalancerName),
+				fmt.Sprintf("%s-ipv6-frontend-config", test.LoadBalancerName),
+			}
+			if len(frontendIPConfigurations) != len(expectedFrontends) {
+				t.Fatalf("mismatch frontend IP configurations count. expected: %d, actual: %d", len(expectedFrontends), len(frontendIPConfigurations))
+			}
+
+			for i, expected := range expectedFrontends {
+				if actual := frontendIPConfigurations[i].Name; actual != expected {
+					t.Errorf("mismatch frontend IP configuration name. expected: %s, actual: %s", expected, actual)
+				}
+			}
+
+			if expected, actual := fmt.Sprintf("%s-backend-pool", test.LoadBalancerName), actuals[1].(string); actual != expected {
+				t.Errorf("mismatch backend pool name. expected: %s, actual: %s", expected, actual)
+			}
+
+			if expected, actual := fmt.Sprintf("%s-ipv6-backend-pool", test.LoadBalancerName), actuals[2].(string); actual != expected {
+				t.Errorf("mismatch IPv6 backend pool name. expected: %s, actual: %s", expected, actual)
+			}
+
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIPv6FrontendIPConfiguration(t *testing.T) {
+	var testCases = []struct {
+		name           string
+		input          *LoadBalancerInput
+		expectedConfig bool
+		expectedErr    bool
+	}{
+		{
+			name:  "IPv4 only",
+			input: &LoadBalancerInput{Name: test.LoadBalancerName, PublicIP: test.PublicIPName},
+		},
+		{
+			name:           "dual-stack",
+			input:          &LoadBalancerInput{IPv6PublicIP: test.LoadBalancerIPv6PublicIPName, Name: test.LoadBalancerName, PublicIP: test.PublicIPName},
+			expectedConfig: true,
+		},
+		{
+			name:        "internal",
+			input:       &LoadBalancerInput{IPv6PublicIP: test.LoadBalancerIPv6PublicIPName, Name: test.LoadBalancerName},
+			expectedErr: true,
+		},
+		{
+			name:        "missing IPv6 public IP",
+			input:       &LoadBalancerInput{IPv6PublicIP: "missing", Name: test.LoadBalancerName, PublicIP: test.PublicIPName},
+			expectedErr: true,
+		},
+	}
+
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		publicIPs, err := mockPublicIPs(ctx, resourceGroup)
+		if err != nil {
+			return err
+		}
+
+		for _, tc := range testCases {
+			actual, err := ipv6FrontendIPConfiguration(tc.input, publicIPs)
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("mismatch error (%s). expected error: %t, actual: %v", tc.name, tc.expectedErr, err)
+				continue
+			}
+
+			if (actual != nil) != tc.expectedConfig {
+				t.Errorf("mismatch IPv6 frontend IP configuration (%s). expected: %t, actual: %v", tc.name, tc.expectedConfig, actual)
+			}
+		}
+
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
+
+func mockPublicIPs(ctx *pulumi.Context, resourceGroup *core.ResourceGroup) (map[string]*network.PublicIp, error) {
+	publicIPs := map[string]*network.PublicIp{}
+	for name, version := range map[string]string{
+		test.PublicIPName:                 "IPv4",
+		test.LoadBalancerIPv6PublicIPName: "IPv6",
+	} {
+		publicIP, err := network.NewPublicIp(ctx, name, &network.PublicIpArgs{
+			AllocationMethod:  pulumi.String("Static"),
+			IpVersion:         pulumi.String(version),
+			Location:          resourceGroup.Location,
+			Name:              pulumi.String(name),
+			ResourceGroupName: resourceGroup.Name,
+			Sku:               pulumi.String("Standard"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		publicIPs[name] = publicIP
+	}
+
+	return publicIPs, nil
+}
@@ -0,0 +1,253 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/stackref"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+const (
+	carvedAddressPrefixesOutput = "carvedSubnetAddressPrefixes"
+
+	// reservedAddresses is the number of addresses Azure reserves in every
+	// subnet.
+	reservedAddresses = 5
+
+	minPrefixLength = 8
+	maxPrefixLength = 29
+)
+
+// carvedAddressPrefixes returns the address prefixes of the subnets with a
+// size instead of an address prefix, keyed by subnet names. The prefixes are
+// carved from the CIDRs of their virtual networks, and are exported as a
+// stack output. On subsequent updates, the exported prefixes are read back
+// from the previous outputs of the stack, so that a subnet keeps its prefix as
+// long as its size doesn't change, and subnets can be added without moving
+// the existing ones.
+func carvedAddressPrefixes(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	allSubnets map[string]*SubnetInput,
+	virtualNetworkInput []*VirtualNetworkInput) (pulumi.StringMapOutput, error) {
+
+	carved := false
+	for _, input := range allSubnets {
+		if len(input.Size) > 0 {
+			if len(input.AddressPrefix) > 0 {
+				return pulumi.StringMapOutput{}, pulumierr.InvalidConfigErr{input.Name, "subnet",
+					"address prefix and size are mutually exclusive"}
+			}
+
+			if _, err := prefixLength(input.Size); err != nil {
+				return pulumi.StringMapOutput{}, pulumierr.InvalidConfigErr{input.Name, "subnet", err.Error()}
+			}
+			carved = true
+		}
+	}
+
+	if !carved {
+		return pulumi.StringMap{}.ToStringMapOutput(), nil
+	}
+
+	previous, err := stackref.Previous(ctx, cfg, ctx.Stack()+"-previous-subnets")
+	if err != nil {
+		return pulumi.StringMapOutput{}, err
+	}
+
+	prefixes := previous.Outputs.ApplyT(func(outputs map[string]interface{}) (map[string]string, error) {
+		previousPrefixes, err := previousAddressPrefixes(outputs)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes := map[string]string{}
+		for _, networkInput := range virtualNetworkInput {
+			if networkInput.Existing != nil {
+				continue
+			}
+
+			subnets := []*SubnetInput{}
+			for _, name := range networkInput.Subnets {
+				if input, exists := allSubnets[name]; exists {
+					subnets = append(subnets, input)
+				}
+			}
+
+			carved, err := carve(networkInput.CIDR, subnets, previousPrefixes)
+			if err != nil {
+				return nil, err
+			}
+
+			for name, prefix := range carved {
+				prefixes[name] = prefix
+			}
+		}
+
+		return prefixes, nil
+	}).(pulumi.StringMapOutput)
+
+	ctx.Export(carvedAddressPrefixesOutput, prefixes)
+	return prefixes, nil
+}
+
+// previousAddressPrefixes returns the carved address prefixes exported by the
+// previous update of the stack. It fails if they can't be read, instead of
+// moving the subnets.
+func previousAddressPrefixes(outputs map[string]interface{}) (map[string]string, error) {
+	prefixes := map[string]string{}
+	exported, exists := outputs[carvedAddressPrefixesOutput]
+	if !exists {
+		return prefixes, nil
+	}
+
+	exportedPrefixes, ok := exported.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("malformed %s stack output: %v", carvedAddressPrefixesOutput, exported)
+	}
+
+	for name, prefix := range exportedPrefixes {
+		s, ok := prefix.(string)
+		if !ok {
+			return nil, fmt.Errorf("malformed %s stack output of subnet %s: %v", carvedAddressPrefixesOutput, name, prefix)
+		}
+		prefixes[name] = s
+	}
+
+	return prefixes, nil
+}
+
+// carve allocates address prefixes from cidr to the subnets with a size.
+// Previous prefixes are kept if they still fit. New prefixes are allocated
+// largest first, then by name, at the lowest free addresses.
+func carve(cidr string, subnets []*SubnetInput, previous map[string]string) (map[string]string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	if network.IP.To4() == nil {
+		return nil, fmt.Errorf("subnets can only be carved from IPv4 address spaces: %s", cidr)
+	}
+
+	taken := []*net.IPNet{}
+	for _, input := range subnets {
+		if len(input.AddressPrefix) > 0 {
+			_, prefix, err := net.ParseCIDR(input.AddressPrefix)
+			if err != nil {
+				return nil, err
+			}
+			taken = append(taken, prefix)
+		}
+	}
+
+	var (
+		prefixes = map[string]string{}
+		pending  = []*SubnetInput{}
+		lengths  = map[string]int{}
+	)
+	for _, input := range subnets {
+		if len(input.Size) == 0 {
+			continue
+		}
+
+		length, err := prefixLength(input.Size)
+		if err != nil {
+			return nil, pulumierr.InvalidConfigErr{input.Name, "subnet", err.Error()}
+		}
+		lengths[input.Name] = length
+
+		if _, prefix, err := net.ParseCIDR(previous[input.Name]); err == nil {
+			ones, _ := prefix.Mask.Size()
+			if ones == length && network.Contains(prefix.IP) && !overlapsAny(prefix, taken) {
+				prefixes[input.Name] = prefix.String()
+				taken = append(taken, prefix)
+				continue
+			}
+		}
+
+		pending = append(pending, input)
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		if lengths[pending[i].Name] != lengths[pending[j].Name] {
+			return lengths[pending[i].Name] < lengths[pending[j].Name]
+		}
+		return pending[i].Name < pending[j].Name
+	})
+
+	var (
+		networkLength, _ = network.Mask.Size()
+		start            = binary.BigEndian.Uint32(network.IP.To4())
+		end              = start + uint32(1)<<uint(32-networkLength)
+	)
+	for _, input := range pending {
+		var (
+			length    = lengths[input.Name]
+			blockSize = uint32(1) << uint(32-length)
+			allocated = false
+		)
+
+		for address := start; length >= networkLength && address+blockSize <= end && address >= start; address += blockSize {
+			ip := make(net.IP, net.IPv4len)
+			binary.BigEndian.PutUint32(ip, address)
+
+			prefix := &net.IPNet{IP: ip, Mask: net.CIDRMask(length, 32)}
+			if !overlapsAny(prefix, taken) {
+				prefixes[input.Name] = prefix.String()
+				taken = append(taken, prefix)
+				allocated = true
+				break
+			}
+		}
+
+		if !allocated {
+			return nil, pulumierr.InvalidConfigErr{input.Name, "subnet",
+				fmt.Sprintf("no free /%d address prefix in %s", length, cidr)}
+		}
+	}
+
+	return prefixes, nil
+}
+
+// prefixLength returns the prefix length of a subnet size, which is either a
+// prefix length such as /24, or the number of hosts the subnet must fit.
+func prefixLength(size string) (int, error) {
+	if strings.HasPrefix(size, "/") {
+		length, err := strconv.Atoi(strings.TrimPrefix(size, "/"))
+		if err != nil || length < minPrefixLength || length > maxPrefixLength {
+			return 0, fmt.Errorf("invalid subnet size %q: prefix length must be between /%d and /%d", size, minPrefixLength, maxPrefixLength)
+		}
+		return length, nil
+	}
+
+	hosts, err := strconv.Atoi(size)
+	if err != nil || hosts < 1 {
+		return 0, fmt.Errorf("invalid subnet size %q: expected a prefix length such as /24 or a host count", size)
+	}
+
+	for length := maxPrefixLength; length >= minPrefixLength; length-- {
+		if 1<<uint(32-length)-reservedAddresses >= hosts {
+			return length, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid subnet size %q: too many hosts", size)
+}
+
+func overlapsAny(prefix *net.IPNet, others []*net.IPNet) bool {
+	for _, other := range others {
+		if prefix.Contains(other.IP) || other.Contains(prefix.IP) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,32 +1,143 @@
 package network
 
+import "github.com/ihcsim/pulumi-azure/v2/pkg/existing"
+
+// NATGatewayInput describes a NAT gateway providing outbound connectivity to
+// the subnets that reference it. PublicIPs and PublicIPPrefixes refer to the
+// Standard SKU public IPs and prefixes of the stack.
+type NATGatewayInput struct {
+	IdleTimeoutMinutes int
+	Name               string
+	PublicIPPrefixes   []string `json:"publicIPPrefixes"`
+	PublicIPs          []string `json:"publicIPs"`
+	Zones              []string
+}
+
 type NetworkSecurityGroupInput struct {
+	Existing      *existing.Input
 	Name          string
 	SecurityRules []string
 }
 
+// NetworkSecurityRuleInput describes a security rule. Each side of the rule
+// is set with one of an address prefix, a list of address prefixes or a list
+// of application security groups. Ports are set with either a port range or
+// a list of port ranges. Service sets the protocol and destination ports
+// from a named service, and Rule sets the whole rule from a compact rule
+// string. Rules without a priority are assigned one.
 type NetworkSecurityRuleInput struct {
 	Access                       string
 	Description                  string
 	DestinationAddressPrefix     string
+	DestinationAddressPrefixes   []string
 	DestinationAppSecurityGroups []string
+	DestinationPortRange         string
 	DestinationPortRanges        []string
 	Direction                    string
 	Name                         string
 	Priority                     int
 	Protocol                     string
+	Rule                         string
+	Service                      string
 	SourceAddressPrefix          string
+	SourceAddressPrefixes        []string
+	SourceAppSecurityGroups      []string
 	SourcePortRange              string
+	SourcePortRanges             []string
+}
+
+// RouteInput describes a user-defined route. NextHopIPAddress is required
+// when NextHopType is VirtualAppliance, and not allowed otherwise. A
+// NextHopType of None drops the traffic.
+type RouteInput struct {
+	AddressPrefix    string
+	Name             string
+	NextHopIPAddress string `json:"nextHopIPAddress"`
+	NextHopType      string
 }
 
+// RouteTableInput describes a route table which subnets can reference.
+// DisableBGPRoutePropagation stops routes learned by BGP from being
+// propagated to the subnets.
+type RouteTableInput struct {
+	DisableBGPRoutePropagation bool `json:"disableBGPRoutePropagation"`
+	Name                       string
+	Routes                     []*RouteInput
+}
+
+// ServiceEndpointPolicyInput describes a service endpoint policy, which limits
+// the resources that can be reached through the service endpoints of the
+// subnets it's applied to.
+type ServiceEndpointPolicyInput struct {
+	Definitions []*ServiceEndpointPolicyDefinitionInput
+	Name        string
+}
+
+// ServiceEndpointPolicyDefinitionInput allows the ServiceResources of a
+// service, such as Microsoft.Storage. A service resource is the ID of a
+// resource, resource group or subscription.
+type ServiceEndpointPolicyDefinitionInput struct {
+	Description      string
+	Name             string
+	Service          string
+	ServiceResources []string
+}
+
+// SubnetInput describes a subnet. The EnforcePrivateLink flags disable the
+// network policies of the private link endpoints or private link services in
+// the subnet, and are mutually exclusive. ServiceEndpointPolicies are names of
+// service endpoint policies, which require the matching service endpoints. A
+// subnet with an IPv6AddressPrefix is dual-stack. Instead of an AddressPrefix, a Size such as /24, or a number
+// of hosts such as 100, carves the IPv4 address prefix from the CIDR of the
+// subnet's virtual network. An Existing subnet is read from an Existing
+// virtual network, and its properties aren't managed.
 type SubnetInput struct {
-	AddressPrefix string
-	Name          string
-	SecurityGroup string
+	AddressPrefix                             string
+	Delegations                               []*SubnetDelegationInput
+	EnforcePrivateLinkEndpointNetworkPolicies bool
+	EnforcePrivateLinkServiceNetworkPolicies  bool
+	Existing                                  *existing.Input
+	IPv6AddressPrefix                         string `json:"ipv6AddressPrefix"`
+	Name                                      string
+	NATGateway                                string `json:"natGateway"`
+	RouteTable                                string
+	SecurityGroup                             string
+	ServiceEndpointPolicies                   []string
+	ServiceEndpoints                          []string
+	Size                                      string
 }
 
-type VirtualNetworkInput struct {
-	CIDR    string
+// SubnetDelegationInput delegates a subnet to an Azure service, such as
+// Microsoft.Web/serverFarms, with the actions the service may perform.
+type SubnetDelegationInput struct {
+	Actions []string
 	Name    string
-	Subnets []string
+	Service string
+}
+
+// VirtualNetworkInput describes a virtual network. CIDR is its primary
+// address space, and AddressSpaces are any additional ones, such as an IPv6
+// address space for dual-stack subnets. The address spaces of an Existing
+// virtual network are only used for validation, and default to its address
+// spaces in Azure.
+type VirtualNetworkInput struct {
+	AddressSpaces []string
+	CIDR          string
+	Existing      *existing.Input
+	Name          string
+	Subnets       []string
+}
+
+// VirtualNetworkPeeringInput describes the peering of two virtual networks,
+// which is created in both directions. In a hub-spoke topology, the hub is
+// the local network; AllowGatewayTransit lets the remote network use the
+// gateways of the local network, and UseRemoteGateways makes the remote
+// network use them.
+type VirtualNetworkPeeringInput struct {
+	AllowForwardedTraffic bool
+	AllowGatewayTransit   bool
+	LocalVirtualNetwork   string
+	Name                  string
+	RemoteVirtualNetwork  string
+	UseRemoteGateways     bool
 }
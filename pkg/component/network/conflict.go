@@ -0,0 +1,330 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+// SecurityGroupConflicts returns the contradictions between the rules of the
+// network security groups a and b, e.g. the ones of a subnet and of a network
+// interface in it. Traffic must be allowed by both groups, so a rule that
+// allows traffic which the other group denies has no effect. Rules are
+// compared by the effective access of their groups: a rule doesn't conflict
+// over traffic that a rule of its group with a higher precedence decides.
+// Existing network security groups have no known rules, and never conflict.
+func SecurityGroupConflicts(
+	cfg *config.Config,
+	appSecGroups map[string]*network.ApplicationSecurityGroup,
+	a, b string) ([]string, error) {
+
+	netSecRulesInput, err := securityRuleInput(cfg, appSecGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := map[string]*NetworkSecurityRuleInput{}
+	for _, input := range netSecRulesInput {
+		rules[input.Name] = input
+	}
+
+	netSecGroupInput := []*NetworkSecurityGroupInput{}
+	if err := cfg.TryObject("networkSecurityGroups", &netSecGroupInput); err != nil {
+		return nil, err
+	}
+
+	groups := map[string]*NetworkSecurityGroupInput{}
+	for _, input := range netSecGroupInput {
+		groups[input.Name] = input
+	}
+
+	groupRules := func(name string) ([]*NetworkSecurityRuleInput, error) {
+		group, exists := groups[name]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{name, "network security group"}
+		}
+
+		inputs := []*NetworkSecurityRuleInput{}
+		for _, rule := range group.SecurityRules {
+			input, exists := rules[rule]
+			if !exists {
+				return nil, pulumierr.MissingConfigErr{rule, "network security rule"}
+			}
+			inputs = append(inputs, input)
+		}
+
+		return inputs, nil
+	}
+
+	rulesA, err := groupRules(a)
+	if err != nil {
+		return nil, err
+	}
+
+	rulesB, err := groupRules(b)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []string{}
+	for _, ruleA := range rulesA {
+		for _, ruleB := range rulesB {
+			if rulesConflict(ruleA, ruleB) && !shadowed(ruleA, rulesA, ruleB) && !shadowed(ruleB, rulesB, ruleA) {
+				conflicts = append(conflicts, fmt.Sprintf("%s rule %s of network security group %s contradicts %s rule %s of network security group %s",
+					strings.ToLower(ruleA.Access), ruleA.Name, a, strings.ToLower(ruleB.Access), ruleB.Name, b))
+			}
+		}
+	}
+
+	return conflicts, nil
+}
+
+// rulesConflict returns true if the rules a and b have opposite access, and
+// match some of the same traffic.
+func rulesConflict(a, b *NetworkSecurityRuleInput) bool {
+	if !strings.EqualFold(a.Direction, b.Direction) || strings.EqualFold(a.Access, b.Access) {
+		return false
+	}
+
+	if a.Protocol != "*" && b.Protocol != "*" && !strings.EqualFold(a.Protocol, b.Protocol) {
+		return false
+	}
+
+	return portRangesOverlap(ruleValues(a.DestinationPortRange, a.DestinationPortRanges), ruleValues(b.DestinationPortRange, b.DestinationPortRanges)) &&
+		portRangesOverlap(ruleValues(a.SourcePortRange, a.SourcePortRanges), ruleValues(b.SourcePortRange, b.SourcePortRanges)) &&
+		endpointsOverlap(a.SourceAddressPrefix, a.SourceAddressPrefixes, a.SourceAppSecurityGroups, b.SourceAddressPrefix, b.SourceAddressPrefixes, b.SourceAppSecurityGroups) &&
+		endpointsOverlap(a.DestinationAddressPrefix, a.DestinationAddressPrefixes, a.DestinationAppSecurityGroups, b.DestinationAddressPrefix, b.DestinationAddressPrefixes, b.DestinationAppSecurityGroups)
+}
+
+// shadowed returns true if a rule of group with a higher precedence than rule,
+// i.e. a lower priority, matches all the traffic that both rule and other
+// match. The access of the group for that traffic is then decided by the
+// higher precedence rule, instead of rule.
+func shadowed(rule *NetworkSecurityRuleInput, group []*NetworkSecurityRuleInput, other *NetworkSecurityRuleInput) bool {
+	for _, higher := range group {
+		if higher == rule || higher.Priority >= rule.Priority || !strings.EqualFold(higher.Direction, rule.Direction) {
+			continue
+		}
+
+		if coversIntersection(higher, rule, other) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// coversIntersection returns true if the rule h matches all the traffic that
+// both a and b match. Each property of h must match either all the values of
+// a or all those of b, so intersections that h only covers by combining
+// values of both aren't detected.
+func coversIntersection(h, a, b *NetworkSecurityRuleInput) bool {
+	covers := func(contains func(h, x *NetworkSecurityRuleInput) bool) bool {
+		return contains(h, a) || contains(h, b)
+	}
+
+	return covers(func(h, x *NetworkSecurityRuleInput) bool {
+		return h.Protocol == "*" || strings.EqualFold(h.Protocol, x.Protocol)
+	}) && covers(func(h, x *NetworkSecurityRuleInput) bool {
+		return portRangesContain(ruleValues(h.DestinationPortRange, h.DestinationPortRanges), ruleValues(x.DestinationPortRange, x.DestinationPortRanges))
+	}) && covers(func(h, x *NetworkSecurityRuleInput) bool {
+		return portRangesContain(ruleValues(h.SourcePortRange, h.SourcePortRanges), ruleValues(x.SourcePortRange, x.SourcePortRanges))
+	}) && covers(func(h, x *NetworkSecurityRuleInput) bool {
+		return endpointsContain(ruleValues(h.SourceAddressPrefix, h.SourceAddressPrefixes), h.SourceAppSecurityGroups,
+			ruleValues(x.SourceAddressPrefix, x.SourceAddressPrefixes), x.SourceAppSecurityGroups)
+	}) && covers(func(h, x *NetworkSecurityRuleInput) bool {
+		return endpointsContain(ruleValues(h.DestinationAddressPrefix, h.DestinationAddressPrefixes), h.DestinationAppSecurityGroups,
+			ruleValues(x.DestinationAddressPrefix, x.DestinationAddressPrefixes), x.DestinationAppSecurityGroups)
+	})
+}
+
+// ruleValues returns all the values of a rule property that is set with either
+// a single value or a list of values.
+func ruleValues(value string, values []string) []string {
+	all := []string{}
+	if len(value) > 0 {
+		all = append(all, value)
+	}
+	return append(all, values...)
+}
+
+// portRangesOverlap returns true if a port range of a overlaps a port range of
+// b. Unset or unparsable port ranges match any port.
+func portRangesOverlap(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+
+	for _, rangeA := range a {
+		lowA, highA, okA := parsePortRange(rangeA)
+		for _, rangeB := range b {
+			lowB, highB, okB := parsePortRange(rangeB)
+			if !okA || !okB || (lowA <= highB && lowB <= highA) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// portRangesContain returns true if every port range of b is within a port
+// range of a. Unset port ranges match any port.
+func portRangesContain(a, b []string) bool {
+	if len(a) == 0 {
+		return true
+	}
+
+	if len(b) == 0 {
+		b = []string{"*"}
+	}
+
+	for _, rangeB := range b {
+		lowB, highB, ok := parsePortRange(rangeB)
+		if !ok {
+			return false
+		}
+
+		contained := false
+		for _, rangeA := range a {
+			if lowA, highA, ok := parsePortRange(rangeA); ok && lowA <= lowB && highB <= highA {
+				contained = true
+				break
+			}
+		}
+
+		if !contained {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parsePortRange returns the bounds of a port range such as "*", "80" or
+// "8000-8080".
+func parsePortRange(portRange string) (int, int, bool) {
+	if portRange == "*" {
+		return 0, 65535, true
+	}
+
+	bounds := strings.SplitN(portRange, "-", 2)
+	low, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if len(bounds) == 1 {
+		return low, low, true
+	}
+
+	high, err := strconv.Atoi(bounds[1])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return low, high, true
+}
+
+// endpointsOverlap returns true if the endpoints a and b of two rules share an
+// address prefix or a service tag, or if either of them matches any address.
+// Address prefixes overlap if their CIDRs do. The members of application
+// security groups are only known at runtime, so an endpoint with an
+// application security group possibly overlaps any other endpoint.
+func endpointsOverlap(prefixA string, prefixesA, appSecGroupsA []string, prefixB string, prefixesB, appSecGroupsB []string) bool {
+	var (
+		a = ruleValues(prefixA, prefixesA)
+		b = ruleValues(prefixB, prefixesB)
+	)
+
+	if len(a)+len(appSecGroupsA) == 0 || len(b)+len(appSecGroupsB) == 0 ||
+		len(appSecGroupsA) > 0 || len(appSecGroupsB) > 0 {
+		return true
+	}
+
+	for _, endpointA := range a {
+		for _, endpointB := range b {
+			if endpointA == "*" || endpointB == "*" || endpointA == endpointB {
+				return true
+			}
+
+			if overlap, err := cidrsOverlap(endpointA, endpointB); err == nil && overlap {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// endpointsContain returns true if the endpoint a of a rule matches all the
+// addresses of the endpoint b of another rule. Every address prefix of b must
+// be within an address prefix of a, and every application security group of b
+// must be one of a.
+func endpointsContain(prefixesA, appSecGroupsA, prefixesB, appSecGroupsB []string) bool {
+	if len(prefixesA)+len(appSecGroupsA) == 0 {
+		return true
+	}
+
+	for _, prefix := range prefixesA {
+		if prefix == "*" {
+			return true
+		}
+	}
+
+	if len(prefixesB)+len(appSecGroupsB) == 0 {
+		return false
+	}
+
+	for _, prefixB := range prefixesB {
+		contained := false
+		for _, prefixA := range prefixesA {
+			if prefixA == prefixB || cidrContains(prefixA, prefixB) {
+				contained = true
+				break
+			}
+		}
+
+		if !contained {
+			return false
+		}
+	}
+
+	for _, appSecGroupB := range appSecGroupsB {
+		contained := false
+		for _, appSecGroupA := range appSecGroupsA {
+			if appSecGroupA == appSecGroupB {
+				contained = true
+				break
+			}
+		}
+
+		if !contained {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cidrContains returns true if the CIDR block a contains the CIDR block b.
+func cidrContains(a, b string) bool {
+	_, networkA, err := net.ParseCIDR(a)
+	if err != nil {
+		return false
+	}
+
+	_, networkB, err := net.ParseCIDR(b)
+	if err != nil {
+		return false
+	}
+
+	onesA, _ := networkA.Mask.Size()
+	onesB, _ := networkB.Mask.Size()
+	return onesA <= onesB && networkA.Contains(networkB.IP)
+}
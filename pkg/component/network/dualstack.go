@@ -0,0 +1,224 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+)
+
+// subnetTemplate is the ARM template of a subnet with IPv4 and IPv6 address
+// prefixes, or with service endpoint policies. The SDK's subnet resource only
+// supports one address prefix, and can't reference service endpoint policies,
+// so these subnets are created as template deployments. As the template
+// replaces the whole subnet, its security group, route table and NAT gateway
+// are set in the template instead of with association resources.
+const subnetTemplate = `{
+  "$schema": "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
+  "contentVersion": "1.0.0.0",
+  "parameters": {
+    "addressPrefixes": {"type": "array"},
+    "delegations": {"type": "array"},
+    "natGatewayId": {"type": "string"},
+    "networkSecurityGroupId": {"type": "string"},
+    "privateEndpointNetworkPolicies": {"type": "string"},
+    "privateLinkServiceNetworkPolicies": {"type": "string"},
+    "routeTableId": {"type": "string"},
+    "serviceEndpointPolicies": {"type": "array"},
+    "serviceEndpoints": {"type": "array"},
+    "subnetName": {"type": "string"},
+    "virtualNetworkName": {"type": "string"}
+  },
+  "resources": [{
+    "type": "Microsoft.Network/virtualNetworks/subnets",
+    "apiVersion": "2019-11-01",
+    "name": "[concat(parameters('virtualNetworkName'), '/', parameters('subnetName'))]",
+    "properties": {
+      "addressPrefixes": "[parameters('addressPrefixes')]",
+      "delegations": "[parameters('delegations')]",
+      "natGateway": "[if(empty(parameters('natGatewayId')), json('null'), createObject('id', parameters('natGatewayId')))]",
+      "networkSecurityGroup": "[if(empty(parameters('networkSecurityGroupId')), json('null'), createObject('id', parameters('networkSecurityGroupId')))]",
+      "privateEndpointNetworkPolicies": "[parameters('privateEndpointNetworkPolicies')]",
+      "privateLinkServiceNetworkPolicies": "[parameters('privateLinkServiceNetworkPolicies')]",
+      "routeTable": "[if(empty(parameters('routeTableId')), json('null'), createObject('id', parameters('routeTableId')))]",
+      "serviceEndpointPolicies": "[parameters('serviceEndpointPolicies')]",
+      "serviceEndpoints": "[parameters('serviceEndpoints')]"
+    }
+  }],
+  "outputs": {
+    "subnetId": {
+      "type": "string",
+      "value": "[resourceId('Microsoft.Network/virtualNetworks/subnets', parameters('virtualNetworkName'), parameters('subnetName'))]"
+    }
+  }
+}`
+
+// TemplateDeployed returns true if the subnet is deployed from an ARM
+// template, because it's dual-stack or has service endpoint policies. Its
+// network security group, NAT gateway and route table are part of the
+// template, so associating others with it doesn't survive redeployments.
+func (s *SubnetInput) TemplateDeployed() bool {
+	return len(s.IPv6AddressPrefix) > 0 || len(s.ServiceEndpointPolicies) > 0
+}
+
+// templateSubnet deploys the dual-stack subnet, or the subnet with service
+// endpoint policies, described by input, and reads it back as a subnet
+// resource. The template is deployed to the resource group of the virtual
+// network, which differs from the stack's resource group for an existing
+// virtual network. The read uses its own logical name, so that it doesn't take over
+// the URN of a subnet that was previously managed by this program. Such a
+// subnet, and its associations, must be removed from the stack's state
+// before it's converted, or they are deleted after the template deployment.
+func templateSubnet(
+	ctx *pulumi.Context,
+	input *SubnetInput,
+	addressPrefix pulumi.StringInput,
+	natGateway pulumi.StringInput,
+	networkSecurityGroup pulumi.StringInput,
+	routeTable pulumi.StringInput,
+	serviceEndpointPolicies pulumi.StringArrayInput,
+	virtualNetwork *network.VirtualNetwork) (*network.Subnet, error) {
+
+	// validate the service endpoints, delegations and network policies
+	if _, err := subnetArgs(input, addressPrefix, virtualNetwork, virtualNetwork.ResourceGroupName); err != nil {
+		return nil, err
+	}
+
+	parameters := pulumi.All(addressPrefix, natGateway, networkSecurityGroup, routeTable, serviceEndpointPolicies, virtualNetwork.Name).ApplyT(
+		func(args []interface{}) (string, error) {
+			return templateSubnetParameters(input, args[0].(string), args[1].(string), args[2].(string), args[3].(string), args[4].([]string), args[5].(string))
+		}).(pulumi.StringOutput)
+
+	deploymentName := fmt.Sprintf("%s-subnet", input.Name)
+	if len(input.IPv6AddressPrefix) > 0 {
+		deploymentName = fmt.Sprintf("%s-dual-stack", input.Name)
+	}
+	deployment, err := core.NewTemplateDeployment(ctx, deploymentName, &core.TemplateDeploymentArgs{
+		DeploymentMode:    pulumi.String("Incremental"),
+		Name:              pulumi.String(deploymentName),
+		ParametersBody:    parameters,
+		ResourceGroupName: virtualNetwork.ResourceGroupName,
+		TemplateBody:      pulumi.String(subnetTemplate),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	subnetID := deployment.Outputs.ApplyT(func(outputs map[string]string) pulumi.ID {
+		return pulumi.ID(outputs["subnetId"])
+	}).(pulumi.IDOutput)
+
+	return network.GetSubnet(ctx, fmt.Sprintf("%s-template", input.Name), subnetID, nil)
+}
+
+// templateSubnetParameters returns the parameters of the subnet template
+// deployment.
+func templateSubnetParameters(
+	input *SubnetInput,
+	addressPrefix string,
+	natGatewayID string,
+	networkSecurityGroupID string,
+	routeTableID string,
+	serviceEndpointPolicyIDs []string,
+	virtualNetworkName string) (string, error) {
+
+	addressPrefixes := []string{addressPrefix}
+	if len(input.IPv6AddressPrefix) > 0 {
+		addressPrefixes = append(addressPrefixes, input.IPv6AddressPrefix)
+	}
+
+	serviceEndpointPolicies := []map[string]string{}
+	for _, id := range serviceEndpointPolicyIDs {
+		serviceEndpointPolicies = append(serviceEndpointPolicies, map[string]string{"id": id})
+	}
+
+	serviceEndpoints := []map[string]string{}
+	for _, service := range input.ServiceEndpoints {
+		serviceEndpoints = append(serviceEndpoints, map[string]string{"service": service})
+	}
+
+	delegations := []map[string]interface{}{}
+	for _, delegation := range input.Delegations {
+		actions := delegation.Actions
+		if actions == nil {
+			actions = []string{}
+		}
+
+		delegations = append(delegations, map[string]interface{}{
+			"name": delegation.Name,
+			"properties": map[string]interface{}{
+				"actions":     actions,
+				"serviceName": delegation.Service,
+			},
+		})
+	}
+
+	parameters := map[string]interface{}{
+		"addressPrefixes":                   addressPrefixes,
+		"delegations":                       delegations,
+		"natGatewayId":                      natGatewayID,
+		"networkSecurityGroupId":            networkSecurityGroupID,
+		"privateEndpointNetworkPolicies":    networkPolicies(input.EnforcePrivateLinkEndpointNetworkPolicies),
+		"privateLinkServiceNetworkPolicies": networkPolicies(input.EnforcePrivateLinkServiceNetworkPolicies),
+		"routeTableId":                      routeTableID,
+		"serviceEndpointPolicies":           serviceEndpointPolicies,
+		"serviceEndpoints":                  serviceEndpoints,
+		"subnetName":                        input.Name,
+		"virtualNetworkName":                virtualNetworkName,
+	}
+
+	body := map[string]interface{}{}
+	for name, value := range parameters {
+		body[name] = map[string]interface{}{"value": value}
+	}
+
+	content, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// networkPolicies returns the state of the private link network policies of
+// a subnet. Enforcing the private link flags disables the policies, as it
+// does on the SDK's subnet resource.
+func networkPolicies(enforce bool) string {
+	if enforce {
+		return "Disabled"
+	}
+
+	return "Enabled"
+}
+
+// validateIPv6AddressPrefix checks that the IPv6 address prefix of a subnet
+// is within one of the address spaces of its virtual network.
+func validateIPv6AddressPrefix(input *SubnetInput, addressSpaces []string) error {
+	ip, _, err := net.ParseCIDR(input.IPv6AddressPrefix)
+	if err != nil {
+		return pulumierr.InvalidConfigErr{input.Name, "subnet", err.Error()}
+	}
+
+	if ip.To4() != nil {
+		return pulumierr.InvalidConfigErr{input.Name, "subnet",
+			fmt.Sprintf("%s isn't an IPv6 address prefix", input.IPv6AddressPrefix)}
+	}
+
+	for _, addressSpace := range addressSpaces {
+		overlap, err := cidrsOverlap(addressSpace, input.IPv6AddressPrefix)
+		if err != nil {
+			return err
+		}
+
+		if overlap {
+			return nil
+		}
+	}
+
+	return pulumierr.InvalidConfigErr{input.Name, "subnet",
+		fmt.Sprintf("%s isn't in the address spaces of its virtual network", input.IPv6AddressPrefix)}
+}
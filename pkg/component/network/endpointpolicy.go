@@ -0,0 +1,147 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+// serviceEndpointPolicyTemplate is the ARM template of a service endpoint
+// policy. The SDK doesn't provide a resource for these policies, so they are
+// created as template deployments.
+const serviceEndpointPolicyTemplate = `{
+  "$schema": "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
+  "contentVersion": "1.0.0.0",
+  "parameters": {
+    "definitions": {"type": "array"},
+    "location": {"type": "string"},
+    "policyName": {"type": "string"},
+    "tags": {"type": "object"}
+  },
+  "resources": [{
+    "type": "Microsoft.Network/serviceEndpointPolicies",
+    "apiVersion": "2019-11-01",
+    "name": "[parameters('policyName')]",
+    "location": "[parameters('location')]",
+    "tags": "[parameters('tags')]",
+    "properties": {
+      "serviceEndpointPolicyDefinitions": "[parameters('definitions')]"
+    }
+  }],
+  "outputs": {
+    "policyId": {
+      "type": "string",
+      "value": "[resourceId('Microsoft.Network/serviceEndpointPolicies', parameters('policyName'))]"
+    }
+  }
+}`
+
+// knownServiceEndpointPolicyServices are the services whose service endpoints
+// can be filtered by service endpoint policies.
+var knownServiceEndpointPolicyServices = map[string]bool{
+	"Microsoft.Storage": true,
+}
+
+// serviceEndpointPolicies creates the service endpoint policies of the stack,
+// and returns their IDs by name.
+func serviceEndpointPolicies(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	resourceGroup *core.ResourceGroup,
+	tags pulumi.StringMap) (map[string]pulumi.StringOutput, error) {
+
+	policyInput := []*ServiceEndpointPolicyInput{}
+	if err := cfg.GetObject("serviceEndpointPolicies", &policyInput); err != nil {
+		return nil, err
+	}
+
+	policies := map[string]pulumi.StringOutput{}
+	for _, input := range policyInput {
+		input := input
+		if err := validateServiceEndpointPolicy(input); err != nil {
+			return nil, err
+		}
+
+		parameters := pulumi.All(resourceGroup.Location, tags).ApplyT(
+			func(args []interface{}) (string, error) {
+				return serviceEndpointPolicyParameters(input, args[0].(string), args[1].(map[string]string))
+			}).(pulumi.StringOutput)
+
+		deploymentName := fmt.Sprintf("%s-service-endpoint-policy", input.Name)
+		deployment, err := core.NewTemplateDeployment(ctx, deploymentName, &core.TemplateDeploymentArgs{
+			DeploymentMode:    pulumi.String("Incremental"),
+			Name:              pulumi.String(deploymentName),
+			ParametersBody:    parameters,
+			ResourceGroupName: resourceGroup.Name,
+			TemplateBody:      pulumi.String(serviceEndpointPolicyTemplate),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		policies[input.Name] = deployment.Outputs.ApplyT(func(outputs map[string]string) string {
+			return outputs["policyId"]
+		}).(pulumi.StringOutput)
+	}
+
+	return policies, nil
+}
+
+// serviceEndpointPolicyParameters returns the parameters of the service
+// endpoint policy template deployment.
+func serviceEndpointPolicyParameters(input *ServiceEndpointPolicyInput, location string, tags map[string]string) (string, error) {
+	definitions := []map[string]interface{}{}
+	for _, definition := range input.Definitions {
+		definitions = append(definitions, map[string]interface{}{
+			"name": definition.Name,
+			"properties": map[string]interface{}{
+				"description":      definition.Description,
+				"service":          definition.Service,
+				"serviceResources": definition.ServiceResources,
+			},
+		})
+	}
+
+	parameters := map[string]interface{}{
+		"definitions": definitions,
+		"location":    location,
+		"policyName":  input.Name,
+		"tags":        tags,
+	}
+
+	body := map[string]interface{}{}
+	for name, value := range parameters {
+		body[name] = map[string]interface{}{"value": value}
+	}
+
+	content, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+func validateServiceEndpointPolicy(input *ServiceEndpointPolicyInput) error {
+	if len(input.Definitions) == 0 {
+		return pulumierr.MissingConfigErr{input.Name, "service endpoint policy definitions"}
+	}
+
+	for _, definition := range input.Definitions {
+		if !knownServiceEndpointPolicyServices[definition.Service] {
+			return pulumierr.InvalidConfigErr{input.Name, "service endpoint policy",
+				fmt.Sprintf("unsupported service %q", definition.Service)}
+		}
+
+		if len(definition.ServiceResources) == 0 {
+			return pulumierr.InvalidConfigErr{input.Name, "service endpoint policy",
+				fmt.Sprintf("definition %s has no service resources", definition.Name)}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package network
+
+import (
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/existing"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+)
+
+// existingNetworkSecurityGroup reads the existing network security group
+// referenced by input. Its security rules aren't managed.
+func existingNetworkSecurityGroup(ctx *pulumi.Context, input *NetworkSecurityGroupInput) (*network.NetworkSecurityGroup, error) {
+	if len(input.SecurityRules) > 0 {
+		return nil, pulumierr.InvalidConfigErr{input.Name, "network security group", "the security rules of an existing network security group can't be set"}
+	}
+
+	id, err := input.Existing.Resolve(input.Name, "network security group", func(name, resourceGroup string) (string, error) {
+		result, err := network.LookupNetworkSecurityGroup(ctx, &network.LookupNetworkSecurityGroupArgs{
+			Name:              name,
+			ResourceGroupName: resourceGroup,
+		})
+		if err != nil {
+			return "", err
+		}
+		return result.Id, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return network.GetNetworkSecurityGroup(ctx, input.Name, id, nil)
+}
+
+// existingVirtualNetwork reads the existing virtual network referenced by
+// input, and returns it with its address spaces in Azure.
+func existingVirtualNetwork(ctx *pulumi.Context, input *VirtualNetworkInput) (*network.VirtualNetwork, []string, error) {
+	var addressSpaces []string
+	lookup := func(name, resourceGroup string) (string, error) {
+		result, err := network.LookupVirtualNetwork(ctx, &network.LookupVirtualNetworkArgs{
+			Name:              name,
+			ResourceGroupName: resourceGroup,
+		})
+		if err != nil {
+			return "", err
+		}
+		addressSpaces = result.AddressSpaces
+		return result.Id, nil
+	}
+
+	id, err := input.Existing.Resolve(input.Name, "virtual network", lookup)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(input.Existing.ID) > 0 {
+		name, resourceGroup, err := existingVirtualNetworkName(input)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if _, err := lookup(name, resourceGroup); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	virtualNetwork, err := network.GetVirtualNetwork(ctx, input.Name, id, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return virtualNetwork, addressSpaces, nil
+}
+
+// existingVirtualNetworkName returns the name and resource group of the
+// existing virtual network referenced by input, parsing them from its ID if
+// it's referenced by ID.
+func existingVirtualNetworkName(input *VirtualNetworkInput) (string, string, error) {
+	if len(input.Existing.ID) > 0 {
+		name, resourceGroup, err := existing.ParseID(input.Existing.ID, "virtualNetworks")
+		if err != nil {
+			return "", "", pulumierr.InvalidConfigErr{input.Name, "virtual network", err.Error()}
+		}
+		return name, resourceGroup, nil
+	}
+
+	name := input.Existing.Name
+	if len(name) == 0 {
+		name = input.Name
+	}
+
+	return name, input.Existing.ResourceGroup, nil
+}
+
+// existingSubnet reads the existing subnet referenced by input, from its
+// existing virtual network. A subnet referenced by name defaults to the
+// resource group of its virtual network. The properties of an existing subnet
+// can't be set.
+func existingSubnet(ctx *pulumi.Context, input *SubnetInput, virtualNetworkInput *VirtualNetworkInput) (*network.Subnet, error) {
+	if err := validateExistingSubnet(input, virtualNetworkInput); err != nil {
+		return nil, err
+	}
+
+	virtualNetworkName, virtualNetworkResourceGroup, err := existingVirtualNetworkName(virtualNetworkInput)
+	if err != nil {
+		return nil, err
+	}
+
+	reference := *input.Existing
+	if len(reference.ID) == 0 && len(reference.ResourceGroup) == 0 {
+		reference.ResourceGroup = virtualNetworkResourceGroup
+	}
+
+	id, err := reference.Resolve(input.Name, "subnet", func(name, resourceGroup string) (string, error) {
+		result, err := network.LookupSubnet(ctx, &network.LookupSubnetArgs{
+			Name:               name,
+			ResourceGroupName:  resourceGroup,
+			VirtualNetworkName: virtualNetworkName,
+		})
+		if err != nil {
+			return "", err
+		}
+		return result.Id, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return network.GetSubnet(ctx, input.Name, id, nil)
+}
+
+func validateExistingSubnet(input *SubnetInput, virtualNetworkInput *VirtualNetworkInput) error {
+	if virtualNetworkInput.Existing == nil {
+		return pulumierr.InvalidConfigErr{input.Name, "subnet",
+			"an existing subnet must belong to an existing virtual network, got " + virtualNetworkInput.Name}
+	}
+
+	if len(input.AddressPrefix) > 0 || len(input.Size) > 0 || len(input.IPv6AddressPrefix) > 0 ||
+		len(input.NATGateway) > 0 || len(input.RouteTable) > 0 || len(input.SecurityGroup) > 0 ||
+		len(input.Delegations) > 0 || len(input.ServiceEndpoints) > 0 || len(input.ServiceEndpointPolicies) > 0 ||
+		input.EnforcePrivateLinkEndpointNetworkPolicies || input.EnforcePrivateLinkServiceNetworkPolicies {
+		return pulumierr.InvalidConfigErr{input.Name, "subnet", "the properties of an existing subnet can't be set"}
+	}
+
+	return nil
+}
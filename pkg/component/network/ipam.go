@@ -0,0 +1,35 @@
+package network
+
+import (
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/ipam"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+// checkIPAMRegistry ensures that the address spaces of the virtual networks
+// don't collide with the CIDRs allocated to other stacks in the IPAM registry,
+// if one is configured. The stack is looked up by its <project>/<stack> name.
+func checkIPAMRegistry(project, stack string, cfg *config.Config, virtualNetworkInput []*VirtualNetworkInput) error {
+	path := cfg.Get("ipamRegistry")
+	if len(path) == 0 {
+		return nil
+	}
+
+	registry, err := ipam.Load(path)
+	if err != nil {
+		return err
+	}
+
+	for _, input := range virtualNetworkInput {
+		// existing virtual networks are allocated by their owners
+		if input.Existing != nil {
+			continue
+		}
+
+		if err := registry.Check(ipam.StackName(project, stack), addressSpaces(input)); err != nil {
+			return pulumierr.InvalidConfigErr{input.Name, "virtual network", err.Error()}
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,11 @@
 package network
 
 import (
+	"fmt"
+	"net"
+
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/vpngateway"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/convert"
 	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
@@ -8,114 +13,548 @@ import (
 	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
 )
 
+// gatewaySubnetName is the name Azure requires of the subnet of a virtual
+// network gateway.
+const gatewaySubnetName = "GatewaySubnet"
+
+// Reconcile creates the virtual networks and subnets of the stack, their
+// network security groups and VPN gateways, and the peerings of the virtual
+// networks. The IDs of the network security groups are returned by name, so
+// that they can also be associated with network interfaces. The VPN gateways
+// are created before the peerings, which may use them.
 func Reconcile(
 	ctx *pulumi.Context,
 	cfg *config.Config,
 	appSecGroups map[string]*network.ApplicationSecurityGroup,
+	publicIPs map[string]*network.PublicIp,
+	publicIPPrefixes map[string]*network.PublicIpPrefix,
 	resourceGroup *core.ResourceGroup,
-	tags pulumi.StringMap) (map[string]*network.VirtualNetwork, error) {
+	tags pulumi.StringMap) (map[string]*network.VirtualNetwork, map[string]*network.Subnet, map[string]pulumi.IDOutput, map[string]*network.VirtualNetworkGateway, error) {
 
 	networkSecurityRules, err := networkSecurityRules(ctx, cfg, appSecGroups)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	networkSecurityGroups, err := networkSecurityGroups(ctx, cfg, networkSecurityRules, resourceGroup, tags)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, nil, err
 	}
 
-	allSubnets, err := subnets(ctx, cfg, networkSecurityGroups)
+	natGateways, err := natGateways(ctx, cfg, publicIPs, publicIPPrefixes, resourceGroup, tags)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, nil, err
+	}
+
+	routeTables, err := routeTables(ctx, cfg, resourceGroup, tags)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	serviceEndpointPolicies, err := serviceEndpointPolicies(ctx, cfg, resourceGroup, tags)
+	if err != nil {
+		return nil, nil, nil, nil, err
 	}
 
 	virtualNetworkInput := []*VirtualNetworkInput{}
 	if err := cfg.TryObject("virtualNetworks", &virtualNetworkInput); err != nil {
-		return nil, err
+		return nil, nil, nil, nil, err
+	}
+
+	if err := checkIPAMRegistry(ctx.Project(), ctx.Stack(), cfg, virtualNetworkInput); err != nil {
+		return nil, nil, nil, nil, err
 	}
 
 	networks := map[string]*network.VirtualNetwork{}
 	for _, input := range virtualNetworkInput {
-		subnets := network.VirtualNetworkSubnetArray{}
-		for _, input := range input.Subnets {
-			subnet, exists := allSubnets[input]
-			if !exists {
-				return nil, pulumierr.MissingConfigErr{input, "subnet"}
+		if input.Existing != nil {
+			network, existingAddressSpaces, err := existingVirtualNetwork(ctx, input)
+			if err != nil {
+				return nil, nil, nil, nil, err
 			}
-			subnets = append(subnets, subnet)
-		}
 
-		addressSpaces := pulumi.StringArray{
-			pulumi.String(input.CIDR),
+			if len(addressSpaces(input)) == 0 {
+				input.AddressSpaces = existingAddressSpaces
+			}
+
+			networks[input.Name] = network
+			continue
 		}
 
+		addressSpaces := convert.ToStringArray(addressSpaces(input))
+
 		network, err := network.NewVirtualNetwork(ctx, input.Name,
 			&network.VirtualNetworkArgs{
 				AddressSpaces:     addressSpaces,
 				Location:          resourceGroup.Location,
 				ResourceGroupName: resourceGroup.Name,
 				Tags:              tags,
-				Subnets:           subnets,
 			})
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		networks[input.Name] = network
 	}
 
-	return networks, nil
+	subnets, err := subnets(ctx, cfg, natGateways, networkSecurityGroups, routeTables, serviceEndpointPolicies, networks, virtualNetworkInput)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	vpnGateways, err := vpngateway.Reconcile(ctx, cfg, publicIPs, resourceGroup, subnets, tags)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	if err := virtualNetworkPeerings(ctx, cfg, networks, virtualNetworkInput, vpnGateways); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return networks, subnets, networkSecurityGroups, vpnGateways, nil
 }
 
-func networkSecurityRules(
+// natGateways creates the NAT gateways of the stack. Subnets associated with a
+// NAT gateway use its public IPs for all outbound connections, instead of the
+// default outbound access of their VMs.
+func natGateways(
 	ctx *pulumi.Context,
 	cfg *config.Config,
-	appSecGroups map[string]*network.ApplicationSecurityGroup) (map[string]network.NetworkSecurityGroupSecurityRuleArgs, error) {
+	publicIPs map[string]*network.PublicIp,
+	publicIPPrefixes map[string]*network.PublicIpPrefix,
+	resourceGroup *core.ResourceGroup,
+	tags pulumi.StringMap) (map[string]*network.NatGateway, error) {
+
+	natGatewayInput := []*NATGatewayInput{}
+	if err := cfg.GetObject("natGateways", &natGatewayInput); err != nil {
+		return nil, err
+	}
+
+	natGateways := map[string]*network.NatGateway{}
+	for _, input := range natGatewayInput {
+		if len(input.PublicIPs) == 0 && len(input.PublicIPPrefixes) == 0 {
+			return nil, pulumierr.InvalidConfigErr{input.Name, "NAT gateway", "at least one public IP or public IP prefix is required"}
+		}
+
+		publicIPIDs := pulumi.StringArray{}
+		for _, name := range input.PublicIPs {
+			publicIP, exists := publicIPs[name]
+			if !exists {
+				return nil, pulumierr.MissingConfigErr{name, "public IP"}
+			}
+			publicIPIDs = append(publicIPIDs, publicIP.ID())
+		}
+
+		publicIPPrefixIDs := pulumi.StringArray{}
+		for _, name := range input.PublicIPPrefixes {
+			publicIPPrefix, exists := publicIPPrefixes[name]
+			if !exists {
+				return nil, pulumierr.MissingConfigErr{name, "public IP prefix"}
+			}
+			publicIPPrefixIDs = append(publicIPPrefixIDs, publicIPPrefix.ID())
+		}
+
+		zones := pulumi.StringArray{}
+		for _, zone := range input.Zones {
+			zones = append(zones, pulumi.String(zone))
+		}
+
+		args := &network.NatGatewayArgs{
+			Location:           resourceGroup.Location,
+			Name:               pulumi.String(input.Name),
+			PublicIpAddressIds: publicIPIDs,
+			PublicIpPrefixIds:  publicIPPrefixIDs,
+			ResourceGroupName:  resourceGroup.Name,
+			SkuName:            pulumi.String("Standard"),
+			Tags:               tags,
+			Zones:              zones,
+		}
+
+		if input.IdleTimeoutMinutes > 0 {
+			args.IdleTimeoutInMinutes = pulumi.Int(input.IdleTimeoutMinutes)
+		}
+
+		natGateway, err := network.NewNatGateway(ctx, input.Name, args)
+		if err != nil {
+			return nil, err
+		}
+
+		natGateways[input.Name] = natGateway
+	}
+
+	return natGateways, nil
+}
+
+// routeTables creates the route tables of the stack, with their user-defined
+// routes.
+func routeTables(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	resourceGroup *core.ResourceGroup,
+	tags pulumi.StringMap) (map[string]*network.RouteTable, error) {
+
+	routeTableInput := []*RouteTableInput{}
+	if err := cfg.GetObject("routeTables", &routeTableInput); err != nil {
+		return nil, err
+	}
+
+	routeTables := map[string]*network.RouteTable{}
+	for _, input := range routeTableInput {
+		routes := network.RouteTableRouteArray{}
+		for _, route := range input.Routes {
+			if err := validateRoute(route); err != nil {
+				return nil, err
+			}
+
+			args := network.RouteTableRouteArgs{
+				AddressPrefix: pulumi.String(route.AddressPrefix),
+				Name:          pulumi.String(route.Name),
+				NextHopType:   pulumi.String(route.NextHopType),
+			}
+
+			if len(route.NextHopIPAddress) > 0 {
+				args.NextHopInIpAddress = pulumi.String(route.NextHopIPAddress)
+			}
+
+			routes = append(routes, args)
+		}
+
+		routeTable, err := network.NewRouteTable(ctx, input.Name, &network.RouteTableArgs{
+			DisableBgpRoutePropagation: pulumi.Bool(input.DisableBGPRoutePropagation),
+			Location:                   resourceGroup.Location,
+			Name:                       pulumi.String(input.Name),
+			ResourceGroupName:          resourceGroup.Name,
+			Routes:                     routes,
+			Tags:                       tags,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		routeTables[input.Name] = routeTable
+	}
+
+	return routeTables, nil
+}
+
+// validateRoute checks the address prefix and next hop of a route.
+func validateRoute(input *RouteInput) error {
+	if _, _, err := net.ParseCIDR(input.AddressPrefix); err != nil {
+		return pulumierr.InvalidConfigErr{input.Name, "route", err.Error()}
+	}
+
+	switch input.NextHopType {
+	case "VirtualAppliance":
+		if net.ParseIP(input.NextHopIPAddress) == nil {
+			return pulumierr.InvalidConfigErr{input.Name, "route",
+				fmt.Sprintf("invalid next hop IP address %q", input.NextHopIPAddress)}
+		}
+	case "Internet", "None", "VirtualNetworkGateway", "VnetLocal":
+		if len(input.NextHopIPAddress) > 0 {
+			return pulumierr.InvalidConfigErr{input.Name, "route",
+				fmt.Sprintf("next hop IP address isn't allowed with next hop type %s", input.NextHopType)}
+		}
+	default:
+		return pulumierr.InvalidConfigErr{input.Name, "route",
+			fmt.Sprintf("unknown next hop type %q", input.NextHopType)}
+	}
+
+	return nil
+}
+
+// virtualNetworkPeerings peers the virtual networks in both directions. The
+// peerings that share the VPN gateways of their local virtual network depend
+// on the gateways, which must exist.
+func virtualNetworkPeerings(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	virtualNetworks map[string]*network.VirtualNetwork,
+	virtualNetworkInput []*VirtualNetworkInput,
+	vpnGateways map[string]*network.VirtualNetworkGateway) error {
+
+	peeringInput := []*VirtualNetworkPeeringInput{}
+	if err := cfg.GetObject("virtualNetworkPeerings", &peeringInput); err != nil {
+		return err
+	}
+
+	cidrs := map[string][]string{}
+	gatewayNetwork := ""
+	for _, input := range virtualNetworkInput {
+		cidrs[input.Name] = addressSpaces(input)
+		for _, subnet := range input.Subnets {
+			if subnet == gatewaySubnetName {
+				gatewayNetwork = input.Name
+			}
+		}
+	}
+
+	// VPN gateways must be in the GatewaySubnet, so they all belong to the
+	// virtual network of the GatewaySubnet
+	gateways := []pulumi.Resource{}
+	for _, vpnGateway := range vpnGateways {
+		gateways = append(gateways, vpnGateway)
+	}
+
+	for _, input := range peeringInput {
+		local, exists := virtualNetworks[input.LocalVirtualNetwork]
+		if !exists {
+			return pulumierr.MissingConfigErr{input.LocalVirtualNetwork, "virtual network"}
+		}
+
+		remote, exists := virtualNetworks[input.RemoteVirtualNetwork]
+		if !exists {
+			return pulumierr.MissingConfigErr{input.RemoteVirtualNetwork, "virtual network"}
+		}
+
+		for _, name := range []string{input.LocalVirtualNetwork, input.RemoteVirtualNetwork} {
+			if len(cidrs[name]) == 0 {
+				return pulumierr.InvalidConfigErr{input.Name, "virtual network peering",
+					fmt.Sprintf("address spaces of %s are unknown, so overlaps can't be checked", name)}
+			}
+		}
+
+		for _, localCIDR := range cidrs[input.LocalVirtualNetwork] {
+			for _, remoteCIDR := range cidrs[input.RemoteVirtualNetwork] {
+				overlap, err := cidrsOverlap(localCIDR, remoteCIDR)
+				if err != nil {
+					return err
+				}
+
+				if overlap {
+					return pulumierr.InvalidConfigErr{input.Name, "virtual network peering",
+						fmt.Sprintf("address spaces of %s and %s overlap", input.LocalVirtualNetwork, input.RemoteVirtualNetwork)}
+				}
+			}
+		}
+
+		localGateways := []pulumi.Resource{}
+		if input.LocalVirtualNetwork == gatewayNetwork {
+			localGateways = gateways
+		}
+
+		if err := validateVirtualNetworkPeering(input, localGateways); err != nil {
+			return err
+		}
+
+		opts := []pulumi.ResourceOption{}
+		if input.AllowGatewayTransit || input.UseRemoteGateways {
+			opts = append(opts, pulumi.DependsOn(localGateways))
+		}
+
+		localToRemote := fmt.Sprintf("%s-%s-to-%s", input.Name, input.LocalVirtualNetwork, input.RemoteVirtualNetwork)
+		if _, err := network.NewVirtualNetworkPeering(ctx, localToRemote, &network.VirtualNetworkPeeringArgs{
+			AllowForwardedTraffic:     pulumi.Bool(input.AllowForwardedTraffic),
+			AllowGatewayTransit:       pulumi.Bool(input.AllowGatewayTransit),
+			AllowVirtualNetworkAccess: pulumi.Bool(true),
+			Name:                      pulumi.String(localToRemote),
+			RemoteVirtualNetworkId:    remote.ID(),
+			ResourceGroupName:         local.ResourceGroupName,
+			VirtualNetworkName:        local.Name,
+		}, opts...); err != nil {
+			return err
+		}
+
+		remoteToLocal := fmt.Sprintf("%s-%s-to-%s", input.Name, input.RemoteVirtualNetwork, input.LocalVirtualNetwork)
+		if _, err := network.NewVirtualNetworkPeering(ctx, remoteToLocal, &network.VirtualNetworkPeeringArgs{
+			AllowForwardedTraffic:     pulumi.Bool(input.AllowForwardedTraffic),
+			AllowVirtualNetworkAccess: pulumi.Bool(true),
+			Name:                      pulumi.String(remoteToLocal),
+			RemoteVirtualNetworkId:    local.ID(),
+			ResourceGroupName:         remote.ResourceGroupName,
+			UseRemoteGateways:         pulumi.Bool(input.UseRemoteGateways),
+			VirtualNetworkName:        remote.Name,
+		}, opts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateVirtualNetworkPeering ensures that a peering whose remote virtual
+// network uses the gateways of the local one allows gateway transit, and that
+// the local virtual network has a VPN gateway.
+func validateVirtualNetworkPeering(input *VirtualNetworkPeeringInput, localGateways []pulumi.Resource) error {
+	if !input.UseRemoteGateways {
+		return nil
+	}
+
+	if !input.AllowGatewayTransit {
+		return pulumierr.InvalidConfigErr{input.Name, "virtual network peering", "useRemoteGateways requires allowGatewayTransit"}
+	}
+
+	if len(localGateways) == 0 {
+		return pulumierr.InvalidConfigErr{input.Name, "virtual network peering",
+			fmt.Sprintf("useRemoteGateways requires a VPN gateway in virtual network %s", input.LocalVirtualNetwork)}
+	}
+
+	return nil
+}
+
+// addressSpaces returns all the address spaces of a virtual network, starting
+// with its primary CIDR. Existing virtual networks may not have any.
+func addressSpaces(input *VirtualNetworkInput) []string {
+	if len(input.CIDR) == 0 {
+		return input.AddressSpaces
+	}
+
+	return append([]string{input.CIDR}, input.AddressSpaces...)
+}
+
+// cidrsOverlap returns true if the two CIDR blocks share any addresses.
+func cidrsOverlap(a, b string) (bool, error) {
+	_, networkA, err := net.ParseCIDR(a)
+	if err != nil {
+		return false, err
+	}
+
+	_, networkB, err := net.ParseCIDR(b)
+	if err != nil {
+		return false, err
+	}
+
+	return networkA.Contains(networkB.IP) || networkB.Contains(networkA.IP), nil
+}
+
+// securityRuleInput reads the network security rules, expands their
+// shorthands, assigns the priorities that aren't set and validates them.
+func securityRuleInput(
+	cfg *config.Config,
+	appSecGroups map[string]*network.ApplicationSecurityGroup) ([]*NetworkSecurityRuleInput, error) {
 
 	netSecRulesInput := []*NetworkSecurityRuleInput{}
 	if err := cfg.TryObject("networkSecurityRules", &netSecRulesInput); err != nil {
 		return nil, err
 	}
 
+	for _, input := range netSecRulesInput {
+		if err := expandSecurityRule(input, appSecGroups); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := assignPriorities(netSecRulesInput); err != nil {
+		return nil, err
+	}
+
+	for _, input := range netSecRulesInput {
+		if err := validateSecurityRule(input); err != nil {
+			return nil, err
+		}
+	}
+
+	return netSecRulesInput, nil
+}
+
+func networkSecurityRules(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	appSecGroups map[string]*network.ApplicationSecurityGroup) (map[string]network.NetworkSecurityGroupSecurityRuleArgs, error) {
+
+	netSecRulesInput, err := securityRuleInput(cfg, appSecGroups)
+	if err != nil {
+		return nil, err
+	}
+
 	networkSecurityRules := map[string]network.NetworkSecurityGroupSecurityRuleArgs{}
 	for _, input := range netSecRulesInput {
-		destinationAppSecGroups := pulumi.StringArray{}
-		for _, key := range input.DestinationAppSecurityGroups {
-			appSecGroup, exists := appSecGroups[key]
-			if !exists {
-				return nil, pulumierr.MissingConfigErr{key, "application security group"}
-			}
-			destinationAppSecGroups = append(
-				destinationAppSecGroups,
-				appSecGroup.ID())
+		destinationAppSecGroups, err := appSecGroupIDs(input.DestinationAppSecurityGroups, appSecGroups)
+		if err != nil {
+			return nil, err
 		}
 
-		destinationPortRanges := pulumi.StringArray{}
-		for _, portRanges := range input.DestinationPortRanges {
-			destinationPortRanges = append(
-				destinationPortRanges,
-				pulumi.String(portRanges))
+		sourceAppSecGroups, err := appSecGroupIDs(input.SourceAppSecurityGroups, appSecGroups)
+		if err != nil {
+			return nil, err
 		}
 
-		networkSecurityRules[input.Name] = network.NetworkSecurityGroupSecurityRuleArgs{
+		rule := network.NetworkSecurityGroupSecurityRuleArgs{
 			Access:                                 pulumi.String(input.Access),
 			Description:                            pulumi.String(input.Description),
-			DestinationPortRanges:                  destinationPortRanges,
-			DestinationAddressPrefix:               pulumi.String(input.DestinationAddressPrefix),
+			DestinationAddressPrefixes:             convert.ToStringArray(input.DestinationAddressPrefixes),
 			DestinationApplicationSecurityGroupIds: destinationAppSecGroups,
+			DestinationPortRanges:                  convert.ToStringArray(input.DestinationPortRanges),
 			Direction:                              pulumi.String(input.Direction),
 			Name:                                   pulumi.String(input.Name),
 			Priority:                               pulumi.Int(input.Priority),
 			Protocol:                               pulumi.String(input.Protocol),
-			SourceAddressPrefix:                    pulumi.String(input.SourceAddressPrefix),
-			SourcePortRange:                        pulumi.String(input.SourcePortRange),
+			SourceAddressPrefixes:                  convert.ToStringArray(input.SourceAddressPrefixes),
+			SourceApplicationSecurityGroupIds:      sourceAppSecGroups,
+			SourcePortRanges:                       convert.ToStringArray(input.SourcePortRanges),
+		}
+
+		if len(input.DestinationAddressPrefix) > 0 {
+			rule.DestinationAddressPrefix = pulumi.String(input.DestinationAddressPrefix)
+		}
+
+		if len(input.DestinationPortRange) > 0 {
+			rule.DestinationPortRange = pulumi.String(input.DestinationPortRange)
+		}
+
+		if len(input.SourceAddressPrefix) > 0 {
+			rule.SourceAddressPrefix = pulumi.String(input.SourceAddressPrefix)
+		}
+
+		if len(input.SourcePortRange) > 0 {
+			rule.SourcePortRange = pulumi.String(input.SourcePortRange)
 		}
+
+		networkSecurityRules[input.Name] = rule
 	}
 
 	return networkSecurityRules, nil
 }
 
+func appSecGroupIDs(names []string, appSecGroups map[string]*network.ApplicationSecurityGroup) (pulumi.StringArray, error) {
+	ids := pulumi.StringArray{}
+	for _, name := range names {
+		appSecGroup, exists := appSecGroups[name]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{name, "application security group"}
+		}
+		ids = append(ids, appSecGroup.ID())
+	}
+
+	return ids, nil
+}
+
+// validateSecurityRule checks that each side of the rule sets exactly one of
+// its mutually exclusive address and port fields.
+func validateSecurityRule(input *NetworkSecurityRuleInput) error {
+	sides := []struct {
+		name            string
+		addressPrefix   string
+		addressPrefixes []string
+		appSecGroups    []string
+		portRange       string
+		portRanges      []string
+	}{
+		{"source", input.SourceAddressPrefix, input.SourceAddressPrefixes, input.SourceAppSecurityGroups, input.SourcePortRange, input.SourcePortRanges},
+		{"destination", input.DestinationAddressPrefix, input.DestinationAddressPrefixes, input.DestinationAppSecurityGroups, input.DestinationPortRange, input.DestinationPortRanges},
+	}
+
+	for _, side := range sides {
+		addresses := 0
+		for _, set := range []bool{len(side.addressPrefix) > 0, len(side.addressPrefixes) > 0, len(side.appSecGroups) > 0} {
+			if set {
+				addresses++
+			}
+		}
+
+		if addresses != 1 {
+			return pulumierr.InvalidConfigErr{input.Name, "network security rule",
+				fmt.Sprintf("exactly one of %[1]s address prefix, %[1]s address prefixes or %[1]s application security groups is required", side.name)}
+		}
+
+		if (len(side.portRange) > 0) == (len(side.portRanges) > 0) {
+			return pulumierr.InvalidConfigErr{input.Name, "network security rule",
+				fmt.Sprintf("exactly one of %[1]s port range or %[1]s port ranges is required", side.name)}
+		}
+	}
+
+	return nil
+}
+
 func networkSecurityGroups(
 	ctx *pulumi.Context,
 	cfg *config.Config,
@@ -130,6 +569,16 @@ func networkSecurityGroups(
 
 	networkSecurityGroups := map[string]pulumi.IDOutput{}
 	for _, input := range netSecGroupInput {
+		if input.Existing != nil {
+			securityGroup, err := existingNetworkSecurityGroup(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+
+			networkSecurityGroups[input.Name] = securityGroup.ID()
+			continue
+		}
+
 		securityRules := network.NetworkSecurityGroupSecurityRuleArray{}
 		for _, rule := range input.SecurityRules {
 			securityRules = append(securityRules, networkSecurityRules[rule])
@@ -152,22 +601,157 @@ func networkSecurityGroups(
 	return networkSecurityGroups, nil
 }
 
+// subnets creates the subnets of the virtual networks as standalone
+// resources, so that resources placed in them depend on their actual IDs.
+// A subnet can only belong to one virtual network.
 func subnets(
 	ctx *pulumi.Context,
 	cfg *config.Config,
-	networkSecurityGroups map[string]pulumi.IDOutput) (map[string]network.VirtualNetworkSubnetArgs, error) {
+	natGateways map[string]*network.NatGateway,
+	networkSecurityGroups map[string]pulumi.IDOutput,
+	routeTables map[string]*network.RouteTable,
+	serviceEndpointPolicies map[string]pulumi.StringOutput,
+	virtualNetworks map[string]*network.VirtualNetwork,
+	virtualNetworkInput []*VirtualNetworkInput) (map[string]*network.Subnet, error) {
 
 	var subnetInput []*SubnetInput
 	if err := cfg.TryObject("subnets", &subnetInput); err != nil {
 		return nil, err
 	}
 
-	subnets := map[string]network.VirtualNetworkSubnetArgs{}
+	allSubnets := map[string]*SubnetInput{}
 	for _, input := range subnetInput {
-		subnets[input.Name] = network.VirtualNetworkSubnetArgs{
-			AddressPrefix: pulumi.String(input.AddressPrefix),
-			Name:          pulumi.String(input.Name),
-			SecurityGroup: networkSecurityGroups[input.SecurityGroup],
+		allSubnets[input.Name] = input
+	}
+
+	carvedAddressPrefixes, err := carvedAddressPrefixes(ctx, cfg, allSubnets, virtualNetworkInput)
+	if err != nil {
+		return nil, err
+	}
+
+	subnets := map[string]*network.Subnet{}
+	for _, networkInput := range virtualNetworkInput {
+		virtualNetwork := virtualNetworks[networkInput.Name]
+		for _, name := range networkInput.Subnets {
+			input, exists := allSubnets[name]
+			if !exists {
+				return nil, pulumierr.MissingConfigErr{name, "subnet"}
+			}
+
+			if _, exists := subnets[name]; exists {
+				return nil, pulumierr.InvalidConfigErr{name, "subnet", "subnet is used by more than one virtual network"}
+			}
+
+			if input.Existing != nil {
+				subnet, err := existingSubnet(ctx, input, networkInput)
+				if err != nil {
+					return nil, err
+				}
+
+				subnets[name] = subnet
+				continue
+			}
+
+			var addressPrefix pulumi.StringInput = pulumi.String(input.AddressPrefix)
+			if len(input.Size) > 0 {
+				addressPrefix = carvedAddressPrefixes.MapIndex(pulumi.String(input.Name))
+			}
+
+			var (
+				natGateway           pulumi.StringInput = pulumi.String("")
+				networkSecurityGroup pulumi.StringInput = pulumi.String("")
+				routeTable           pulumi.StringInput = pulumi.String("")
+			)
+			if len(input.NATGateway) > 0 {
+				resource, exists := natGateways[input.NATGateway]
+				if !exists {
+					return nil, pulumierr.MissingConfigErr{input.NATGateway, "NAT gateway"}
+				}
+				natGateway = resource.ID().ToStringOutput()
+			}
+
+			if len(input.SecurityGroup) > 0 {
+				id, exists := networkSecurityGroups[input.SecurityGroup]
+				if !exists {
+					return nil, pulumierr.MissingConfigErr{input.SecurityGroup, "network security group"}
+				}
+				networkSecurityGroup = id.ToStringOutput()
+			}
+
+			if len(input.RouteTable) > 0 {
+				resource, exists := routeTables[input.RouteTable]
+				if !exists {
+					return nil, pulumierr.MissingConfigErr{input.RouteTable, "route table"}
+				}
+				routeTable = resource.ID().ToStringOutput()
+			}
+
+			policies := pulumi.StringArray{}
+			for _, policy := range input.ServiceEndpointPolicies {
+				id, exists := serviceEndpointPolicies[policy]
+				if !exists {
+					return nil, pulumierr.MissingConfigErr{policy, "service endpoint policy"}
+				}
+				policies = append(policies, id)
+			}
+
+			if input.TemplateDeployed() {
+				if len(input.IPv6AddressPrefix) > 0 {
+					if err := validateIPv6AddressPrefix(input, addressSpaces(networkInput)); err != nil {
+						return nil, err
+					}
+				}
+
+				subnet, err := templateSubnet(ctx, input, addressPrefix, natGateway, networkSecurityGroup, routeTable, policies, virtualNetwork)
+				if err != nil {
+					return nil, err
+				}
+
+				subnets[name] = subnet
+				continue
+			}
+
+			args, err := subnetArgs(input, addressPrefix, virtualNetwork, virtualNetwork.ResourceGroupName)
+			if err != nil {
+				return nil, err
+			}
+
+			subnet, err := network.NewSubnet(ctx, input.Name, args)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(input.SecurityGroup) > 0 {
+				if _, err := network.NewSubnetNetworkSecurityGroupAssociation(ctx, input.Name,
+					&network.SubnetNetworkSecurityGroupAssociationArgs{
+						NetworkSecurityGroupId: networkSecurityGroup,
+						SubnetId:               subnet.ID(),
+					}); err != nil {
+					return nil, err
+				}
+			}
+
+			if len(input.NATGateway) > 0 {
+				if _, err := network.NewSubnetNatGatewayAssociation(ctx, input.Name,
+					&network.SubnetNatGatewayAssociationArgs{
+						NatGatewayId: natGateway,
+						SubnetId:     subnet.ID(),
+					}); err != nil {
+					return nil, err
+				}
+			}
+
+			if len(input.RouteTable) > 0 {
+				if _, err := network.NewSubnetRouteTableAssociation(ctx, input.Name,
+					&network.SubnetRouteTableAssociationArgs{
+						RouteTableId: routeTable,
+						SubnetId:     subnet.ID(),
+					}); err != nil {
+					return nil, err
+				}
+			}
+
+			subnets[name] = subnet
 		}
 	}
 
@@ -1,6 +1,10 @@
 package network
 
 import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
 	"strings"
 	"sync"
 	"testing"
@@ -8,11 +12,51 @@ import (
 	"github.com/ihcsim/pulumi-azure/v2/pkg/mock"
 	"github.com/ihcsim/pulumi-azure/v2/pkg/test"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi/sdk/go/common/resource"
 	"github.com/pulumi/pulumi/sdk/go/pulumi"
 	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
 )
 
+const (
+	securityGroupAssociationType = "azure:network/subnetNetworkSecurityGroupAssociation:SubnetNetworkSecurityGroupAssociation"
+	templateDeploymentType       = "azure:core/templateDeployment:TemplateDeployment"
+	virtualNetworkPeeringType    = "azure:network/virtualNetworkPeering:VirtualNetworkPeering"
+	virtualNetworkType           = "azure:network/virtualNetwork:VirtualNetwork"
+)
+
+// recordingMocks records the inputs of the created resources, keyed by their
+// types and logical names. Reading the existing virtual network returns its
+// resource group, as Azure would.
+type recordingMocks struct {
+	mock.Mocks
+	sync.Mutex
+	resources map[string]map[string]resource.PropertyMap
+}
+
+func (m *recordingMocks) NewResource(
+	typeToken, name string,
+	inputs resource.PropertyMap,
+	provider, id string) (string, resource.PropertyMap, error) {
+
+	if len(id) > 0 {
+		if typeToken == virtualNetworkType && name == test.VirtualNetworkExistingName {
+			inputs["resourceGroupName"] = resource.NewStringProperty(test.VirtualNetworkExistingResourceGroup)
+		}
+		return m.Mocks.NewResource(typeToken, name, inputs, provider, id)
+	}
+
+	m.Lock()
+	if m.resources[typeToken] == nil {
+		m.resources[typeToken] = map[string]resource.PropertyMap{}
+	}
+	m.resources[typeToken][name] = inputs
+	m.Unlock()
+
+	return m.Mocks.NewResource(typeToken, name, inputs, provider, id)
+}
+
 func TestReconcile(t *testing.T) {
+	mocks := &recordingMocks{resources: map[string]map[string]resource.PropertyMap{}}
 	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
 		cfg := config.New(ctx, test.ConfigNamespace)
 
@@ -26,11 +70,25 @@ func TestReconcile(t *testing.T) {
 			return err
 		}
 
-		virtualNetworks, err := Reconcile(ctx, cfg, appSecGroups, resourceGroup, test.Tags)
+		publicIPs, err := test.MockPublicIPs(ctx)
+		if err != nil {
+			return err
+		}
+
+		publicIPPrefixes, err := test.MockPublicIPPrefixes(ctx)
 		if err != nil {
 			return err
 		}
 
+		virtualNetworks, subnets, _, vpnGateways, err := Reconcile(ctx, cfg, appSecGroups, publicIPs, publicIPPrefixes, resourceGroup, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		if _, exists := vpnGateways[test.VPNGatewayName]; !exists {
+			t.Errorf("missing VPN gateway: %s", test.VPNGatewayName)
+		}
+
 		virtualNetwork, exists := virtualNetworks[test.VirtualNetworkName]
 		if !exists {
 			t.Errorf("missing virtual network: %s", test.VirtualNetworkName)
@@ -56,40 +114,257 @@ func TestReconcile(t *testing.T) {
 		virtualNetwork.AddressSpaces.ApplyT(func(addressSpaces []string) error {
 			defer wg.Done()
 
-			if len(addressSpaces) != 1 {
-				t.Error("expected address spaces list to have a length of 1")
+			expected := []string{test.VirtualNetworkAddressSpace, test.VirtualNetworkIPv6AddressSpace}
+			if !reflect.DeepEqual(addressSpaces, expected) {
+				t.Errorf("address spaces mismatch. expected: %v, actual: %v", expected, addressSpaces)
 			}
 
-			if actual := addressSpaces[0]; actual != "10.0.0.0/16" {
-				t.Errorf("address spaces mismatch. expected: 10.0.0.0/16, actual: %s", actual)
+			return nil
+		})
+
+		subnet, exists := subnets[test.SubnetName]
+		if !exists {
+			t.Errorf("missing subnet: %s", test.SubnetName)
+		}
+
+		if _, exists := subnets[test.SubnetDualStackName]; !exists {
+			t.Errorf("missing subnet: %s", test.SubnetDualStackName)
+		}
+
+		if _, exists := virtualNetworks[test.VirtualNetworkExistingName]; !exists {
+			t.Errorf("missing existing virtual network: %s", test.VirtualNetworkExistingName)
+		}
+
+		if _, exists := subnets[test.SubnetExistingName]; !exists {
+			t.Errorf("missing existing subnet: %s", test.SubnetExistingName)
+		}
+
+		if _, exists := subnets[test.SubnetExistingPolicyName]; !exists {
+			t.Errorf("missing subnet: %s", test.SubnetExistingPolicyName)
+		}
+
+		carvedSubnet, exists := subnets[test.SubnetCarvedName]
+		if !exists {
+			t.Fatalf("missing subnet: %s", test.SubnetCarvedName)
+		}
+
+		wg.Add(1)
+		carvedSubnet.AddressPrefix.ApplyT(func(actual string) error {
+			defer wg.Done()
+
+			if expected := "10.0.2.0/26"; actual != expected {
+				t.Errorf("carved address prefix mismatch. expected: %s, actual: %s", expected, actual)
 			}
 
 			return nil
 		})
 
 		wg.Add(1)
-		virtualNetwork.Subnets.ApplyT(func(subnets []network.VirtualNetworkSubnet) error {
+		pulumi.All(subnet.AddressPrefix, subnet.Name, subnet.ServiceEndpoints).ApplyT(func(actuals []interface{}) error {
+			defer wg.Done()
+
+			if actual := actuals[0].(string); actual != "10.0.0.0/24" {
+				t.Errorf("address prefix mismatch. expected: 10.0.0.0/24, actual: %s", actual)
+			}
+
+			if actual := actuals[1].(string); actual != test.SubnetName {
+				t.Errorf("subnet name mismatch. expected: %s, actual: %s", test.SubnetName, actual)
+			}
+
+			if actual := actuals[2].([]string); len(actual) != 1 || actual[0] != test.SubnetServiceEndpoint {
+				t.Errorf("service endpoints mismatch. expected: [%s], actual: %v", test.SubnetServiceEndpoint, actual)
+			}
+
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mocks)); err != nil {
+		t.Fatal(err)
+	}
+
+	association, exists := mocks.resources[securityGroupAssociationType][test.SubnetName]
+	if !exists {
+		t.Fatalf("missing network security group association of subnet: %s", test.SubnetName)
+	}
+
+	if expected, actual := test.NetworkSecurityGroupName+"_id", association["networkSecurityGroupId"].StringValue(); actual != expected {
+		t.Errorf("network security group ID mismatch. expected: %s, actual: %s", expected, actual)
+	}
+
+	if expected, actual := test.SubnetName+"_id", association["subnetId"].StringValue(); actual != expected {
+		t.Errorf("subnet ID mismatch. expected: %s, actual: %s", expected, actual)
+	}
+
+	// template subnets are deployed to the resource groups of their virtual
+	// networks
+	for deployment, expected := range map[string]string{
+		test.SubnetDualStackName + "-dual-stack":  test.ResourceGroupName,
+		test.SubnetExistingPolicyName + "-subnet": test.VirtualNetworkExistingResourceGroup,
+	} {
+		inputs, exists := mocks.resources[templateDeploymentType][deployment]
+		if !exists {
+			t.Errorf("missing template deployment: %s", deployment)
+			continue
+		}
+
+		if actual := inputs["resourceGroupName"].StringValue(); actual != expected {
+			t.Errorf("resource group mismatch of template deployment %s. expected: %s, actual: %s", deployment, expected, actual)
+		}
+	}
+
+	remoteToLocal := fmt.Sprintf("%s-%s-to-%s", test.VirtualNetworkPeeringName, test.VirtualNetworkSpokeName, test.VirtualNetworkName)
+	peering, exists := mocks.resources[virtualNetworkPeeringType][remoteToLocal]
+	if !exists {
+		t.Fatalf("missing virtual network peering: %s", remoteToLocal)
+	}
+
+	if !peering["useRemoteGateways"].BoolValue() {
+		t.Errorf("expected virtual network peering %s to use remote gateways", remoteToLocal)
+	}
+}
+
+func TestValidateVirtualNetworkPeering(t *testing.T) {
+	var testCases = []struct {
+		name          string
+		input         *VirtualNetworkPeeringInput
+		localGateways []pulumi.Resource
+		expectedErr   bool
+	}{
+		{
+			name:  "without remote gateways",
+			input: &VirtualNetworkPeeringInput{Name: test.VirtualNetworkPeeringName},
+		},
+		{
+			name:          "with remote gateways",
+			input:         &VirtualNetworkPeeringInput{AllowGatewayTransit: true, Name: test.VirtualNetworkPeeringName, UseRemoteGateways: true},
+			localGateways: []pulumi.Resource{&network.VirtualNetworkGateway{}},
+		},
+		{
+			name:          "without gateway transit",
+			input:         &VirtualNetworkPeeringInput{Name: test.VirtualNetworkPeeringName, UseRemoteGateways: true},
+			localGateways: []pulumi.Resource{&network.VirtualNetworkGateway{}},
+			expectedErr:   true,
+		},
+		{
+			name:        "without VPN gateway",
+			input:       &VirtualNetworkPeeringInput{AllowGatewayTransit: true, Name: test.VirtualNetworkPeeringName, UseRemoteGateways: true},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		if err := validateVirtualNetworkPeering(tc.input, tc.localGateways); (err != nil) != tc.expectedErr {
+			t.Errorf("error mismatch (%s). expected error: %t, actual: %v", tc.name, tc.expectedErr, err)
+		}
+	}
+}
+
+func TestNATGateways(t *testing.T) {
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		publicIPs, err := test.MockPublicIPs(ctx)
+		if err != nil {
+			return err
+		}
+
+		publicIPPrefixes, err := test.MockPublicIPPrefixes(ctx)
+		if err != nil {
+			return err
+		}
+
+		natGateways, err := natGateways(ctx, cfg, publicIPs, publicIPPrefixes, resourceGroup, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		natGateway, exists := natGateways[test.NATGatewayName]
+		if !exists {
+			t.Fatalf("missing NAT gateway: %s", test.NATGatewayName)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		pulumi.All(
+			natGateway.IdleTimeoutInMinutes,
+			natGateway.PublicIpAddressIds,
+			natGateway.PublicIpPrefixIds,
+			natGateway.Zones).ApplyT(func(actuals []interface{}) error {
 			defer wg.Done()
 
-			if len(subnets) != 1 {
-				t.Error("expected number of subnets to be 1")
+			if actual := actuals[0].(*int); actual == nil || *actual != test.NATGatewayIdleTimeoutMinutes {
+				t.Errorf("idle timeout mismatch. expected: %d, actual: %v", test.NATGatewayIdleTimeoutMinutes, actual)
 			}
 
-			subnet := subnets[0]
-			wg.Add(1)
-			pulumi.All(subnet.AddressPrefix, subnet.SecurityGroup).ApplyT(func(actuals []interface{}) error {
-				defer wg.Done()
+			if actual := actuals[1].([]string); len(actual) != 1 || actual[0] != test.PublicIPName+"_id" {
+				t.Errorf("public IP IDs mismatch. expected: [%s_id], actual: %v", test.PublicIPName, actual)
+			}
 
-				if actual := actuals[0].(string); actual != "10.0.0.0/24" {
-					t.Errorf("address prefix mismatch. expected: 10.0.0.0/24, actual: %s", actual)
-				}
+			if actual := actuals[2].([]string); len(actual) != 1 || actual[0] != test.PublicIPPrefixName+"_id" {
+				t.Errorf("public IP prefix IDs mismatch. expected: [%s_id], actual: %v", test.PublicIPPrefixName, actual)
+			}
 
-				if actual := actuals[1].(*string); !strings.HasPrefix(*actual, test.NetworkSecurityGroupName) {
-					t.Errorf("security group name mismatch. expected: %s, actual: %s", test.NetworkSecurityGroupName, *actual)
-				}
+			if actual := actuals[3].([]string); len(actual) != 1 || actual[0] != test.NATGatewayZone {
+				t.Errorf("zones mismatch. expected: [%s], actual: %v", test.NATGatewayZone, actual)
+			}
 
-				return nil
-			})
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRouteTables(t *testing.T) {
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		routeTables, err := routeTables(ctx, cfg, resourceGroup, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		routeTable, exists := routeTables[test.RouteTableName]
+		if !exists {
+			t.Fatalf("missing route table: %s", test.RouteTableName)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		pulumi.All(routeTable.DisableBgpRoutePropagation, routeTable.Routes).ApplyT(func(actuals []interface{}) error {
+			defer wg.Done()
+
+			if actual := actuals[0].(*bool); actual == nil || !*actual {
+				t.Errorf("BGP route propagation mismatch. expected: disabled, actual: %v", actual)
+			}
+
+			routes := actuals[1].([]network.RouteTableRoute)
+			if len(routes) != 2 {
+				t.Fatalf("expected 2 routes, actual: %d", len(routes))
+			}
+
+			if actual := routes[0].NextHopInIpAddress; actual == nil || *actual != test.RouteTableNextHopIPAddress {
+				t.Errorf("next hop IP address mismatch. expected: %s, actual: %v", test.RouteTableNextHopIPAddress, actual)
+			}
+
+			if actual := routes[1].NextHopType; actual != "None" {
+				t.Errorf("next hop type mismatch. expected: None, actual: %s", actual)
+			}
 
 			return nil
 		})
@@ -100,3 +375,710 @@ func TestReconcile(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestValidateRoute(t *testing.T) {
+	var testCases = []struct {
+		input       *RouteInput
+		expectedErr bool
+	}{
+		{input: &RouteInput{AddressPrefix: "0.0.0.0/0", NextHopIPAddress: "10.0.1.4", NextHopType: "VirtualAppliance"}},
+		{input: &RouteInput{AddressPrefix: "10.2.0.0/16", NextHopType: "None"}},
+		{input: &RouteInput{AddressPrefix: "0.0.0.0/0", NextHopType: "VirtualAppliance"}, expectedErr: true},
+		{input: &RouteInput{AddressPrefix: "0.0.0.0/0", NextHopIPAddress: "10.0.1.4", NextHopType: "Internet"}, expectedErr: true},
+		{input: &RouteInput{AddressPrefix: "0.0.0.0/0", NextHopType: "Firewall"}, expectedErr: true},
+		{input: &RouteInput{AddressPrefix: "10.2.0.0", NextHopType: "None"}, expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		if err := validateRoute(tc.input); (err != nil) != tc.expectedErr {
+			t.Errorf("error mismatch for route %+v. expected error: %t, actual: %v", tc.input, tc.expectedErr, err)
+		}
+	}
+}
+
+func TestSubnetArgs(t *testing.T) {
+	var testCases = []struct {
+		input       *SubnetInput
+		expectedErr bool
+	}{
+		{input: &SubnetInput{ServiceEndpoints: []string{"Microsoft.Storage", "Microsoft.Sql"}}},
+		{input: &SubnetInput{Delegations: []*SubnetDelegationInput{{
+			Actions: []string{"Microsoft.Network/virtualNetworks/subnets/action"},
+			Service: "Microsoft.Web/serverFarms"}}}},
+		{input: &SubnetInput{EnforcePrivateLinkEndpointNetworkPolicies: true}},
+		{input: &SubnetInput{ServiceEndpoints: []string{"Microsoft.Unknown"}}, expectedErr: true},
+		{input: &SubnetInput{Delegations: []*SubnetDelegationInput{{Service: "Microsoft.Unknown/things"}}}, expectedErr: true},
+		{input: &SubnetInput{Delegations: []*SubnetDelegationInput{{
+			Actions: []string{"Microsoft.Network/unknown/action"},
+			Service: "Microsoft.Web/serverFarms"}}}, expectedErr: true},
+		{input: &SubnetInput{
+			EnforcePrivateLinkEndpointNetworkPolicies: true,
+			EnforcePrivateLinkServiceNetworkPolicies:  true}, expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		if _, err := subnetArgs(tc.input, pulumi.String(""), &network.VirtualNetwork{}, pulumi.String(test.ResourceGroupName)); (err != nil) != tc.expectedErr {
+			t.Errorf("error mismatch for subnet %+v. expected error: %t, actual: %v", tc.input, tc.expectedErr, err)
+		}
+	}
+}
+
+func TestValidateSecurityRule(t *testing.T) {
+	var testCases = []struct {
+		input       *NetworkSecurityRuleInput
+		expectedErr bool
+	}{
+		{input: &NetworkSecurityRuleInput{
+			DestinationAppSecurityGroups: []string{"web-servers"},
+			DestinationPortRange:         "22",
+			SourceAppSecurityGroups:      []string{"admin-servers"},
+			SourcePortRange:              "*"}},
+		{input: &NetworkSecurityRuleInput{
+			DestinationAddressPrefixes: []string{"10.0.10.0/24", "10.0.20.0/24"},
+			DestinationPortRanges:      []string{"80", "443"},
+			SourceAddressPrefix:        "Internet",
+			SourcePortRanges:           []string{"1024-65535"}}},
+		{input: &NetworkSecurityRuleInput{
+			DestinationAddressPrefix:     "VirtualNetwork",
+			DestinationAppSecurityGroups: []string{"web-servers"},
+			DestinationPortRange:         "22",
+			SourceAddressPrefix:          "*",
+			SourcePortRange:              "*"}, expectedErr: true},
+		{input: &NetworkSecurityRuleInput{
+			DestinationAddressPrefix: "VirtualNetwork",
+			DestinationPortRange:     "22",
+			SourceAddressPrefix:      "*",
+			SourceAddressPrefixes:    []string{"10.0.0.0/8"},
+			SourcePortRange:          "*"}, expectedErr: true},
+		{input: &NetworkSecurityRuleInput{
+			DestinationAddressPrefix: "VirtualNetwork",
+			DestinationPortRange:     "22",
+			DestinationPortRanges:    []string{"22"},
+			SourceAddressPrefix:      "*",
+			SourcePortRange:          "*"}, expectedErr: true},
+		{input: &NetworkSecurityRuleInput{
+			DestinationAddressPrefix: "VirtualNetwork",
+			DestinationPortRange:     "22",
+			SourcePortRange:          "*"}, expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		if err := validateSecurityRule(tc.input); (err != nil) != tc.expectedErr {
+			t.Errorf("error mismatch for rule %+v. expected error: %t, actual: %v", tc.input, tc.expectedErr, err)
+		}
+	}
+}
+
+func TestExpandSecurityRule(t *testing.T) {
+	appSecGroups := map[string]*network.ApplicationSecurityGroup{
+		"admin-servers": nil,
+		"web-servers":   nil,
+	}
+
+	var testCases = []struct {
+		input       string
+		expected    NetworkSecurityRuleInput
+		expectedErr bool
+	}{
+		{
+			input: `"allow https from Internet to web-servers"`,
+			expected: NetworkSecurityRuleInput{
+				Access:                       "Allow",
+				Description:                  "allow https from Internet to web-servers",
+				DestinationAppSecurityGroups: []string{"web-servers"},
+				DestinationPortRange:         "443",
+				Direction:                    "Inbound",
+				Name:                         "allow-https-from-internet-to-web-servers",
+				Protocol:                     "Tcp",
+				Rule:                         "allow https from Internet to web-servers",
+				Service:                      "https",
+				SourceAddressPrefix:          "Internet",
+				SourcePortRange:              "*",
+			},
+		},
+		{
+			input: `{"name": "bastion", "priority": 300, "rule": "allow bastion-data-plane from VirtualNetwork to 10.0.1.0/24,10.0.2.0/24 outbound"}`,
+			expected: NetworkSecurityRuleInput{
+				Access:                     "Allow",
+				Description:                "allow bastion-data-plane from VirtualNetwork to 10.0.1.0/24,10.0.2.0/24 outbound",
+				DestinationAddressPrefixes: []string{"10.0.1.0/24", "10.0.2.0/24"},
+				DestinationPortRanges:      []string{"5701", "8080"},
+				Direction:                  "Outbound",
+				Name:                       "bastion",
+				Priority:                   300,
+				Protocol:                   "*",
+				Rule:                       "allow bastion-data-plane from VirtualNetwork to 10.0.1.0/24,10.0.2.0/24 outbound",
+				Service:                    "bastion-data-plane",
+				SourceAddressPrefix:        "VirtualNetwork",
+				SourcePortRange:            "*",
+			},
+		},
+		{
+			input: `"deny http from * to 10.0.1.0/24,10.0.2.0/24"`,
+			expected: NetworkSecurityRuleInput{
+				Access:                     "Deny",
+				Description:                "deny http from * to 10.0.1.0/24,10.0.2.0/24",
+				DestinationAddressPrefixes: []string{"10.0.1.0/24", "10.0.2.0/24"},
+				DestinationPortRange:       "80",
+				Direction:                  "Inbound",
+				Name:                       "deny-http-from-any-to-10.0.1.0-24-10.0.2.0-24",
+				Protocol:                   "Tcp",
+				Rule:                       "deny http from * to 10.0.1.0/24,10.0.2.0/24",
+				Service:                    "http",
+				SourceAddressPrefix:        "*",
+				SourcePortRange:            "*",
+			},
+		},
+		{input: `"allow telnet from Internet to web-servers"`, expectedErr: true},
+		{input: `"permit ssh from Internet to web-servers"`, expectedErr: true},
+		{input: `"allow ssh from admin-servers,Internet to web-servers"`, expectedErr: true},
+		{input: `"allow ssh to web-servers"`, expectedErr: true},
+		{input: `{"name": "ssh", "service": "ssh", "destinationPortRange": "2222"}`, expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		var input NetworkSecurityRuleInput
+		if err := json.Unmarshal([]byte(tc.input), &input); err != nil {
+			t.Fatal(err)
+		}
+
+		err := expandSecurityRule(&input, appSecGroups)
+		if (err != nil) != tc.expectedErr {
+			t.Errorf("error mismatch for rule %s. expected error: %t, actual: %v", tc.input, tc.expectedErr, err)
+			continue
+		}
+
+		if err == nil && !reflect.DeepEqual(input, tc.expected) {
+			t.Errorf("expanded rule mismatch. expected: %+v, actual: %+v", tc.expected, input)
+		}
+	}
+}
+
+func TestSecurityRuleName(t *testing.T) {
+	valid := regexp.MustCompile(`^[a-z0-9]([a-z0-9_.-]*[a-z0-9_])?$`)
+
+	var testCases = []string{
+		"allow http from * to web-servers",
+		"allow ssh from 10.0.0.0/16,192.168.0.0/24 to admin-servers outbound",
+		"allow https from " + strings.Repeat("10.0.0.0/16,", 10) + "10.1.0.0/16 to web-servers",
+	}
+
+	for _, tc := range testCases {
+		actual := securityRuleName(strings.Fields(tc))
+		if len(actual) > maxRuleNameLength || !valid.MatchString(actual) {
+			t.Errorf("invalid rule name of %q: %s", tc, actual)
+		}
+	}
+}
+
+func TestAssignPriorities(t *testing.T) {
+	inputs := []*NetworkSecurityRuleInput{
+		{Direction: "Inbound"},
+		{Direction: "Inbound", Priority: 101},
+		{Direction: "Inbound"},
+		{Direction: "Outbound"},
+		{Direction: "Inbound", Priority: 100},
+	}
+
+	if err := assignPriorities(inputs); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int{102, 101, 103, 100, 100}
+	for i, input := range inputs {
+		if input.Priority != expected[i] {
+			t.Errorf("priority mismatch of rule %d. expected: %d, actual: %d", i, expected[i], input.Priority)
+		}
+	}
+}
+
+func TestTemplateSubnetParameters(t *testing.T) {
+	input := &SubnetInput{
+		AddressPrefix:           "10.0.1.0/24",
+		IPv6AddressPrefix:       test.SubnetDualStackIPv6AddressPrefix,
+		Name:                    test.SubnetDualStackName,
+		ServiceEndpointPolicies: []string{test.ServiceEndpointPolicyName},
+		ServiceEndpoints:        []string{test.SubnetServiceEndpoint},
+	}
+
+	content, err := templateSubnetParameters(input, input.AddressPrefix, "", "nsg_id", "", []string{"policy_id"}, test.VirtualNetworkName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual map[string]struct {
+		Value interface{}
+	}
+	if err := json.Unmarshal([]byte(content), &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"addressPrefixes":                   []interface{}{"10.0.1.0/24", test.SubnetDualStackIPv6AddressPrefix},
+		"delegations":                       []interface{}{},
+		"natGatewayId":                      "",
+		"networkSecurityGroupId":            "nsg_id",
+		"privateEndpointNetworkPolicies":    "Enabled",
+		"privateLinkServiceNetworkPolicies": "Enabled",
+		"routeTableId":                      "",
+		"serviceEndpointPolicies":           []interface{}{map[string]interface{}{"id": "policy_id"}},
+		"serviceEndpoints":                  []interface{}{map[string]interface{}{"service": test.SubnetServiceEndpoint}},
+		"subnetName":                        test.SubnetDualStackName,
+		"virtualNetworkName":                test.VirtualNetworkName,
+	}
+
+	if len(actual) != len(expected) {
+		t.Errorf("parameters count mismatch. expected: %d, actual: %d", len(expected), len(actual))
+	}
+
+	for name, value := range expected {
+		if !reflect.DeepEqual(actual[name].Value, value) {
+			t.Errorf("parameter %s mismatch. expected: %v, actual: %v", name, value, actual[name].Value)
+		}
+	}
+}
+
+func TestServiceEndpointPolicyParameters(t *testing.T) {
+	input := &ServiceEndpointPolicyInput{
+		Definitions: []*ServiceEndpointPolicyDefinitionInput{{
+			Name:             "allow-backups",
+			Service:          test.SubnetServiceEndpoint,
+			ServiceResources: []string{"/subscriptions/00000000/resourceGroups/test-backups"},
+		}},
+		Name: test.ServiceEndpointPolicyName,
+	}
+
+	if err := validateServiceEndpointPolicy(input); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := serviceEndpointPolicyParameters(input, test.Location, map[string]string{"key": "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual map[string]struct {
+		Value interface{}
+	}
+	if err := json.Unmarshal([]byte(content), &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := actual["policyName"].Value; actual != test.ServiceEndpointPolicyName {
+		t.Errorf("policy name mismatch. expected: %s, actual: %v", test.ServiceEndpointPolicyName, actual)
+	}
+
+	definitions := actual["definitions"].Value.([]interface{})
+	if len(definitions) != 1 {
+		t.Fatalf("definitions count mismatch. expected: 1, actual: %d", len(definitions))
+	}
+
+	properties := definitions[0].(map[string]interface{})["properties"].(map[string]interface{})
+	if actual := properties["service"]; actual != test.SubnetServiceEndpoint {
+		t.Errorf("service mismatch. expected: %s, actual: %v", test.SubnetServiceEndpoint, actual)
+	}
+
+	input.Definitions[0].Service = "Microsoft.Sql"
+	if err := validateServiceEndpointPolicy(input); err == nil {
+		t.Error("expected error didn't occur")
+	}
+
+	if _, err := subnetArgs(&SubnetInput{Name: test.SubnetName, ServiceEndpointPolicies: []string{test.ServiceEndpointPolicyName}},
+		pulumi.String("10.0.0.0/24"), &network.VirtualNetwork{}, pulumi.String(test.ResourceGroupName)); err == nil {
+		t.Error("expected error didn't occur")
+	}
+}
+
+func TestValidateIPv6AddressPrefix(t *testing.T) {
+	addressSpaces := []string{test.VirtualNetworkAddressSpace, test.VirtualNetworkIPv6AddressSpace}
+
+	var testCases = []struct {
+		prefix      string
+		expectedErr bool
+	}{
+		{prefix: test.SubnetDualStackIPv6AddressPrefix},
+		{prefix: "fd00:db9:0:1::/64", expectedErr: true},
+		{prefix: "10.0.1.0/24", expectedErr: true},
+		{prefix: "fd00:db8:0:1::", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		input := &SubnetInput{IPv6AddressPrefix: tc.prefix}
+		if err := validateIPv6AddressPrefix(input, addressSpaces); (err != nil) != tc.expectedErr {
+			t.Errorf("error mismatch for prefix %s. expected error: %t, actual: %v", tc.prefix, tc.expectedErr, err)
+		}
+	}
+}
+
+func TestCarve(t *testing.T) {
+	var testCases = []struct {
+		subnets     []*SubnetInput
+		previous    map[string]string
+		expected    map[string]string
+		expectedErr bool
+	}{
+		{
+			subnets: []*SubnetInput{
+				{Name: "a", AddressPrefix: "10.0.0.0/24"},
+				{Name: "b", Size: "/26"},
+				{Name: "c", Size: "/24"},
+				{Name: "d", Size: "100"},
+			},
+			expected: map[string]string{"b": "10.0.2.128/26", "c": "10.0.1.0/24", "d": "10.0.2.0/25"},
+		},
+		{
+			// previous prefixes are kept, and new subnets don't move them
+			subnets: []*SubnetInput{
+				{Name: "a", Size: "/24"},
+				{Name: "b", Size: "/24"},
+				{Name: "c", Size: "/22"},
+			},
+			previous: map[string]string{"a": "10.0.1.0/24", "b": "10.0.0.0/24"},
+			expected: map[string]string{"a": "10.0.1.0/24", "b": "10.0.0.0/24", "c": "10.0.4.0/22"},
+		},
+		{
+			// resized subnets get a new prefix
+			subnets:  []*SubnetInput{{Name: "a", Size: "/25"}},
+			previous: map[string]string{"a": "10.0.1.0/24"},
+			expected: map[string]string{"a": "10.0.0.0/25"},
+		},
+		{
+			subnets:     []*SubnetInput{{Name: "a", Size: "/15"}},
+			expectedErr: true,
+		},
+		{
+			subnets:     []*SubnetInput{{Name: "a", Size: "/17"}, {Name: "b", Size: "/17"}, {Name: "c", Size: "/29"}},
+			expectedErr: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		actual, err := carve("10.0.0.0/16", tc.subnets, tc.previous)
+		if (err != nil) != tc.expectedErr {
+			t.Errorf("error mismatch in test case %d. expected error: %t, actual: %v", i, tc.expectedErr, err)
+			continue
+		}
+
+		if err == nil && !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("carved prefixes mismatch in test case %d. expected: %v, actual: %v", i, tc.expected, actual)
+		}
+	}
+}
+
+func TestPreviousAddressPrefixes(t *testing.T) {
+	var testCases = []struct {
+		outputs     map[string]interface{}
+		expected    map[string]string
+		expectedErr bool
+	}{
+		{outputs: map[string]interface{}{}, expected: map[string]string{}},
+		{
+			outputs:  map[string]interface{}{carvedAddressPrefixesOutput: map[string]interface{}{"a": "10.0.1.0/24"}},
+			expected: map[string]string{"a": "10.0.1.0/24"},
+		},
+		{
+			outputs:     map[string]interface{}{carvedAddressPrefixesOutput: "10.0.1.0/24"},
+			expectedErr: true,
+		},
+		{
+			outputs:     map[string]interface{}{carvedAddressPrefixesOutput: map[string]interface{}{"a": 24}},
+			expectedErr: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		actual, err := previousAddressPrefixes(tc.outputs)
+		if (err != nil) != tc.expectedErr {
+			t.Errorf("error mismatch in test case %d. expected error: %t, actual: %v", i, tc.expectedErr, err)
+			continue
+		}
+
+		if err == nil && !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("previous prefixes mismatch in test case %d. expected: %v, actual: %v", i, tc.expected, actual)
+		}
+	}
+}
+
+func TestPrefixLength(t *testing.T) {
+	var testCases = []struct {
+		size        string
+		expected    int
+		expectedErr bool
+	}{
+		{size: "/24", expected: 24},
+		{size: "/29", expected: 29},
+		{size: "3", expected: 29},
+		{size: "4", expected: 28},
+		{size: "251", expected: 24},
+		{size: "252", expected: 23},
+		{size: "/30", expectedErr: true},
+		{size: "/7", expectedErr: true},
+		{size: "0", expectedErr: true},
+		{size: "large", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		actual, err := prefixLength(tc.size)
+		if (err != nil) != tc.expectedErr {
+			t.Errorf("error mismatch for size %s. expected error: %t, actual: %v", tc.size, tc.expectedErr, err)
+			continue
+		}
+
+		if actual != tc.expected {
+			t.Errorf("prefix length mismatch for size %s. expected: %d, actual: %d", tc.size, tc.expected, actual)
+		}
+	}
+}
+
+func TestCIDRsOverlap(t *testing.T) {
+	var testCases = []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "10.0.0.0/16", b: "10.1.0.0/16", expected: false},
+		{a: "10.0.0.0/16", b: "10.0.10.0/24", expected: true},
+		{a: "10.0.10.0/24", b: "10.0.0.0/8", expected: true},
+		{a: "10.0.0.0/16", b: "10.0.0.0/16", expected: true},
+	}
+
+	for _, tc := range testCases {
+		actual, err := cidrsOverlap(tc.a, tc.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual != tc.expected {
+			t.Errorf("mismatch overlap of %s and %s. expected: %t, actual: %t", tc.a, tc.b, tc.expected, actual)
+		}
+	}
+
+	if _, err := cidrsOverlap("10.0.0.0", "10.1.0.0/16"); err == nil {
+		t.Error("expected error didn't occur")
+	}
+}
+
+func TestRulesConflict(t *testing.T) {
+	allowHTTP := &NetworkSecurityRuleInput{
+		Access:                       "Allow",
+		DestinationAppSecurityGroups: []string{test.AppSecGroupName},
+		DestinationPortRange:         "80",
+		Direction:                    "Inbound",
+		Protocol:                     "Tcp",
+		SourceAddressPrefix:          "10.0.0.0/16",
+		SourcePortRange:              "*",
+	}
+
+	var testCases = []struct {
+		rule     *NetworkSecurityRuleInput
+		expected bool
+	}{
+		{rule: &NetworkSecurityRuleInput{Access: "Deny", DestinationAddressPrefix: "*", DestinationPortRange: "*", Direction: "Inbound", Protocol: "*", SourceAddressPrefix: "*"}, expected: true},
+		{rule: &NetworkSecurityRuleInput{Access: "Deny", DestinationAppSecurityGroups: []string{test.AppSecGroupName}, DestinationPortRanges: []string{"22", "8000-8080"}, Direction: "Inbound", Protocol: "Tcp", SourceAddressPrefix: "*"}, expected: false},
+		{rule: &NetworkSecurityRuleInput{Access: "Deny", DestinationAppSecurityGroups: []string{test.AppSecGroupName}, DestinationPortRange: "1-1024", Direction: "Inbound", Protocol: "Tcp", SourceAddressPrefixes: []string{"10.0.1.0/24"}}, expected: true},
+		{rule: &NetworkSecurityRuleInput{Access: "Deny", DestinationAppSecurityGroups: []string{test.AppSecGroupName}, DestinationPortRange: "80", Direction: "Inbound", Protocol: "Tcp", SourceAddressPrefix: "192.168.0.0/16"}, expected: false},
+		{rule: &NetworkSecurityRuleInput{Access: "Deny", DestinationAppSecurityGroups: []string{test.AppSecGroupName}, DestinationPortRange: "80", Direction: "Inbound", Protocol: "Udp", SourceAddressPrefix: "*"}, expected: false},
+		{rule: &NetworkSecurityRuleInput{Access: "Deny", DestinationAddressPrefix: "*", DestinationPortRange: "80", Direction: "Outbound", Protocol: "Tcp", SourceAddressPrefix: "*"}, expected: false},
+		{rule: &NetworkSecurityRuleInput{Access: "Allow", DestinationAddressPrefix: "*", DestinationPortRange: "80", Direction: "Inbound", Protocol: "Tcp", SourceAddressPrefix: "*"}, expected: false},
+	}
+
+	for i, tc := range testCases {
+		if actual := rulesConflict(allowHTTP, tc.rule); actual != tc.expected {
+			t.Errorf("conflict mismatch of rule %d. expected: %t, actual: %t", i, tc.expected, actual)
+		}
+	}
+}
+
+func TestShadowed(t *testing.T) {
+	var (
+		allowHTTP = &NetworkSecurityRuleInput{Access: "Allow", DestinationAddressPrefix: "10.0.1.0/24", DestinationPortRange: "80",
+			Direction: "Inbound", Priority: 100, Protocol: "Tcp", SourceAddressPrefix: "*"}
+		allowSSH = &NetworkSecurityRuleInput{Access: "Allow", DestinationAddressPrefix: "10.0.1.0/24", DestinationPortRange: "22",
+			Direction: "Inbound", Priority: 200, Protocol: "Tcp", SourceAddressPrefix: "10.0.0.0/16"}
+		denyAll = &NetworkSecurityRuleInput{Access: "Deny", DestinationAddressPrefix: "*", DestinationPortRange: "*",
+			Direction: "Inbound", Priority: 4096, Protocol: "*", SourceAddressPrefix: "*"}
+		denyAllFirst = &NetworkSecurityRuleInput{Access: "Deny", DestinationAddressPrefix: "*", DestinationPortRange: "*",
+			Direction: "Inbound", Priority: 50, Protocol: "*", SourceAddressPrefix: "*"}
+		otherAllowHTTP = &NetworkSecurityRuleInput{Access: "Allow", DestinationAddressPrefix: "10.0.1.4/32", DestinationPortRange: "80",
+			Direction: "Inbound", Priority: 300, Protocol: "Tcp", SourceAddressPrefix: "*"}
+		otherAllowSSH = &NetworkSecurityRuleInput{Access: "Allow", DestinationAddressPrefix: "10.0.1.4/32", DestinationPortRange: "22",
+			Direction: "Inbound", Priority: 300, Protocol: "Tcp", SourceAddressPrefix: "*"}
+	)
+
+	var testCases = []struct {
+		name     string
+		rule     *NetworkSecurityRuleInput
+		group    []*NetworkSecurityRuleInput
+		other    *NetworkSecurityRuleInput
+		expected bool
+	}{
+		{
+			// the low precedence deny-all doesn't apply to the HTTP traffic
+			name:     "allowed before deny-all",
+			rule:     denyAll,
+			group:    []*NetworkSecurityRuleInput{allowHTTP, denyAll},
+			other:    otherAllowHTTP,
+			expected: true,
+		},
+		{
+			// only SSH from 10.0.0.0/16 is allowed, so the rest is denied
+			name:  "partially allowed before deny-all",
+			rule:  denyAll,
+			group: []*NetworkSecurityRuleInput{allowSSH, denyAll},
+			other: otherAllowSSH,
+		},
+		{
+			name:     "denied before allow",
+			rule:     allowHTTP,
+			group:    []*NetworkSecurityRuleInput{denyAllFirst, allowHTTP},
+			other:    otherAllowHTTP,
+			expected: true,
+		},
+		{
+			name:  "highest precedence",
+			rule:  allowHTTP,
+			group: []*NetworkSecurityRuleInput{allowHTTP, denyAll},
+			other: denyAll,
+		},
+	}
+
+	for _, tc := range testCases {
+		if actual := shadowed(tc.rule, tc.group, tc.other); actual != tc.expected {
+			t.Errorf("shadowed mismatch (%s). expected: %t, actual: %t", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestEndpointsOverlap(t *testing.T) {
+	var testCases = []struct {
+		prefixesA, appSecGroupsA []string
+		prefixesB, appSecGroupsB []string
+		expected                 bool
+	}{
+		{prefixesA: []string{"10.0.0.0/16"}, prefixesB: []string{"10.0.1.0/24"}, expected: true},
+		{prefixesA: []string{"10.0.0.0/16"}, prefixesB: []string{"192.168.0.0/16"}},
+		{prefixesA: []string{"VirtualNetwork"}, prefixesB: []string{"Internet"}},
+		{prefixesA: []string{"*"}, prefixesB: []string{"Internet"}, expected: true},
+		{appSecGroupsA: []string{test.AppSecGroupName}, prefixesB: []string{"192.168.0.0/16"}, expected: true},
+		{appSecGroupsA: []string{test.AppSecGroupName}, appSecGroupsB: []string{"other"}, expected: true},
+	}
+
+	for i, tc := range testCases {
+		if actual := endpointsOverlap("", tc.prefixesA, tc.appSecGroupsA, "", tc.prefixesB, tc.appSecGroupsB); actual != tc.expected {
+			t.Errorf("overlap mismatch of test case %d. expected: %t, actual: %t", i, tc.expected, actual)
+		}
+	}
+}
+
+func TestSecurityGroupConflicts(t *testing.T) {
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		appSecGroups, err := test.MockApplicationSecurityGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		conflicts, err := SecurityGroupConflicts(cfg, appSecGroups, test.NetworkSecurityGroupName, test.NetworkSecurityGroupHostName)
+		if err != nil {
+			return err
+		}
+
+		if len(conflicts) != 1 {
+			t.Errorf("conflicts count mismatch. expected: 1, actual: %d (%v)", len(conflicts), conflicts)
+		}
+
+		if _, err := SecurityGroupConflicts(cfg, appSecGroups, test.NetworkSecurityGroupName, "missing"); err == nil {
+			t.Error("expected error didn't occur")
+		}
+
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSecurityGroupConflictsAssignedPriorities(t *testing.T) {
+	// the rules of the subnet group have no priority, so the allow ssh rule
+	// takes precedence over the deny all rule, as it's listed first
+	conflictsConfig := map[string]string{}
+	for key, value := range test.Config {
+		conflictsConfig[key] = value
+	}
+
+	conflictsConfig[fmt.Sprintf("%s:networkSecurityRules", test.ConfigNamespace)] = `
+[{
+	"access": "Allow",
+	"destinationAddressPrefix": "*",
+	"destinationPortRange": "22",
+	"direction": "Inbound",
+	"name": "subnet-allow-ssh",
+	"protocol": "Tcp",
+	"sourceAddressPrefix": "*",
+	"sourcePortRange": "*"
+},
+{
+	"access": "Deny",
+	"destinationAddressPrefix": "*",
+	"destinationPortRange": "*",
+	"direction": "Inbound",
+	"name": "subnet-deny-all",
+	"protocol": "Tcp",
+	"sourceAddressPrefix": "*",
+	"sourcePortRange": "*"
+},
+{
+	"access": "Allow",
+	"destinationAddressPrefix": "*",
+	"destinationPortRange": "22",
+	"direction": "Inbound",
+	"name": "host-allow-ssh",
+	"priority": 100,
+	"protocol": "Tcp",
+	"sourceAddressPrefix": "*",
+	"sourcePortRange": "*"
+},
+{
+	"access": "Allow",
+	"destinationAddressPrefix": "*",
+	"destinationPortRange": "80",
+	"direction": "Inbound",
+	"name": "host-allow-http",
+	"priority": 110,
+	"protocol": "Tcp",
+	"sourceAddressPrefix": "*",
+	"sourcePortRange": "*"
+}]`
+
+	conflictsConfig[fmt.Sprintf("%s:networkSecurityGroups", test.ConfigNamespace)] = `
+[{
+	"name": "subnet",
+	"securityRules": ["subnet-allow-ssh", "subnet-deny-all"]
+},
+{
+	"name": "host",
+	"securityRules": ["host-allow-ssh", "host-allow-http"]
+}]`
+
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		appSecGroups, err := test.MockApplicationSecurityGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		conflicts, err := SecurityGroupConflicts(cfg, appSecGroups, "subnet", "host")
+		if err != nil {
+			return err
+		}
+
+		expected := []string{"deny rule subnet-deny-all of network security group subnet contradicts allow rule host-allow-http of network security group host"}
+		if !reflect.DeepEqual(conflicts, expected) {
+			t.Errorf("conflicts mismatch. expected: %v, actual: %v", expected, conflicts)
+		}
+
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, conflictsConfig, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
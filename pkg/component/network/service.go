@@ -0,0 +1,223 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+)
+
+const (
+	firstPriority     = 100
+	lastPriority      = 4096
+	maxRuleNameLength = 80
+)
+
+var (
+	invalidRuleNameChars = regexp.MustCompile(`[^a-z0-9_.-]`)
+	repeatedHyphens      = regexp.MustCompile(`-{2,}`)
+)
+
+// service is a named protocol and set of destination ports which security
+// rules can refer to.
+type service struct {
+	portRanges []string
+	protocol   string
+}
+
+// services is the catalog of named services.
+var services = map[string]service{
+	"azure-lb-probe":     {portRanges: []string{"*"}, protocol: "*"},
+	"bastion-data-plane": {portRanges: []string{"5701", "8080"}, protocol: "*"},
+	"http":               {portRanges: []string{"80"}, protocol: "Tcp"},
+	"https":              {portRanges: []string{"443"}, protocol: "Tcp"},
+	"rdp":                {portRanges: []string{"3389"}, protocol: "Tcp"},
+	"ssh":                {portRanges: []string{"22"}, protocol: "Tcp"},
+}
+
+// UnmarshalJSON accepts a security rule as either a full rule object, or a
+// compact rule string such as "allow https from Internet to web-servers".
+func (n *NetworkSecurityRuleInput) UnmarshalJSON(data []byte) error {
+	var rule string
+	if err := json.Unmarshal(data, &rule); err == nil {
+		*n = NetworkSecurityRuleInput{Rule: rule}
+		return nil
+	}
+
+	type plain NetworkSecurityRuleInput
+	return json.Unmarshal(data, (*plain)(n))
+}
+
+// expandSecurityRule fills in the fields of a security rule from its compact
+// rule and named service. The compact rule syntax is:
+//
+//	<allow|deny> <service> from <source> to <destination> [inbound|outbound]
+//
+// The source and destination are comma-separated lists of application
+// security groups, or of address prefixes and service tags. Rules are
+// inbound by default, and are named after their compact rule unless a name
+// is given.
+func expandSecurityRule(input *NetworkSecurityRuleInput, appSecGroups map[string]*network.ApplicationSecurityGroup) error {
+	if len(input.Rule) > 0 {
+		fields := strings.Fields(input.Rule)
+		if (len(fields) != 6 && len(fields) != 7) || fields[2] != "from" || fields[4] != "to" {
+			return pulumierr.InvalidConfigErr{input.Rule, "network security rule",
+				"expected <allow|deny> <service> from <source> to <destination> [inbound|outbound]"}
+		}
+
+		switch fields[0] {
+		case "allow", "deny":
+			input.Access = strings.Title(fields[0])
+		default:
+			return pulumierr.InvalidConfigErr{input.Rule, "network security rule",
+				fmt.Sprintf("unknown access %q", fields[0])}
+		}
+
+		input.Direction = "Inbound"
+		if len(fields) == 7 {
+			switch fields[6] {
+			case "inbound", "outbound":
+				input.Direction = strings.Title(fields[6])
+			default:
+				return pulumierr.InvalidConfigErr{input.Rule, "network security rule",
+					fmt.Sprintf("unknown direction %q", fields[6])}
+			}
+		}
+
+		if len(input.Name) == 0 {
+			input.Name = securityRuleName(fields)
+		}
+
+		if len(input.Description) == 0 {
+			input.Description = input.Rule
+		}
+
+		input.Service = fields[1]
+		input.SourcePortRange = "*"
+
+		var err error
+		input.SourceAddressPrefix, input.SourceAddressPrefixes, input.SourceAppSecurityGroups, err = endpoint(input, fields[3], appSecGroups)
+		if err != nil {
+			return err
+		}
+
+		input.DestinationAddressPrefix, input.DestinationAddressPrefixes, input.DestinationAppSecurityGroups, err = endpoint(input, fields[5], appSecGroups)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(input.Service) > 0 {
+		service, exists := services[input.Service]
+		if !exists {
+			return pulumierr.InvalidConfigErr{input.Name, "network security rule",
+				fmt.Sprintf("unknown service %q", input.Service)}
+		}
+
+		if len(input.Protocol) > 0 || len(input.DestinationPortRange) > 0 || len(input.DestinationPortRanges) > 0 {
+			return pulumierr.InvalidConfigErr{input.Name, "network security rule",
+				"service can't be combined with protocol or destination ports"}
+		}
+
+		input.Protocol = service.protocol
+		if len(service.portRanges) == 1 {
+			input.DestinationPortRange = service.portRanges[0]
+		} else {
+			input.DestinationPortRanges = service.portRanges
+		}
+	}
+
+	return nil
+}
+
+// securityRuleName returns the name of a compact rule without an explicit
+// name. Azure rule names may only contain letters, digits, underscores,
+// periods and hyphens, start with a letter or digit, and be at most 80
+// characters long. Wildcards are named "any", and the other invalid
+// characters of address prefixes are replaced by hyphens.
+func securityRuleName(fields []string) string {
+	name := strings.ToLower(strings.Join(fields, "-"))
+	name = strings.Replace(name, "*", "any", -1)
+	name = invalidRuleNameChars.ReplaceAllString(name, "-")
+	name = repeatedHyphens.ReplaceAllString(name, "-")
+
+	if len(name) > maxRuleNameLength {
+		name = name[:maxRuleNameLength]
+	}
+
+	return strings.TrimRight(strings.TrimLeft(name, "-._"), "-.")
+}
+
+// endpoint returns the address prefix, address prefixes or application
+// security groups of one side of a compact rule.
+func endpoint(
+	input *NetworkSecurityRuleInput,
+	value string,
+	appSecGroups map[string]*network.ApplicationSecurityGroup) (string, []string, []string, error) {
+
+	var (
+		names   = strings.Split(value, ",")
+		matches = 0
+	)
+	for _, name := range names {
+		if _, exists := appSecGroups[name]; exists {
+			matches++
+		}
+	}
+
+	switch {
+	case matches == len(names):
+		return "", nil, names, nil
+	case matches > 0:
+		return "", nil, nil, pulumierr.InvalidConfigErr{input.Rule, "network security rule",
+			fmt.Sprintf("%q mixes application security groups and address prefixes", value)}
+	case len(names) == 1:
+		return value, nil, nil, nil
+	default:
+		return "", names, nil, nil
+	}
+}
+
+// assignPriorities assigns the lowest free priorities to the rules without
+// one, in the order of the rules. Priorities are unique per direction.
+func assignPriorities(inputs []*NetworkSecurityRuleInput) error {
+	used := map[string]map[int]bool{}
+	for _, input := range inputs {
+		if used[input.Direction] == nil {
+			used[input.Direction] = map[int]bool{}
+		}
+
+		if input.Priority > 0 {
+			used[input.Direction][input.Priority] = true
+		}
+	}
+
+	next := map[string]int{}
+	for _, input := range inputs {
+		if input.Priority > 0 {
+			continue
+		}
+
+		priority := next[input.Direction]
+		if priority == 0 {
+			priority = firstPriority
+		}
+
+		for used[input.Direction][priority] {
+			priority++
+		}
+
+		if priority > lastPriority {
+			return pulumierr.InvalidConfigErr{input.Name, "network security rule", "no free priorities"}
+		}
+
+		input.Priority = priority
+		used[input.Direction][priority] = true
+		next[input.Direction] = priority + 1
+	}
+
+	return nil
+}
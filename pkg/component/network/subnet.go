@@ -0,0 +1,125 @@
+package network
+
+import (
+	"fmt"
+
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+)
+
+// knownServiceEndpoints are the services which can be reached through subnet
+// service endpoints.
+var knownServiceEndpoints = map[string]bool{
+	"Microsoft.AzureActiveDirectory": true,
+	"Microsoft.AzureCosmosDB":        true,
+	"Microsoft.ContainerRegistry":    true,
+	"Microsoft.EventHub":             true,
+	"Microsoft.KeyVault":             true,
+	"Microsoft.ServiceBus":           true,
+	"Microsoft.Sql":                  true,
+	"Microsoft.Storage":              true,
+	"Microsoft.Web":                  true,
+}
+
+// knownDelegationServices are the services which subnets can be delegated to.
+var knownDelegationServices = map[string]bool{
+	"Microsoft.BareMetal/AzureVMware":                 true,
+	"Microsoft.BareMetal/CrayServers":                 true,
+	"Microsoft.Batch/batchAccounts":                   true,
+	"Microsoft.ContainerInstance/containerGroups":     true,
+	"Microsoft.Databricks/workspaces":                 true,
+	"Microsoft.DBforPostgreSQL/serversv2":             true,
+	"Microsoft.HardwareSecurityModules/dedicatedHSMs": true,
+	"Microsoft.Logic/integrationServiceEnvironments":  true,
+	"Microsoft.Netapp/volumes":                        true,
+	"Microsoft.ServiceFabricMesh/networks":            true,
+	"Microsoft.Sql/managedInstances":                  true,
+	"Microsoft.Sql/servers":                           true,
+	"Microsoft.StreamAnalytics/streamingJobs":         true,
+	"Microsoft.Web/hostingEnvironments":               true,
+	"Microsoft.Web/serverFarms":                       true,
+}
+
+// knownDelegationActions are the actions which can be delegated to a service.
+var knownDelegationActions = map[string]bool{
+	"Microsoft.Network/networkinterfaces/*":                                     true,
+	"Microsoft.Network/virtualNetworks/subnets/action":                          true,
+	"Microsoft.Network/virtualNetworks/subnets/join/action":                     true,
+	"Microsoft.Network/virtualNetworks/subnets/prepareNetworkPolicies/action":   true,
+	"Microsoft.Network/virtualNetworks/subnets/unprepareNetworkPolicies/action": true,
+}
+
+// subnetArgs returns the arguments of the subnet described by input, after
+// validating its service endpoints, service endpoint policies, delegations
+// and private link network policies.
+func subnetArgs(
+	input *SubnetInput,
+	addressPrefix pulumi.StringInput,
+	virtualNetwork *network.VirtualNetwork,
+	resourceGroupName pulumi.StringInput) (*network.SubnetArgs, error) {
+
+	if input.EnforcePrivateLinkEndpointNetworkPolicies && input.EnforcePrivateLinkServiceNetworkPolicies {
+		return nil, pulumierr.InvalidConfigErr{input.Name, "subnet",
+			"private link endpoint and private link service network policies can't both be enforced"}
+	}
+
+	if len(input.ServiceEndpointPolicies) > 0 {
+		hasPolicyServiceEndpoint := false
+		for _, service := range input.ServiceEndpoints {
+			if knownServiceEndpointPolicyServices[service] {
+				hasPolicyServiceEndpoint = true
+			}
+		}
+
+		if !hasPolicyServiceEndpoint {
+			return nil, pulumierr.InvalidConfigErr{input.Name, "subnet",
+				"service endpoint policies require a Microsoft.Storage service endpoint"}
+		}
+	}
+
+	serviceEndpoints := pulumi.StringArray{}
+	for _, service := range input.ServiceEndpoints {
+		if !knownServiceEndpoints[service] {
+			return nil, pulumierr.InvalidConfigErr{input.Name, "subnet",
+				fmt.Sprintf("unknown service endpoint %q", service)}
+		}
+		serviceEndpoints = append(serviceEndpoints, pulumi.String(service))
+	}
+
+	delegations := network.SubnetDelegationArray{}
+	for _, delegation := range input.Delegations {
+		if !knownDelegationServices[delegation.Service] {
+			return nil, pulumierr.InvalidConfigErr{input.Name, "subnet",
+				fmt.Sprintf("unknown delegation service %q", delegation.Service)}
+		}
+
+		actions := pulumi.StringArray{}
+		for _, action := range delegation.Actions {
+			if !knownDelegationActions[action] {
+				return nil, pulumierr.InvalidConfigErr{input.Name, "subnet",
+					fmt.Sprintf("unknown delegation action %q", action)}
+			}
+			actions = append(actions, pulumi.String(action))
+		}
+
+		delegations = append(delegations, network.SubnetDelegationArgs{
+			Name: pulumi.String(delegation.Name),
+			ServiceDelegation: network.SubnetDelegationServiceDelegationArgs{
+				Actions: actions,
+				Name:    pulumi.String(delegation.Service),
+			},
+		})
+	}
+
+	return &network.SubnetArgs{
+		AddressPrefix: addressPrefix,
+		Delegations:   delegations,
+		EnforcePrivateLinkEndpointNetworkPolicies: pulumi.Bool(input.EnforcePrivateLinkEndpointNetworkPolicies),
+		EnforcePrivateLinkServiceNetworkPolicies:  pulumi.Bool(input.EnforcePrivateLinkServiceNetworkPolicies),
+		Name:                                      pulumi.String(input.Name),
+		ResourceGroupName:                         resourceGroupName,
+		ServiceEndpoints:                          serviceEndpoints,
+		VirtualNetworkName:                        virtualNetwork.Name,
+	}, nil
+}
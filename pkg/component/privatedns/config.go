@@ -0,0 +1,22 @@
+package privatedns
+
+// PrivateDNSZoneInput describes a private DNS zone, such as example.internal,
+// and the virtual networks linked to it. If Records is true, A records are
+// created for the internal load balancers of the stack, and for the VM
+// instances, except those in virtual networks that auto-register with the
+// zone.
+type PrivateDNSZoneInput struct {
+	Name                string
+	Records             bool
+	TTL                 int `json:"ttl"`
+	VirtualNetworkLinks []*VirtualNetworkLinkInput
+}
+
+// VirtualNetworkLinkInput links a virtual network to a private DNS zone. With
+// RegistrationEnabled, the VMs of the virtual network register their own A
+// records in the zone. A virtual network can only auto-register with one
+// zone.
+type VirtualNetworkLinkInput struct {
+	RegistrationEnabled bool
+	VirtualNetwork      string
+}
@@ -0,0 +1,155 @@
+package privatedns
+
+import (
+	"fmt"
+
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/compute"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/loadbalancer"
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/privatedns"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+const defaultTTL = 300
+
+func Reconcile(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	loadBalancers map[string]*loadbalancer.LoadBalancer,
+	resourceGroup *core.ResourceGroup,
+	virtualNetworks map[string]*network.VirtualNetwork,
+	vmGroups map[string]*compute.VMGroup,
+	tags pulumi.StringMap) (map[string]*privatedns.Zone, error) {
+
+	zoneInput := []*PrivateDNSZoneInput{}
+	if err := cfg.GetObject("privateDnsZones", &zoneInput); err != nil {
+		return nil, err
+	}
+
+	registrations := map[string]string{}
+	zones := map[string]*privatedns.Zone{}
+	for _, input := range zoneInput {
+		zone, err := privatedns.NewZone(ctx, input.Name, &privatedns.ZoneArgs{
+			Name:              pulumi.String(input.Name),
+			ResourceGroupName: resourceGroup.Name,
+			Tags:              tags,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, link := range input.VirtualNetworkLinks {
+			virtualNetwork, exists := virtualNetworks[link.VirtualNetwork]
+			if !exists {
+				return nil, pulumierr.MissingConfigErr{link.VirtualNetwork, "virtual network"}
+			}
+
+			if link.RegistrationEnabled {
+				if registered, exists := registrations[link.VirtualNetwork]; exists {
+					return nil, pulumierr.InvalidConfigErr{input.Name, "private DNS zone",
+						fmt.Sprintf("virtual network %s already auto-registers with %s", link.VirtualNetwork, registered)}
+				}
+				registrations[link.VirtualNetwork] = input.Name
+			}
+
+			linkName := fmt.Sprintf("%s-%s-link", input.Name, link.VirtualNetwork)
+			if _, err := privatedns.NewZoneVirtualNetworkLink(ctx, linkName, &privatedns.ZoneVirtualNetworkLinkArgs{
+				Name:                pulumi.String(linkName),
+				PrivateDnsZoneName:  zone.Name,
+				RegistrationEnabled: pulumi.Bool(link.RegistrationEnabled),
+				ResourceGroupName:   resourceGroup.Name,
+				Tags:                tags,
+				VirtualNetworkId:    virtualNetwork.ID(),
+			}); err != nil {
+				return nil, err
+			}
+		}
+
+		if input.Records {
+			if _, err := aRecords(ctx, input, loadBalancers, resourceGroup, vmGroups, zone, tags); err != nil {
+				return nil, err
+			}
+		}
+
+		zones[input.Name] = zone
+	}
+
+	return zones, nil
+}
+
+// aRecords creates the A records of the VM instances and internal load
+// balancers in the zone, keyed by record names. The records of the VM
+// instances in virtual networks that auto-register with the zone are
+// skipped, as those VMs register their own records.
+func aRecords(
+	ctx *pulumi.Context,
+	input *PrivateDNSZoneInput,
+	loadBalancers map[string]*loadbalancer.LoadBalancer,
+	resourceGroup *core.ResourceGroup,
+	vmGroups map[string]*compute.VMGroup,
+	zone *privatedns.Zone,
+	tags pulumi.StringMap) (map[string]*privatedns.ARecord, error) {
+
+	addresses := map[string]pulumi.StringArrayInput{}
+	for _, vmGroup := range vmGroups {
+		if registrationEnabled(input, vmGroup.VirtualNetwork) {
+			continue
+		}
+
+		for _, instance := range vmGroup.Instances {
+			addresses[instance.Name] = pulumi.StringArray{instance.PrivateIPAddress}
+		}
+	}
+
+	for _, loadBalancer := range loadBalancers {
+		if !loadBalancer.Internal {
+			continue
+		}
+
+		if _, exists := addresses[loadBalancer.Name]; exists {
+			return nil, pulumierr.InvalidConfigErr{input.Name, "private DNS zone",
+				fmt.Sprintf("load balancer %s has the same name as a VM instance", loadBalancer.Name)}
+		}
+		addresses[loadBalancer.Name] = loadBalancer.LoadBalancer.PrivateIpAddresses
+	}
+
+	ttl := input.TTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+
+	records := map[string]*privatedns.ARecord{}
+	for name, address := range addresses {
+		recordName := fmt.Sprintf("%s-%s", input.Name, name)
+		record, err := privatedns.NewARecord(ctx, recordName, &privatedns.ARecordArgs{
+			Name:              pulumi.String(name),
+			Records:           address,
+			ResourceGroupName: resourceGroup.Name,
+			Tags:              tags,
+			Ttl:               pulumi.Int(ttl),
+			ZoneName:          zone.Name,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		records[name] = record
+	}
+
+	return records, nil
+}
+
+// registrationEnabled returns true if the virtual network auto-registers
+// with the zone.
+func registrationEnabled(input *PrivateDNSZoneInput, virtualNetwork string) bool {
+	for _, link := range input.VirtualNetworkLinks {
+		if link.VirtualNetwork == virtualNetwork && link.RegistrationEnabled {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,184 @@
+package privatedns
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/compute"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/component/loadbalancer"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/mock"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/test"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/lb"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/privatedns"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+const (
+	instancePrivateIPAddress = "10.0.1.4"
+	internalLoadBalancerName = "test-internal-lb"
+	publicLoadBalancerName   = "test-public-lb"
+	spokeInstanceName        = "test-spoke-vm-00"
+	spokeVMGroupName         = "test-spoke-vm"
+)
+
+func TestReconcile(t *testing.T) {
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		virtualNetworks, err := test.MockVirtualNetworks(ctx)
+		if err != nil {
+			return err
+		}
+
+		zones, err := Reconcile(ctx, cfg, nil, resourceGroup, virtualNetworks, nil, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		zone, exists := zones[test.PrivateDNSZoneName]
+		if !exists {
+			t.Fatalf("missing private DNS zone: %s", test.PrivateDNSZoneName)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		pulumi.All(zone.Name, zone.ResourceGroupName).ApplyT(func(actuals []interface{}) error {
+			defer wg.Done()
+
+			if actual := actuals[0].(string); actual != test.PrivateDNSZoneName {
+				t.Errorf("zone name mismatch. expected: %s, actual: %s", test.PrivateDNSZoneName, actual)
+			}
+
+			if actual := actuals[1].(string); actual != test.ResourceGroupName {
+				t.Errorf("resource group names mismatch. expected: %s, actual: %s", test.ResourceGroupName, actual)
+			}
+
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestARecords(t *testing.T) {
+	var testCases = []struct {
+		registrationEnabled bool
+		expected            []string
+	}{
+		{registrationEnabled: false, expected: []string{test.VirtualMachineInstanceName, spokeInstanceName, internalLoadBalancerName}},
+		{registrationEnabled: true, expected: []string{spokeInstanceName, internalLoadBalancerName}},
+	}
+
+	for _, tc := range testCases {
+		if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			resourceGroup, err := test.MockResourceGroup(ctx)
+			if err != nil {
+				return err
+			}
+
+			loadBalancers := map[string]*loadbalancer.LoadBalancer{}
+			for _, name := range []string{internalLoadBalancerName, publicLoadBalancerName} {
+				loadBalancer, err := lb.NewLoadBalancer(ctx, name, &lb.LoadBalancerArgs{
+					Name:              pulumi.String(name),
+					ResourceGroupName: resourceGroup.Name,
+				})
+				if err != nil {
+					return err
+				}
+
+				loadBalancers[name] = &loadbalancer.LoadBalancer{
+					Internal:     name == internalLoadBalancerName,
+					LoadBalancer: loadBalancer,
+					Name:         name,
+				}
+			}
+
+			vmGroups := map[string]*compute.VMGroup{
+				test.VirtualMachineName: {
+					Instances: []*compute.VMInstance{{
+						Name:             test.VirtualMachineInstanceName,
+						PrivateIPAddress: pulumi.String(instancePrivateIPAddress).ToStringOutput(),
+					}},
+					Name:           test.VirtualMachineName,
+					VirtualNetwork: test.VirtualNetworkName,
+				},
+				spokeVMGroupName: {
+					Instances: []*compute.VMInstance{{
+						Name:             spokeInstanceName,
+						PrivateIPAddress: pulumi.String(instancePrivateIPAddress).ToStringOutput(),
+					}},
+					Name:           spokeVMGroupName,
+					VirtualNetwork: test.VirtualNetworkSpokeName,
+				},
+			}
+
+			zone, err := privatedns.NewZone(ctx, test.PrivateDNSZoneName, &privatedns.ZoneArgs{
+				Name:              pulumi.String(test.PrivateDNSZoneName),
+				ResourceGroupName: resourceGroup.Name,
+			})
+			if err != nil {
+				return err
+			}
+
+			input := &PrivateDNSZoneInput{
+				Name:    test.PrivateDNSZoneName,
+				Records: true,
+				TTL:     test.PrivateDNSZoneTTL,
+				VirtualNetworkLinks: []*VirtualNetworkLinkInput{{
+					RegistrationEnabled: tc.registrationEnabled,
+					VirtualNetwork:      test.VirtualNetworkName,
+				}},
+			}
+
+			records, err := aRecords(ctx, input, loadBalancers, resourceGroup, vmGroups, zone, test.Tags)
+			if err != nil {
+				return err
+			}
+
+			if len(records) != len(tc.expected) {
+				t.Errorf("records count mismatch. expected: %d, actual: %d", len(tc.expected), len(records))
+			}
+
+			for _, name := range tc.expected {
+				if _, exists := records[name]; !exists {
+					t.Errorf("missing A record: %s", name)
+				}
+			}
+
+			record, exists := records[test.VirtualMachineInstanceName]
+			if !exists {
+				return nil
+			}
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			pulumi.All(record.Records, record.Ttl).ApplyT(func(actuals []interface{}) error {
+				defer wg.Done()
+
+				if actual := actuals[0].([]string); len(actual) != 1 || actual[0] != instancePrivateIPAddress {
+					t.Errorf("records mismatch. expected: [%s], actual: %v", instancePrivateIPAddress, actual)
+				}
+
+				if actual := actuals[1].(int); actual != test.PrivateDNSZoneTTL {
+					t.Errorf("TTL mismatch. expected: %d, actual: %d", test.PrivateDNSZoneTTL, actual)
+				}
+
+				return nil
+			})
+
+			wg.Wait()
+			return nil
+		}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+			t.Error(err)
+		}
+	}
+}
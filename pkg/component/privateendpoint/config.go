@@ -0,0 +1,19 @@
+package privateendpoint
+
+// PrivateEndpointInput describes a private endpoint in a subnet, that connects
+// to a resource managed by this program. Subresource and PrivateDNSZone
+// default to the ones of the kind of the target resource.
+type PrivateEndpointInput struct {
+	Name           string
+	PrivateDNSZone string `json:"privateDnsZone"`
+	Subnet         string
+	Subresource    string
+	Target         *TargetInput
+}
+
+// TargetInput identifies a resource managed by this program by its kind, e.g.
+// recoveryServicesVault, and its name.
+type TargetInput struct {
+	Kind string
+	Name string
+}
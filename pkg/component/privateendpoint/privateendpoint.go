@@ -0,0 +1,227 @@
+package privateendpoint
+
+import (
+	"encoding/json"
+	"fmt"
+
+	vnet "github.com/ihcsim/pulumi-azure/v2/pkg/component/network"
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/privatedns"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/privatelink"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+// targetKind describes the private link subresources of a kind of resource,
+// and the private DNS zones of their endpoints. Subresources without a known
+// zone, e.g. those with regional zones, require an explicit private DNS zone.
+// Endpoints can only target the known kinds that are also managed by this
+// program, i.e. that have targets.
+type targetKind struct {
+	defaultSubresource string
+	zones              map[string]string
+}
+
+var knownTargetKinds = map[string]*targetKind{
+	"keyVault": {
+		defaultSubresource: "vault",
+		zones:              map[string]string{"vault": "privatelink.vaultcore.azure.net"},
+	},
+	"recoveryServicesVault": {
+		defaultSubresource: "AzureBackup",
+		zones:              map[string]string{"AzureBackup": "", "AzureSiteRecovery": ""},
+	},
+	"sqlServer": {
+		defaultSubresource: "sqlServer",
+		zones:              map[string]string{"sqlServer": "privatelink.database.windows.net"},
+	},
+	"storageAccount": {
+		defaultSubresource: "blob",
+		zones: map[string]string{
+			"blob":  "privatelink.blob.core.windows.net",
+			"dfs":   "privatelink.dfs.core.windows.net",
+			"file":  "privatelink.file.core.windows.net",
+			"queue": "privatelink.queue.core.windows.net",
+			"table": "privatelink.table.core.windows.net",
+			"web":   "privatelink.web.core.windows.net",
+		},
+	},
+}
+
+// privateDNSZoneGroupTemplate is the ARM template of the private DNS zone
+// group of a private endpoint, which registers the endpoint in its private DNS
+// zone. The SDK's private endpoint resource doesn't support zone groups, so
+// they are created as template deployments.
+const privateDNSZoneGroupTemplate = `{
+  "$schema": "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
+  "contentVersion": "1.0.0.0",
+  "parameters": {
+    "privateDnsZoneId": {"type": "string"},
+    "privateDnsZoneName": {"type": "string"},
+    "privateEndpointName": {"type": "string"}
+  },
+  "resources": [{
+    "type": "Microsoft.Network/privateEndpoints/privateDnsZoneGroups",
+    "apiVersion": "2020-03-01",
+    "name": "[concat(parameters('privateEndpointName'), '/default')]",
+    "properties": {
+      "privateDnsZoneConfigs": [{
+        "name": "[replace(parameters('privateDnsZoneName'), '.', '-')]",
+        "properties": {
+          "privateDnsZoneId": "[parameters('privateDnsZoneId')]"
+        }
+      }]
+    }
+  }]
+}`
+
+// Reconcile creates the private endpoints of the targets, which are the IDs
+// of the resources managed by this program, keyed by kind and name.
+func Reconcile(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	privateDNSZones map[string]*privatedns.Zone,
+	resourceGroup *core.ResourceGroup,
+	subnets map[string]*network.Subnet,
+	targets map[string]map[string]pulumi.IDOutput,
+	tags pulumi.StringMap) (map[string]*privatelink.Endpoint, error) {
+
+	privateEndpointInput := []*PrivateEndpointInput{}
+	if err := cfg.GetObject("privateEndpoints", &privateEndpointInput); err != nil {
+		return nil, err
+	}
+
+	subnetInput := []*vnet.SubnetInput{}
+	if err := cfg.GetObject("subnets", &subnetInput); err != nil {
+		return nil, err
+	}
+
+	privateEndpoints := map[string]*privatelink.Endpoint{}
+	for _, input := range privateEndpointInput {
+		subresource, zoneName, err := resolve(input, subnetInput, targets)
+		if err != nil {
+			return nil, err
+		}
+
+		targetID, exists := targets[input.Target.Kind][input.Target.Name]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{input.Target.Name, input.Target.Kind}
+		}
+
+		subnet, exists := subnets[input.Subnet]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{input.Subnet, "subnet"}
+		}
+
+		zone, exists := privateDNSZones[zoneName]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{zoneName, "private DNS zone"}
+		}
+
+		privateEndpoint, err := privatelink.NewEndpoint(ctx, input.Name, &privatelink.EndpointArgs{
+			Location: resourceGroup.Location,
+			Name:     pulumi.String(input.Name),
+			PrivateServiceConnection: privatelink.EndpointPrivateServiceConnectionArgs{
+				IsManualConnection:          pulumi.Bool(false),
+				Name:                        pulumi.String(fmt.Sprintf("%s-connection", input.Name)),
+				PrivateConnectionResourceId: targetID.ToStringOutput(),
+				SubresourceNames:            pulumi.StringArray{pulumi.String(subresource)},
+			},
+			ResourceGroupName: resourceGroup.Name,
+			SubnetId:          subnet.ID(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		privateEndpoints[input.Name] = privateEndpoint
+
+		parameters := pulumi.All(privateEndpoint.Name, zone.ID().ToStringOutput(), zone.Name).ApplyT(
+			func(args []interface{}) (string, error) {
+				return privateDNSZoneGroupParameters(args[0].(string), args[1].(string), args[2].(string))
+			}).(pulumi.StringOutput)
+
+		deploymentName := fmt.Sprintf("%s-dns-zone-group", input.Name)
+		if _, err := core.NewTemplateDeployment(ctx, deploymentName, &core.TemplateDeploymentArgs{
+			DeploymentMode:    pulumi.String("Incremental"),
+			Name:              pulumi.String(deploymentName),
+			ParametersBody:    parameters,
+			ResourceGroupName: resourceGroup.Name,
+			TemplateBody:      pulumi.String(privateDNSZoneGroupTemplate),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return privateEndpoints, nil
+}
+
+// resolve returns the subresource and the private DNS zone of the private
+// endpoint. It ensures that the kind of the endpoint's target is managed by
+// this program, and that the network policies of the endpoint's subnet are
+// disabled, as required by private endpoints, unless the subnet isn't managed
+// by this program.
+func resolve(
+	input *PrivateEndpointInput,
+	subnetInput []*vnet.SubnetInput,
+	targets map[string]map[string]pulumi.IDOutput) (string, string, error) {
+
+	if input.Target == nil {
+		return "", "", pulumierr.MissingConfigErr{input.Name, "private endpoint target"}
+	}
+
+	kind, exists := knownTargetKinds[input.Target.Kind]
+	if !exists {
+		return "", "", pulumierr.InvalidConfigErr{input.Name, "private endpoint",
+			fmt.Sprintf("unknown target kind %q", input.Target.Kind)}
+	}
+
+	if _, managed := targets[input.Target.Kind]; !managed {
+		return "", "", pulumierr.InvalidConfigErr{input.Name, "private endpoint",
+			fmt.Sprintf("target kind %q isn't managed by this program", input.Target.Kind)}
+	}
+
+	subresource := input.Subresource
+	if len(subresource) == 0 {
+		subresource = kind.defaultSubresource
+	}
+
+	zoneName, exists := kind.zones[subresource]
+	if !exists {
+		return "", "", pulumierr.InvalidConfigErr{input.Name, "private endpoint",
+			fmt.Sprintf("unknown subresource %q of %s", subresource, input.Target.Kind)}
+	}
+
+	if len(input.PrivateDNSZone) > 0 {
+		zoneName = input.PrivateDNSZone
+	}
+
+	if len(zoneName) == 0 {
+		return "", "", pulumierr.MissingConfigErr{input.Name, "private endpoint private DNS zone"}
+	}
+
+	for _, subnet := range subnetInput {
+		if subnet.Name == input.Subnet && subnet.Existing == nil && !subnet.EnforcePrivateLinkEndpointNetworkPolicies {
+			return "", "", pulumierr.InvalidConfigErr{input.Name, "private endpoint",
+				fmt.Sprintf("subnet %s must set enforcePrivateLinkEndpointNetworkPolicies", input.Subnet)}
+		}
+	}
+
+	return subresource, zoneName, nil
+}
+
+// privateDNSZoneGroupParameters returns the parameters of the private DNS
+// zone group template deployment.
+func privateDNSZoneGroupParameters(privateEndpointName, privateDNSZoneID, privateDNSZoneName string) (string, error) {
+	content, err := json.Marshal(map[string]interface{}{
+		"privateDnsZoneId":    map[string]string{"value": privateDNSZoneID},
+		"privateDnsZoneName":  map[string]string{"value": privateDNSZoneName},
+		"privateEndpointName": map[string]string{"value": privateEndpointName},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
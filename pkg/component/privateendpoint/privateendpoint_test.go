@@ -0,0 +1,183 @@
+package privateendpoint
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+
+	vnet "github.com/ihcsim/pulumi-azure/v2/pkg/component/network"
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/mock"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/test"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/privatedns"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/privatelink"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/recoveryservices"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+func TestReconcile(t *testing.T) {
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		subnets, err := test.MockSubnets(ctx)
+		if err != nil {
+			return err
+		}
+
+		zone, err := privatedns.NewZone(ctx, test.PrivateDNSZoneName, &privatedns.ZoneArgs{
+			Name:              pulumi.String(test.PrivateDNSZoneName),
+			ResourceGroupName: resourceGroup.Name,
+		})
+		if err != nil {
+			return err
+		}
+
+		vault, err := recoveryservices.NewVault(ctx, test.RecoveryServicesVaultName, &recoveryservices.VaultArgs{
+			Name:              pulumi.String(test.RecoveryServicesVaultName),
+			ResourceGroupName: resourceGroup.Name,
+			Sku:               pulumi.String(test.RecoveryServicesVaultSKU),
+		})
+		if err != nil {
+			return err
+		}
+
+		var (
+			privateDNSZones = map[string]*privatedns.Zone{test.PrivateDNSZoneName: zone}
+			targets         = map[string]map[string]pulumi.IDOutput{
+				"recoveryServicesVault": {test.RecoveryServicesVaultName: vault.ID()},
+			}
+		)
+
+		privateEndpoints, err := Reconcile(ctx, cfg, privateDNSZones, resourceGroup, subnets, targets, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		privateEndpoint, exists := privateEndpoints[test.PrivateEndpointName]
+		if !exists {
+			t.Fatalf("expected private endpoint %s to exist", test.PrivateEndpointName)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		pulumi.All(privateEndpoint.Name, privateEndpoint.PrivateServiceConnection, vault.ID().ToStringOutput()).ApplyT(func(actuals []interface{}) error {
+			defer wg.Done()
+
+			if actual := actuals[0].(string); actual != test.PrivateEndpointName {
+				t.Errorf("mismatch name. expected: %s, actual: %s", test.PrivateEndpointName, actual)
+			}
+
+			actual := actuals[1].(privatelink.EndpointPrivateServiceConnection)
+			if expected := actuals[2].(string); actual.PrivateConnectionResourceId != expected {
+				t.Errorf("mismatch target. expected: %s, actual: %s", expected, actual.PrivateConnectionResourceId)
+			}
+
+			if expected := []string{"AzureBackup"}; !reflect.DeepEqual(expected, actual.SubresourceNames) {
+				t.Errorf("mismatch subresources. expected: %v, actual: %v", expected, actual.SubresourceNames)
+			}
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	subnetInput := []*vnet.SubnetInput{
+		{Name: "endpoints", EnforcePrivateLinkEndpointNetworkPolicies: true},
+		{Name: "subnet-00"},
+	}
+
+	targets := map[string]map[string]pulumi.IDOutput{
+		"keyVault":              {},
+		"recoveryServicesVault": {},
+		"storageAccount":        {},
+	}
+
+	var testCases = []struct {
+		input               *PrivateEndpointInput
+		expectedSubresource string
+		expectedZone        string
+	}{
+		{
+			input:               &PrivateEndpointInput{Name: "kv", Subnet: "endpoints", Target: &TargetInput{Kind: "keyVault", Name: "kv"}},
+			expectedSubresource: "vault",
+			expectedZone:        "privatelink.vaultcore.azure.net",
+		},
+		{
+			input:               &PrivateEndpointInput{Name: "files", Subnet: "endpoints", Subresource: "file", Target: &TargetInput{Kind: "storageAccount", Name: "sa"}},
+			expectedSubresource: "file",
+			expectedZone:        "privatelink.file.core.windows.net",
+		},
+		{
+			input:               &PrivateEndpointInput{Name: "backup", PrivateDNSZone: "privatelink.wus.backup.windowsazure.com", Subnet: "endpoints", Target: &TargetInput{Kind: "recoveryServicesVault", Name: "vault"}},
+			expectedSubresource: "AzureBackup",
+			expectedZone:        "privatelink.wus.backup.windowsazure.com",
+		},
+		{input: &PrivateEndpointInput{Name: "missing target", Subnet: "endpoints"}},
+		{input: &PrivateEndpointInput{Name: "unknown kind", Subnet: "endpoints", Target: &TargetInput{Kind: "cosmosAccount", Name: "db"}}},
+		{input: &PrivateEndpointInput{Name: "unknown subresource", Subnet: "endpoints", Subresource: "secrets", Target: &TargetInput{Kind: "keyVault", Name: "kv"}}},
+		{input: &PrivateEndpointInput{Name: "missing zone", Subnet: "endpoints", Target: &TargetInput{Kind: "recoveryServicesVault", Name: "vault"}}},
+		{input: &PrivateEndpointInput{Name: "network policies", Subnet: "subnet-00", Target: &TargetInput{Kind: "keyVault", Name: "kv"}}},
+	}
+
+	for _, tc := range testCases {
+		actualSubresource, actualZone, err := resolve(tc.input, subnetInput, targets)
+		if tc.expectedSubresource == "" {
+			if err == nil {
+				t.Errorf("expected error (%s)", tc.input.Name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error (%s): %s", tc.input.Name, err)
+		}
+
+		if actualSubresource != tc.expectedSubresource {
+			t.Errorf("mismatch subresource (%s). expected: %s, actual: %s", tc.input.Name, tc.expectedSubresource, actualSubresource)
+		}
+
+		if actualZone != tc.expectedZone {
+			t.Errorf("mismatch zone (%s). expected: %s, actual: %s", tc.input.Name, tc.expectedZone, actualZone)
+		}
+	}
+
+	// known kinds that aren't managed by this program can't be targeted
+	unmanaged := &PrivateEndpointInput{Name: "sql", Subnet: "endpoints", Target: &TargetInput{Kind: "sqlServer", Name: "sql"}}
+	expected := pulumierr.InvalidConfigErr{"sql", "private endpoint", `target kind "sqlServer" isn't managed by this program`}
+	if _, _, err := resolve(unmanaged, subnetInput, targets); err != expected {
+		t.Errorf("mismatch error. expected: %v, actual: %v", expected, err)
+	}
+}
+
+func TestPrivateDNSZoneGroupParameters(t *testing.T) {
+	actual, err := privateDNSZoneGroupParameters("endpoint", "zone-id", "privatelink.vaultcore.azure.net")
+	if err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+
+	parameters := map[string]map[string]string{}
+	if err := json.Unmarshal([]byte(actual), &parameters); err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+
+	expected := map[string]map[string]string{
+		"privateDnsZoneId":    {"value": "zone-id"},
+		"privateDnsZoneName":  {"value": "privatelink.vaultcore.azure.net"},
+		"privateEndpointName": {"value": "endpoint"},
+	}
+	if !reflect.DeepEqual(expected, parameters) {
+		t.Errorf("mismatch parameters. expected: %v, actual: %v", expected, parameters)
+	}
+}
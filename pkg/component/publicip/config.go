@@ -1,8 +1,20 @@
 package publicip
 
+import "github.com/ihcsim/pulumi-azure/v2/pkg/existing"
+
 type PublicIPInput struct {
+	Existing         *existing.Input
 	Name             string
 	AllocationMethod string
 	IPVersion        string
 	SKU              string
 }
+
+// PublicIPPrefixInput describes a contiguous range of public IP addresses.
+// PrefixLength is between 28 (16 addresses) and 31 (2 addresses).
+type PublicIPPrefixInput struct {
+	Name         string
+	PrefixLength int
+	SKU          string
+	Zone         string
+}
@@ -11,15 +11,39 @@ func Reconcile(
 	ctx *pulumi.Context,
 	cfg *config.Config,
 	resourceGroup *core.ResourceGroup,
-	tags pulumi.StringMap) (map[string]*network.PublicIp, error) {
+	tags pulumi.StringMap) (map[string]*network.PublicIp, map[string]*network.PublicIpPrefix, error) {
 
 	publicIPInput := []*PublicIPInput{}
 	if err := cfg.TryObject("publicIP", &publicIPInput); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	publicIPs := map[string]*network.PublicIp{}
 	for _, input := range publicIPInput {
+		if input.Existing != nil {
+			id, err := input.Existing.Resolve(input.Name, "public IP", func(name, resourceGroup string) (string, error) {
+				result, err := network.GetPublicIP(ctx, &network.GetPublicIPArgs{
+					Name:              name,
+					ResourceGroupName: resourceGroup,
+				})
+				if err != nil {
+					return "", err
+				}
+				return result.Id, nil
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+
+			publicIP, err := network.GetPublicIp(ctx, input.Name, id, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			publicIPs[input.Name] = publicIP
+			continue
+		}
+
 		publicIP, err := network.NewPublicIp(ctx, input.Name, &network.PublicIpArgs{
 			AllocationMethod:  pulumi.String(input.AllocationMethod),
 			IpVersion:         pulumi.String(input.IPVersion),
@@ -30,11 +54,53 @@ func Reconcile(
 			Tags:              tags,
 		})
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		publicIPs[input.Name] = publicIP
 	}
 
-	return publicIPs, nil
+	publicIPPrefixes, err := publicIPPrefixes(ctx, cfg, resourceGroup, tags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return publicIPs, publicIPPrefixes, nil
+}
+
+func publicIPPrefixes(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	resourceGroup *core.ResourceGroup,
+	tags pulumi.StringMap) (map[string]*network.PublicIpPrefix, error) {
+
+	prefixInput := []*PublicIPPrefixInput{}
+	if err := cfg.GetObject("publicIPPrefixes", &prefixInput); err != nil {
+		return nil, err
+	}
+
+	prefixes := map[string]*network.PublicIpPrefix{}
+	for _, input := range prefixInput {
+		args := &network.PublicIpPrefixArgs{
+			Location:          resourceGroup.Location,
+			Name:              pulumi.String(input.Name),
+			PrefixLength:      pulumi.Int(input.PrefixLength),
+			ResourceGroupName: resourceGroup.Name,
+			Sku:               pulumi.String(input.SKU),
+			Tags:              tags,
+		}
+
+		if len(input.Zone) > 0 {
+			args.Zones = pulumi.String(input.Zone)
+		}
+
+		prefix, err := network.NewPublicIpPrefix(ctx, input.Name, args)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes[input.Name] = prefix
+	}
+
+	return prefixes, nil
 }
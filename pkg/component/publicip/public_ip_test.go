@@ -22,7 +22,7 @@ func TestReconcile(t *testing.T) {
 			return err
 		}
 
-		publicIPs, err := Reconcile(ctx, cfg, resourceGroup, tags)
+		publicIPs, publicIPPrefixes, err := Reconcile(ctx, cfg, resourceGroup, tags)
 		if err != nil {
 			return err
 		}
@@ -42,18 +42,42 @@ func TestReconcile(t *testing.T) {
 				t.Errorf("allocation method mismatch. expected: %s, actual: %s", test.PublicIPAllocationMethod, actual)
 			}
 
-			if actual := actuals[1].(string); actual != test.PublicIPVersion {
-				t.Errorf("IP version mismatch. expected: %s, actual: %s", test.PublicIPVersion, actual)
+			if actual := actuals[1].(*string); actual == nil || *actual != test.PublicIPVersion {
+				t.Errorf("IP version mismatch. expected: %s, actual: %v", test.PublicIPVersion, actual)
 			}
 
-			if actual := actuals[2].(string); actual != test.PublicIPSKU {
-				t.Errorf("public IP SKU mismatch. expected: %s, actual: %s", test.PublicIPSKU, actual)
+			if actual := actuals[2].(string); actual != test.PublicIPName {
+				t.Errorf("public IP name mismatch. expected: %s, actual: %s", test.PublicIPName, actual)
+			}
+
+			if actual := actuals[3].(*string); actual == nil || *actual != test.PublicIPSKU {
+				t.Errorf("public IP SKU mismatch. expected: %s, actual: %v", test.PublicIPSKU, actual)
+			}
+
+			return nil
+		})
+
+		publicIPPrefix, exists := publicIPPrefixes[test.PublicIPPrefixName]
+		if !exists {
+			t.Fatalf("missing public IP prefix: %s", test.PublicIPPrefixName)
+		}
+
+		wg.Add(1)
+		pulumi.All(publicIPPrefix.Name, publicIPPrefix.PrefixLength).ApplyT(func(actuals []interface{}) error {
+			defer wg.Done()
+
+			if actual := actuals[0].(string); actual != test.PublicIPPrefixName {
+				t.Errorf("public IP prefix name mismatch. expected: %s, actual: %s", test.PublicIPPrefixName, actual)
+			}
+
+			if actual := actuals[1].(*int); actual == nil || *actual != test.PublicIPPrefixLength {
+				t.Errorf("public IP prefix length mismatch. expected: %d, actual: %v", test.PublicIPPrefixLength, actual)
 			}
 
-			wg.Wait()
 			return nil
 		})
 
+		wg.Wait()
 		return nil
 	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
 		t.Error(err)
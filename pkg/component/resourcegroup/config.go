@@ -1,6 +1,12 @@
 package resourcegroup
 
+import "github.com/ihcsim/pulumi-azure/v2/pkg/existing"
+
+// ResourceGroupInput describes the resource group of the stack. An existing
+// resource group is referenced by either its ID or its name, without a
+// resource group.
 type ResourceGroupInput struct {
+	Existing *existing.Input
 	Location string
 	Name     string
 }
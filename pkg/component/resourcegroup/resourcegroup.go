@@ -12,9 +12,30 @@ func Reconcile(ctx *pulumi.Context, cfg *config.Config, tags pulumi.StringMap) (
 		return nil, err
 	}
 
+	if input.Existing != nil {
+		return existingResourceGroup(ctx, &input)
+	}
+
 	return core.NewResourceGroup(ctx, string(input.Name),
 		&core.ResourceGroupArgs{
 			Location: pulumi.String(input.Location),
 			Tags:     tags,
 		})
 }
+
+// existingResourceGroup reads the existing resource group referenced by
+// input.
+func existingResourceGroup(ctx *pulumi.Context, input *ResourceGroupInput) (*core.ResourceGroup, error) {
+	id, err := input.Existing.ResolveResourceGroup(input.Name, func(name, _ string) (string, error) {
+		result, err := core.LookupResourceGroup(ctx, &core.LookupResourceGroupArgs{Name: name})
+		if err != nil {
+			return "", err
+		}
+		return result.Id, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return core.GetResourceGroup(ctx, input.Name, id, nil)
+}
@@ -0,0 +1,43 @@
+package vpngateway
+
+// VPNGatewayInput describes a site-to-site VPN gateway in the GatewaySubnet
+// of a virtual network, with its local network gateways and connections.
+type VPNGatewayInput struct {
+	Connections          []*ConnectionInput
+	Generation           string
+	LocalNetworkGateways []*LocalNetworkGatewayInput
+	Name                 string
+	PublicIP             string
+	SKU                  string
+	Subnet               string
+	VPNType              string `json:"vpnType"`
+}
+
+// LocalNetworkGatewayInput describes an on-premises VPN device, and the
+// address ranges that are reachable through it.
+type LocalNetworkGatewayInput struct {
+	AddressSpaces  []string
+	GatewayAddress string
+	Name           string
+}
+
+// ConnectionInput describes an IPsec connection between the VPN gateway and
+// one of its local network gateways. SharedKey should be set as a secret.
+type ConnectionInput struct {
+	IPsecPolicy         *IPsecPolicyInput `json:"ipsecPolicy"`
+	LocalNetworkGateway string
+	Name                string
+	SharedKey           string
+}
+
+// IPsecPolicyInput describes a custom IPsec/IKE policy of a connection.
+type IPsecPolicyInput struct {
+	DHGroup           string `json:"dhGroup"`
+	IKEEncryption     string `json:"ikeEncryption"`
+	IKEIntegrity      string `json:"ikeIntegrity"`
+	IPsecEncryption   string `json:"ipsecEncryption"`
+	IPsecIntegrity    string `json:"ipsecIntegrity"`
+	PFSGroup          string `json:"pfsGroup"`
+	SADataSizeKB      int    `json:"saDataSizeKB"`
+	SALifetimeSeconds int    `json:"saLifetimeSeconds"`
+}
@@ -0,0 +1,208 @@
+package vpngateway
+
+import (
+	"fmt"
+	"net"
+
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+// gatewaySubnetName is the name Azure requires of the subnet of a virtual
+// network gateway.
+const gatewaySubnetName = "GatewaySubnet"
+
+var knownSKUs = map[string]bool{
+	"Basic":    true,
+	"VpnGw1":   true,
+	"VpnGw2":   true,
+	"VpnGw3":   true,
+	"VpnGw4":   true,
+	"VpnGw5":   true,
+	"VpnGw1AZ": true,
+	"VpnGw2AZ": true,
+	"VpnGw3AZ": true,
+	"VpnGw4AZ": true,
+	"VpnGw5AZ": true,
+}
+
+func Reconcile(
+	ctx *pulumi.Context,
+	cfg *config.Config,
+	publicIPs map[string]*network.PublicIp,
+	resourceGroup *core.ResourceGroup,
+	subnets map[string]*network.Subnet,
+	tags pulumi.StringMap) (map[string]*network.VirtualNetworkGateway, error) {
+
+	vpnGatewayInput := []*VPNGatewayInput{}
+	if err := cfg.GetObject("vpnGateways", &vpnGatewayInput); err != nil {
+		return nil, err
+	}
+
+	vpnGateways := map[string]*network.VirtualNetworkGateway{}
+	for _, input := range vpnGatewayInput {
+		if err := validateVPNGateway(input); err != nil {
+			return nil, err
+		}
+
+		subnet, exists := subnets[input.Subnet]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{input.Subnet, "subnet"}
+		}
+
+		publicIP, exists := publicIPs[input.PublicIP]
+		if !exists {
+			return nil, pulumierr.MissingConfigErr{input.PublicIP, "public IP"}
+		}
+
+		args := &network.VirtualNetworkGatewayArgs{
+			IpConfigurations: network.VirtualNetworkGatewayIpConfigurationArray{
+				network.VirtualNetworkGatewayIpConfigurationArgs{
+					Name:                       pulumi.String(fmt.Sprintf("%s-ip-config", input.Name)),
+					PrivateIpAddressAllocation: pulumi.String("Dynamic"),
+					PublicIpAddressId:          publicIP.ID(),
+					SubnetId:                   subnet.ID(),
+				},
+			},
+			Location:          resourceGroup.Location,
+			Name:              pulumi.String(input.Name),
+			ResourceGroupName: resourceGroup.Name,
+			Sku:               pulumi.String(input.SKU),
+			Tags:              tags,
+			Type:              pulumi.String("Vpn"),
+		}
+
+		if len(input.Generation) > 0 {
+			args.Generation = pulumi.String(input.Generation)
+		}
+
+		if len(input.VPNType) > 0 {
+			args.VpnType = pulumi.String(input.VPNType)
+		}
+
+		vpnGateway, err := network.NewVirtualNetworkGateway(ctx, input.Name, args)
+		if err != nil {
+			return nil, err
+		}
+		vpnGateways[input.Name] = vpnGateway
+
+		localNetworkGateways := map[string]*network.LocalNetworkGateway{}
+		for _, localInput := range input.LocalNetworkGateways {
+			addressSpaces := pulumi.StringArray{}
+			for _, addressSpace := range localInput.AddressSpaces {
+				addressSpaces = append(addressSpaces, pulumi.String(addressSpace))
+			}
+
+			localNetworkGateway, err := network.NewLocalNetworkGateway(ctx, localInput.Name,
+				&network.LocalNetworkGatewayArgs{
+					AddressSpaces:     addressSpaces,
+					GatewayAddress:    pulumi.String(localInput.GatewayAddress),
+					Location:          resourceGroup.Location,
+					Name:              pulumi.String(localInput.Name),
+					ResourceGroupName: resourceGroup.Name,
+					Tags:              tags,
+				})
+			if err != nil {
+				return nil, err
+			}
+			localNetworkGateways[localInput.Name] = localNetworkGateway
+		}
+
+		for _, connectionInput := range input.Connections {
+			connectionArgs := &network.VirtualNetworkGatewayConnectionArgs{
+				LocalNetworkGatewayId:   localNetworkGateways[connectionInput.LocalNetworkGateway].ID(),
+				Location:                resourceGroup.Location,
+				Name:                    pulumi.String(connectionInput.Name),
+				ResourceGroupName:       resourceGroup.Name,
+				SharedKey:               pulumi.ToSecret(pulumi.String(connectionInput.SharedKey)).(pulumi.StringOutput),
+				Tags:                    tags,
+				Type:                    pulumi.String("IPsec"),
+				VirtualNetworkGatewayId: vpnGateway.ID(),
+			}
+
+			if policy := connectionInput.IPsecPolicy; policy != nil {
+				policyArgs := network.VirtualNetworkGatewayConnectionIpsecPolicyArgs{
+					DhGroup:         pulumi.String(policy.DHGroup),
+					IkeEncryption:   pulumi.String(policy.IKEEncryption),
+					IkeIntegrity:    pulumi.String(policy.IKEIntegrity),
+					IpsecEncryption: pulumi.String(policy.IPsecEncryption),
+					IpsecIntegrity:  pulumi.String(policy.IPsecIntegrity),
+					PfsGroup:        pulumi.String(policy.PFSGroup),
+				}
+
+				if policy.SADataSizeKB > 0 {
+					policyArgs.SaDatasize = pulumi.Int(policy.SADataSizeKB)
+				}
+
+				if policy.SALifetimeSeconds > 0 {
+					policyArgs.SaLifetime = pulumi.Int(policy.SALifetimeSeconds)
+				}
+
+				connectionArgs.IpsecPolicy = policyArgs
+			}
+
+			if _, err := network.NewVirtualNetworkGatewayConnection(ctx, connectionInput.Name, connectionArgs); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return vpnGateways, nil
+}
+
+// validateVPNGateway ensures that the VPN gateway is in the GatewaySubnet,
+// its SKU supports its VPN type, and its connections refer to its local
+// network gateways with a shared key.
+func validateVPNGateway(input *VPNGatewayInput) error {
+	if input.Subnet != gatewaySubnetName {
+		return pulumierr.InvalidConfigErr{input.Name, "VPN gateway",
+			fmt.Sprintf("subnet must be named %s, got %s", gatewaySubnetName, input.Subnet)}
+	}
+
+	if !knownSKUs[input.SKU] {
+		return pulumierr.InvalidConfigErr{input.Name, "VPN gateway", fmt.Sprintf("unknown SKU %q", input.SKU)}
+	}
+
+	if input.VPNType == "PolicyBased" && input.SKU != "Basic" {
+		return pulumierr.InvalidConfigErr{input.Name, "VPN gateway", "a policy-based VPN gateway requires the Basic SKU"}
+	}
+
+	if len(input.PublicIP) == 0 {
+		return pulumierr.MissingConfigErr{input.Name, "VPN gateway public IP"}
+	}
+
+	localNetworkGateways := map[string]bool{}
+	for _, localInput := range input.LocalNetworkGateways {
+		if net.ParseIP(localInput.GatewayAddress) == nil {
+			return pulumierr.InvalidConfigErr{localInput.Name, "local network gateway",
+				fmt.Sprintf("invalid gateway address %q", localInput.GatewayAddress)}
+		}
+
+		if len(localInput.AddressSpaces) == 0 {
+			return pulumierr.MissingConfigErr{localInput.Name, "local network gateway address spaces"}
+		}
+
+		for _, addressSpace := range localInput.AddressSpaces {
+			if _, _, err := net.ParseCIDR(addressSpace); err != nil {
+				return pulumierr.InvalidConfigErr{localInput.Name, "local network gateway", err.Error()}
+			}
+		}
+
+		localNetworkGateways[localInput.Name] = true
+	}
+
+	for _, connectionInput := range input.Connections {
+		if !localNetworkGateways[connectionInput.LocalNetworkGateway] {
+			return pulumierr.MissingConfigErr{connectionInput.LocalNetworkGateway, "local network gateway"}
+		}
+
+		if len(connectionInput.SharedKey) == 0 {
+			return pulumierr.MissingConfigErr{connectionInput.Name, "VPN connection shared key"}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package vpngateway
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ihcsim/pulumi-azure/v2/pkg/mock"
+	"github.com/ihcsim/pulumi-azure/v2/pkg/test"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+func TestReconcile(t *testing.T) {
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cfg := config.New(ctx, test.ConfigNamespace)
+
+		resourceGroup, err := test.MockResourceGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		publicIPs, err := test.MockPublicIPs(ctx)
+		if err != nil {
+			return err
+		}
+
+		subnets, err := test.MockSubnets(ctx)
+		if err != nil {
+			return err
+		}
+
+		vpnGateways, err := Reconcile(ctx, cfg, publicIPs, resourceGroup, subnets, test.Tags)
+		if err != nil {
+			return err
+		}
+
+		vpnGateway, exists := vpnGateways[test.VPNGatewayName]
+		if !exists {
+			t.Fatalf("expected VPN gateway %s to exist", test.VPNGatewayName)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		pulumi.All(vpnGateway.Name, vpnGateway.Sku, vpnGateway.Type).ApplyT(func(actuals []interface{}) error {
+			defer wg.Done()
+
+			if actual := actuals[0].(string); actual != test.VPNGatewayName {
+				t.Errorf("mismatch name. expected: %s, actual: %s", test.VPNGatewayName, actual)
+			}
+
+			if actual := actuals[1].(string); actual != test.VPNGatewaySKU {
+				t.Errorf("mismatch SKU. expected: %s, actual: %s", test.VPNGatewaySKU, actual)
+			}
+
+			if actual := actuals[2].(string); actual != "Vpn" {
+				t.Errorf("mismatch type. expected: Vpn, actual: %s", actual)
+			}
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, mock.WithCustomMocks(test.Project, test.Stack, test.Config, mock.Mocks(0))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateVPNGateway(t *testing.T) {
+	var (
+		localNetworkGateway = &LocalNetworkGatewayInput{
+			AddressSpaces:  []string{"192.168.0.0/16"},
+			GatewayAddress: "203.0.113.1",
+			Name:           "office",
+		}
+		connection = &ConnectionInput{
+			LocalNetworkGateway: "office",
+			Name:                "office-connection",
+			SharedKey:           "secret",
+		}
+	)
+
+	var testCases = []struct {
+		name     string
+		input    *VPNGatewayInput
+		expected bool
+	}{
+		{
+			name: "valid",
+			input: &VPNGatewayInput{Name: "vpn", PublicIP: "ip", SKU: "VpnGw1", Subnet: "GatewaySubnet",
+				LocalNetworkGateways: []*LocalNetworkGatewayInput{localNetworkGateway},
+				Connections:          []*ConnectionInput{connection}},
+			expected: true,
+		},
+		{
+			name:  "wrong subnet",
+			input: &VPNGatewayInput{Name: "vpn", PublicIP: "ip", SKU: "VpnGw1", Subnet: "subnet-00"},
+		},
+		{
+			name:  "unknown sku",
+			input: &VPNGatewayInput{Name: "vpn", PublicIP: "ip", SKU: "Standard", Subnet: "GatewaySubnet"},
+		},
+		{
+			name:  "policy-based without basic sku",
+			input: &VPNGatewayInput{Name: "vpn", PublicIP: "ip", SKU: "VpnGw1", Subnet: "GatewaySubnet", VPNType: "PolicyBased"},
+		},
+		{
+			name:  "missing public IP",
+			input: &VPNGatewayInput{Name: "vpn", SKU: "VpnGw1", Subnet: "GatewaySubnet"},
+		},
+		{
+			name: "invalid gateway address",
+			input: &VPNGatewayInput{Name: "vpn", PublicIP: "ip", SKU: "VpnGw1", Subnet: "GatewaySubnet",
+				LocalNetworkGateways: []*LocalNetworkGatewayInput{{AddressSpaces: []string{"192.168.0.0/16"}, GatewayAddress: "office.example.com", Name: "office"}}},
+		},
+		{
+			name: "invalid address space",
+			input: &VPNGatewayInput{Name: "vpn", PublicIP: "ip", SKU: "VpnGw1", Subnet: "GatewaySubnet",
+				LocalNetworkGateways: []*LocalNetworkGatewayInput{{AddressSpaces: []string{"192.168.0.0"}, GatewayAddress: "203.0.113.1", Name: "office"}}},
+		},
+		{
+			name: "unknown local network gateway",
+			input: &VPNGatewayInput{Name: "vpn", PublicIP: "ip", SKU: "VpnGw1", Subnet: "GatewaySubnet",
+				Connections: []*ConnectionInput{connection}},
+		},
+		{
+			name: "missing shared key",
+			input: &VPNGatewayInput{Name: "vpn", PublicIP: "ip", SKU: "VpnGw1", Subnet: "GatewaySubnet",
+				LocalNetworkGateways: []*LocalNetworkGatewayInput{localNetworkGateway},
+				Connections:          []*ConnectionInput{{LocalNetworkGateway: "office", Name: "office-connection"}}},
+		},
+	}
+
+	for _, tc := range testCases {
+		if err := validateVPNGateway(tc.input); (err == nil) != tc.expected {
+			t.Errorf("mismatch validation (%s). expected valid: %t, actual error: %v", tc.name, tc.expected, err)
+		}
+	}
+}
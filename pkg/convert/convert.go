@@ -0,0 +1,14 @@
+// Package convert converts config values to Pulumi inputs.
+package convert
+
+import "github.com/pulumi/pulumi/sdk/go/pulumi"
+
+// ToStringArray converts values to a pulumi.StringArray.
+func ToStringArray(values []string) pulumi.StringArray {
+	array := pulumi.StringArray{}
+	for _, value := range values {
+		array = append(array, pulumi.String(value))
+	}
+
+	return array
+}
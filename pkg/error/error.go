@@ -12,3 +12,13 @@ type MissingConfigErr struct {
 func (e MissingConfigErr) Error() string {
 	return fmt.Sprintf("missing config. name: %s, kind: %s", e.Name, e.Kind)
 }
+
+type InvalidConfigErr struct {
+	Name   string
+	Kind   string
+	Reason string
+}
+
+func (e InvalidConfigErr) Error() string {
+	return fmt.Sprintf("invalid config. name: %s, kind: %s, reason: %s", e.Name, e.Kind, e.Reason)
+}
@@ -0,0 +1,96 @@
+// Package existing resolves references to Azure resources that aren't
+// managed by this program, such as those owned by another team.
+package existing
+
+import (
+	"fmt"
+	"strings"
+
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+)
+
+// Input references an existing resource, either by its ID, or by its name and
+// resource group. The name defaults to the name of the config entry.
+type Input struct {
+	ID            string `json:"id"`
+	Name          string
+	ResourceGroup string
+}
+
+// LookupFunc returns the ID of the named resource in the resource group.
+type LookupFunc func(name, resourceGroup string) (string, error)
+
+// Resolve returns the ID of the existing resource of the named config entry,
+// using lookup to find it by name.
+func (i *Input) Resolve(name, kind string, lookup LookupFunc) (pulumi.ID, error) {
+	if len(i.ID) == 0 && len(i.ResourceGroup) == 0 {
+		return "", pulumierr.MissingConfigErr{name, kind + " existing resource group"}
+	}
+
+	return i.resolve(name, kind, lookup)
+}
+
+// ResolveResourceGroup returns the ID of the existing resource group of the
+// named config entry. A resource group reference has no resource group, so
+// lookup is called with an empty one.
+func (i *Input) ResolveResourceGroup(name string, lookup LookupFunc) (pulumi.ID, error) {
+	if len(i.ResourceGroup) > 0 {
+		return "", pulumierr.InvalidConfigErr{name, "resource group", "an existing resource group reference has no resource group"}
+	}
+
+	return i.resolve(name, "resource group", lookup)
+}
+
+func (i *Input) resolve(name, kind string, lookup LookupFunc) (pulumi.ID, error) {
+	if len(i.ID) > 0 {
+		if len(i.Name) > 0 || len(i.ResourceGroup) > 0 {
+			return "", pulumierr.InvalidConfigErr{name, kind, "an existing reference has either an ID, or a name and resource group"}
+		}
+		return pulumi.ID(i.ID), nil
+	}
+
+	resourceName := i.Name
+	if len(resourceName) == 0 {
+		resourceName = name
+	}
+
+	id, err := lookup(resourceName, i.ResourceGroup)
+	if err != nil {
+		return "", err
+	}
+
+	if len(id) == 0 {
+		if len(i.ResourceGroup) == 0 {
+			return "", pulumierr.InvalidConfigErr{name, kind, fmt.Sprintf("existing resource %s not found", resourceName)}
+		}
+		return "", pulumierr.InvalidConfigErr{name, kind,
+			fmt.Sprintf("existing resource %s not found in resource group %q", resourceName, i.ResourceGroup)}
+	}
+
+	return pulumi.ID(id), nil
+}
+
+// ParseID returns the name and resource group of the resource of the given
+// type, such as virtualNetworks, in an Azure resource ID.
+func ParseID(id, resourceType string) (name, resourceGroup string, err error) {
+	segments := strings.Split(strings.Trim(id, "/"), "/")
+	if len(segments)%2 != 0 {
+		return "", "", fmt.Errorf("malformed resource ID %q", id)
+	}
+
+	for i := 0; i < len(segments); i += 2 {
+		switch {
+		case strings.EqualFold(segments[i], "resourceGroups"):
+			resourceGroup = segments[i+1]
+		case strings.EqualFold(segments[i], resourceType):
+			name = segments[i+1]
+		}
+	}
+
+	if len(name) == 0 || len(resourceGroup) == 0 {
+		return "", "", fmt.Errorf("resource ID %q has no %s name or resource group", id, resourceType)
+	}
+
+	return name, resourceGroup, nil
+}
@@ -0,0 +1,152 @@
+package existing
+
+import (
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+)
+
+func TestResolve(t *testing.T) {
+	const (
+		entryName     = "test-entry"
+		resourceGroup = "test-platform"
+		resourceID    = "/subscriptions/00000000/resourceGroups/test-platform/providers/test/test-resource"
+	)
+
+	lookup := func(name, resourceGroup string) (string, error) {
+		if name == "missing" {
+			return "", nil
+		}
+		return "/" + resourceGroup + "/" + name, nil
+	}
+
+	var testCases = []struct {
+		input     *Input
+		expected  pulumi.ID
+		expectErr bool
+	}{
+		{input: &Input{ID: resourceID}, expected: pulumi.ID(resourceID)},
+		{input: &Input{ResourceGroup: resourceGroup}, expected: pulumi.ID("/test-platform/test-entry")},
+		{input: &Input{Name: "other", ResourceGroup: resourceGroup}, expected: pulumi.ID("/test-platform/other")},
+		{input: &Input{ID: resourceID, ResourceGroup: resourceGroup}, expectErr: true},
+		{input: &Input{Name: "other"}, expectErr: true},
+		{input: &Input{Name: "missing", ResourceGroup: resourceGroup}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		actual, err := tc.input.Resolve(entryName, "test resource", lookup)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("expected error for input %+v", tc.input)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+			continue
+		}
+
+		if actual != tc.expected {
+			t.Errorf("ID mismatch. expected: %s, actual: %s", tc.expected, actual)
+		}
+	}
+}
+
+func TestResolveResourceGroup(t *testing.T) {
+	const (
+		entryName  = "test-entry"
+		resourceID = "/subscriptions/00000000/resourceGroups/test-platform"
+	)
+
+	lookup := func(name, resourceGroup string) (string, error) {
+		if name == "missing" {
+			return "", nil
+		}
+		return "/" + name, nil
+	}
+
+	var testCases = []struct {
+		input     *Input
+		expected  pulumi.ID
+		expectErr bool
+	}{
+		{input: &Input{ID: resourceID}, expected: pulumi.ID(resourceID)},
+		{input: &Input{}, expected: pulumi.ID("/test-entry")},
+		{input: &Input{Name: "other"}, expected: pulumi.ID("/other")},
+		{input: &Input{ID: resourceID, Name: "other"}, expectErr: true},
+		{input: &Input{ResourceGroup: "test-platform"}, expectErr: true},
+		{input: &Input{Name: "missing"}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		actual, err := tc.input.ResolveResourceGroup(entryName, lookup)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("expected error for input %+v", tc.input)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+			continue
+		}
+
+		if actual != tc.expected {
+			t.Errorf("ID mismatch. expected: %s, actual: %s", tc.expected, actual)
+		}
+	}
+}
+
+func TestParseID(t *testing.T) {
+	var testCases = []struct {
+		id                    string
+		resourceType          string
+		expectedName          string
+		expectedResourceGroup string
+		expectErr             bool
+	}{
+		{
+			id:                    "/subscriptions/00000000/resourceGroups/test-platform/providers/Microsoft.Network/virtualNetworks/test-vnet",
+			resourceType:          "virtualNetworks",
+			expectedName:          "test-vnet",
+			expectedResourceGroup: "test-platform",
+		},
+		{
+			id:                    "/subscriptions/00000000/resourcegroups/test-platform/providers/Microsoft.Network/virtualNetworks/test-vnet/subnets/test-subnet",
+			resourceType:          "virtualNetworks",
+			expectedName:          "test-vnet",
+			expectedResourceGroup: "test-platform",
+		},
+		{
+			id:           "/subscriptions/00000000/resourceGroups/test-platform/providers/Microsoft.Network/networkSecurityGroups/test-nsg",
+			resourceType: "virtualNetworks",
+			expectErr:    true,
+		},
+		{
+			id:           "/subscriptions/00000000/resourceGroups",
+			resourceType: "virtualNetworks",
+			expectErr:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		name, resourceGroup, err := ParseID(tc.id, tc.resourceType)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("expected error for ID %s", tc.id)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+			continue
+		}
+
+		if name != tc.expectedName || resourceGroup != tc.expectedResourceGroup {
+			t.Errorf("mismatch. expected: %s/%s, actual: %s/%s", tc.expectedResourceGroup, tc.expectedName, resourceGroup, name)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package ipam implements a file-based IP address management registry. The
+// registry records which stack owns which CIDR, so that the virtual networks
+// of different stacks don't overlap and can be peered.
+package ipam
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Registry is the content of a registry file. Blocks are allocated from
+// Pools.
+type Registry struct {
+	Allocations []*Allocation `json:"allocations" yaml:"allocations"`
+	Pools       []string      `json:"pools" yaml:"pools"`
+}
+
+// Allocation is a CIDR owned by a stack. Stacks are keyed by their
+// <project>/<stack> names, as stacks of different projects can share names.
+type Allocation struct {
+	CIDR        string `json:"cidr" yaml:"cidr"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Stack       string `json:"stack" yaml:"stack"`
+}
+
+// CollisionErr is returned when a CIDR overlaps the allocation of another
+// stack.
+type CollisionErr struct {
+	CIDR       string
+	Allocation *Allocation
+}
+
+func (e CollisionErr) Error() string {
+	return fmt.Sprintf("CIDR collision. cidr: %s, allocated: %s, stack: %s", e.CIDR, e.Allocation.CIDR, e.Allocation.Stack)
+}
+
+// Load reads the registry file at path, which is either JSON or YAML
+// depending on its extension.
+func Load(path string) (*Registry, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := &Registry{}
+	if isJSON(path) {
+		err = json.Unmarshal(content, registry)
+	} else {
+		err = yaml.Unmarshal(content, registry)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse IPAM registry %s: %s", path, err)
+	}
+
+	for _, cidr := range registry.Pools {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, allocation := range registry.Allocations {
+		if _, _, err := net.ParseCIDR(allocation.CIDR); err != nil {
+			return nil, err
+		}
+
+		if err := validateStack(allocation.Stack); err != nil {
+			return nil, fmt.Errorf("invalid allocation %s in IPAM registry %s: %s", allocation.CIDR, path, err)
+		}
+	}
+
+	return registry, nil
+}
+
+// Save writes the registry to the file at path, in the format of its
+// extension.
+func (r *Registry) Save(path string) error {
+	var (
+		content []byte
+		err     error
+	)
+	if isJSON(path) {
+		content, err = json.MarshalIndent(r, "", "  ")
+		content = append(content, '\n')
+	} else {
+		content, err = yaml.Marshal(r)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// StackName returns the <project>/<stack> name that the allocations of a
+// stack are keyed by.
+func StackName(project, stack string) string {
+	return project + "/" + stack
+}
+
+// Check returns a CollisionErr if any of the CIDRs overlaps a CIDR allocated
+// to another stack.
+func (r *Registry) Check(stack string, cidrs []string) error {
+	if err := validateStack(stack); err != nil {
+		return err
+	}
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+
+		for _, allocation := range r.Allocations {
+			if allocation.Stack == stack {
+				continue
+			}
+
+			_, allocated, _ := net.ParseCIDR(allocation.CIDR)
+			if overlap(network, allocated) {
+				return CollisionErr{cidr, allocation}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Allocate allocates the lowest free block of the given size from the pools
+// to the stack, and returns its CIDR. The size is a prefix length such as
+// /16. If the stack already owns a block of that size, its CIDR is returned
+// instead, so that allocating twice doesn't give the stack a second block. A
+// stack that only owns blocks of other sizes is refused.
+func (r *Registry) Allocate(stack, size, description string) (string, error) {
+	if err := validateStack(stack); err != nil {
+		return "", err
+	}
+
+	length, err := strconv.Atoi(strings.TrimPrefix(size, "/"))
+	if err != nil || length < 1 || length > 32 {
+		return "", fmt.Errorf("invalid block size %q: expected a prefix length such as /16", size)
+	}
+
+	var (
+		allocated = []*net.IPNet{}
+		owned     = []string{}
+	)
+	for _, allocation := range r.Allocations {
+		_, network, _ := net.ParseCIDR(allocation.CIDR)
+		allocated = append(allocated, network)
+
+		if allocation.Stack != stack {
+			continue
+		}
+
+		if allocatedLength, _ := network.Mask.Size(); allocatedLength == length {
+			return allocation.CIDR, nil
+		}
+		owned = append(owned, allocation.CIDR)
+	}
+
+	if len(owned) > 0 {
+		return "", fmt.Errorf("stack %s already owns %s, which isn't a /%d block", stack, strings.Join(owned, ", "), length)
+	}
+
+	for _, pool := range r.Pools {
+		_, network, _ := net.ParseCIDR(pool)
+		if network.IP.To4() == nil {
+			continue
+		}
+
+		poolLength, _ := network.Mask.Size()
+		if length < poolLength {
+			continue
+		}
+
+		var (
+			blockSize = uint64(1) << uint(32-length)
+			start     = uint64(binary.BigEndian.Uint32(network.IP.To4()))
+			end       = start + uint64(1)<<uint(32-poolLength)
+		)
+		for address := start; address+blockSize <= end; address += blockSize {
+			ip := make(net.IP, net.IPv4len)
+			binary.BigEndian.PutUint32(ip, uint32(address))
+
+			block := &net.IPNet{IP: ip, Mask: net.CIDRMask(length, 32)}
+			if overlapsAny(block, allocated) {
+				continue
+			}
+
+			r.Allocations = append(r.Allocations, &Allocation{
+				CIDR:        block.String(),
+				Description: description,
+				Stack:       stack,
+			})
+			return block.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("no free /%d block in pools %s", length, strings.Join(r.Pools, ", "))
+}
+
+// validateStack ensures that the stack name is qualified by its project.
+func validateStack(stack string) error {
+	parts := strings.Split(stack, "/")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return fmt.Errorf("invalid stack %q: expected <project>/<stack>", stack)
+	}
+
+	return nil
+}
+
+func isJSON(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+func overlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func overlapsAny(network *net.IPNet, others []*net.IPNet) bool {
+	for _, other := range others {
+		if overlap(network, other) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,136 @@
+package ipam
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipam")
+	if err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	expected := &Registry{
+		Allocations: []*Allocation{
+			{CIDR: "10.0.0.0/16", Description: "dev", Stack: "network/dev"},
+		},
+		Pools: []string{"10.0.0.0/8"},
+	}
+
+	for _, file := range []string{"ipam.json", "ipam.yaml"} {
+		path := filepath.Join(dir, file)
+		if err := expected.Save(path); err != nil {
+			t.Fatalf("unexpected error (%s): %s", file, err)
+		}
+
+		actual, err := Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error (%s): %s", file, err)
+		}
+
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("mismatch registry (%s). expected: %+v, actual: %+v", file, expected, actual)
+		}
+	}
+
+	unqualified := &Registry{
+		Allocations: []*Allocation{
+			{CIDR: "10.0.0.0/16", Stack: "dev"},
+		},
+	}
+
+	path := filepath.Join(dir, "unqualified.yaml")
+	if err := unqualified.Save(path); err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for allocation keyed by bare stack name")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	registry := &Registry{
+		Allocations: []*Allocation{
+			{CIDR: "10.0.0.0/16", Stack: "network/dev"},
+			{CIDR: "10.1.0.0/16", Stack: "network/staging"},
+		},
+	}
+
+	var testCases = []struct {
+		stack    string
+		cidrs    []string
+		expected bool
+	}{
+		{stack: "network/dev", cidrs: []string{"10.0.0.0/16"}, expected: false},
+		{stack: "network/dev", cidrs: []string{"10.0.0.0/16", "10.2.0.0/16"}, expected: false},
+		{stack: "network/dev", cidrs: []string{"10.1.128.0/24"}, expected: true},
+		{stack: "network/prod", cidrs: []string{"10.0.0.0/8"}, expected: true},
+		{stack: "network/prod", cidrs: []string{"172.16.0.0/16"}, expected: false},
+		{stack: "data/dev", cidrs: []string{"10.0.0.0/16"}, expected: true},
+	}
+
+	for _, tc := range testCases {
+		err := registry.Check(tc.stack, tc.cidrs)
+		if _, ok := err.(CollisionErr); ok != tc.expected {
+			t.Errorf("mismatch collision (%s %v). expected: %t, actual: %v", tc.stack, tc.cidrs, tc.expected, err)
+		}
+	}
+
+	if err := registry.Check("dev", []string{"10.0.0.0/16"}); err == nil {
+		t.Error("expected error for bare stack name")
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	registry := &Registry{
+		Allocations: []*Allocation{
+			{CIDR: "10.0.0.0/16", Stack: "network/dev"},
+			{CIDR: "10.2.0.0/16", Stack: "network/staging"},
+		},
+		Pools: []string{"10.0.0.0/14"},
+	}
+
+	var testCases = []struct {
+		stack    string
+		size     string
+		expected string
+	}{
+		{stack: "network/prod", size: "/16", expected: "10.1.0.0/16"},
+		{stack: "network/test", size: "/24", expected: "10.3.0.0/24"},
+		{stack: "network/dev", size: "/16", expected: "10.0.0.0/16"},
+		{stack: "network/prod", size: "/16", expected: "10.1.0.0/16"},
+		{stack: "network/dev", size: "/24", expected: ""},
+		{stack: "network/qa", size: "/16", expected: ""},
+		{stack: "network/qa", size: "/8", expected: ""},
+		{stack: "network/qa", size: "16k", expected: ""},
+		{stack: "qa", size: "/24", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		actual, err := registry.Allocate(tc.stack, tc.size, "")
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("expected error (%s %s), got: %s", tc.stack, tc.size, actual)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error (%s %s): %s", tc.stack, tc.size, err)
+		}
+
+		if actual != tc.expected {
+			t.Errorf("mismatch CIDR (%s). expected: %s, actual: %s", tc.stack, tc.expected, actual)
+		}
+	}
+
+	if len(registry.Allocations) != 4 {
+		t.Errorf("mismatch allocations count. expected: 4, actual: %d", len(registry.Allocations))
+	}
+}
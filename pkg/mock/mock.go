@@ -12,6 +12,10 @@ func (m Mocks) NewResource(
 	inputs resource.PropertyMap,
 	provider, id string) (string, resource.PropertyMap, error) {
 
+	if typeToken == "pulumi:pulumi:StackReference" {
+		inputs["outputs"] = resource.NewObjectProperty(resource.PropertyMap{})
+	}
+
 	return name + "_id", inputs, nil
 }
 
@@ -20,6 +24,10 @@ func (m Mocks) Call(
 	args resource.PropertyMap,
 	provider string) (resource.PropertyMap, error) {
 
+	if name, ok := args["name"]; ok && name.IsString() {
+		args["id"] = resource.NewStringProperty(name.StringValue() + "_id")
+	}
+
 	return args, nil
 }
 
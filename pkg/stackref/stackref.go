@@ -0,0 +1,27 @@
+// Package stackref references the previous outputs of the current stack,
+// which some components read back to keep generated values stable across
+// updates.
+package stackref
+
+import (
+	"fmt"
+
+	pulumierr "github.com/ihcsim/pulumi-azure/v2/pkg/error"
+	"github.com/pulumi/pulumi/sdk/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/go/pulumi/config"
+)
+
+// Previous returns a reference named name to the current stack. The stack is
+// referenced by its fully qualified name, built from the organization config,
+// because a bare stack name resolves against the current user instead of the
+// organization that owns the stack.
+func Previous(ctx *pulumi.Context, cfg *config.Config, name string) (*pulumi.StackReference, error) {
+	organization := cfg.Get("organization")
+	if len(organization) == 0 {
+		return nil, pulumierr.MissingConfigErr{"organization", "stack organization"}
+	}
+
+	return pulumi.NewStackReference(ctx, name, &pulumi.StackReferenceArgs{
+		Name: pulumi.String(fmt.Sprintf("%s/%s/%s", organization, ctx.Project(), ctx.Stack())),
+	})
+}
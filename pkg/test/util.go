@@ -3,6 +3,7 @@ package test
 import (
 	"fmt"
 
+	"github.com/pulumi/pulumi-azure/sdk/go/azure/backup"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/core"
 	"github.com/pulumi/pulumi-azure/sdk/go/azure/network"
 	"github.com/pulumi/pulumi/sdk/go/pulumi"
@@ -11,29 +12,69 @@ import (
 const (
 	ConfigNamespace = "testConfig"
 	Location        = "uswest"
+	Organization    = "testOrganization"
 	Project         = "testProject"
 	Stack           = "testStack"
 
+	ApplicationGatewayName                    = "test-application-gateway"
+	ApplicationGatewaySKU                     = "WAF_v2"
+	ApplicationGatewaySubnetName              = "test-subnet-application-gateway"
 	AppSecGroupName                           = "test-appsec-group"
+	AutoShutdownName                          = "test-auto-shutdown"
+	AutoShutdownTime                          = "1900"
+	AutoShutdownTimezone                      = "Pacific Standard Time"
 	AvailabilitySetName                       = "test-availability-set"
+	BackupPolicyFrequency                     = "Daily"
+	BackupPolicyName                          = "test-backup-policy"
+	BackupPolicyRetentionDays                 = 7
+	BackupPolicyTime                          = "23:00"
 	BastionName                               = "test-bastion"
+	DedicatedHostGroupName                    = "test-dedicated-host-group"
+	DedicatedHostGroupZone                    = "1"
+	DedicatedHostName                         = "test-dedicated-host"
+	FirewallName                              = "test-firewall"
+	FirewallSubnetName                        = "AzureFirewallSubnet"
+	GatewaySubnetName                         = "GatewaySubnet"
+	IPConfigurationIPv6Name                   = "test-ipv6-config"
 	IPConfigurationName                       = "test-ip-configuration"
 	IPConfigurationPrivateIPAddressAllocation = "Dynamic"
 	IPConfigurationPrivateIPAddressVersion    = "IPv4"
+	LocalNetworkGatewayAddress                = "203.0.113.1"
+	LocalNetworkGatewayAddressSpace           = "192.168.0.0/16"
+	LocalNetworkGatewayName                   = "test-local-network-gateway"
+	LoadBalancerIPv6PublicIPName              = "test-public-ip-ipv6"
+	LoadBalancerName                          = "test-load-balancer"
+	LoadBalancerPort                          = 80
 	NetworkInterfaceName                      = "test-virtual-machine-00-primary"
+	NetworkSecurityRuleDenyName               = "test-network-rule-deny"
 	NetworkSecurityRuleName                   = "test-network-rule"
+	NetworkSecurityGroupHostName              = "test-network-group-host"
 	NetworkSecurityGroupName                  = "test-network-group"
 	OSProfileAdminPassword                    = "test-password"
 	OSProfileAdminUsername                    = "test-username"
 	OSProfileCustomData                       = "test-custom-data"
+	OSProfileGeneratedName                    = "test-osprofile-generated"
 	OSProfileName                             = "test-osprofile"
+	OSProfileLinuxGeneratedName               = "test-osprofile-linux-generated"
 	OSProfileLinuxName                        = "test-osprofile-linux"
-	OSProfileLinuxSSHKeyData                  = "test-key-data"
+	OSProfileLinuxSSHKeyData                  = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBJWvjlJQzaDy7jQHkktz49+Xf2EFKSzIAdLhaLD8KbP test-operator-00"
+	OSProfileLinuxSSHKeyDataAlt               = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIK1eG2ozuzC0R9asO9F2IAjC00pdvGcHV6IM6qnnGLTG test-operator-01"
 	OSProfileLinuxSSHKeyPath                  = "test-key-path"
+	PrivateEndpointName                       = "test-private-endpoint"
+	PrivateDNSZoneName                        = "test.internal"
+	PrivateDNSZoneTTL                         = 60
+	ProximityPlacementGroupName               = "test-proximity-placement-group"
+	NATGatewayIdleTimeoutMinutes              = 10
+	NATGatewayName                            = "test-nat-gateway"
+	NATGatewayZone                            = "1"
 	PublicIPAllocationMethod                  = "Static"
 	PublicIPName                              = "test-public-ip"
+	PublicIPPrefixLength                      = 30
+	PublicIPPrefixName                        = "test-public-ip-prefix"
 	PublicIPSKU                               = "Standard"
 	PublicIPVersion                           = "IPv4"
+	RecoveryServicesVaultName                 = "test-recovery-services-vault"
+	RecoveryServicesVaultSKU                  = "Standard"
 	StorageImageReferenceName                 = "test-storage-image-ref"
 	StorageImageReferenceOffer                = "test-storage-image-ref-offer"
 	StorageImageReferencePublisher            = "test-storage-image-ref-publisher"
@@ -42,35 +83,139 @@ const (
 	StorageOSDiskCreateOption                 = "test-storage-os-disk-create-option"
 	StorageOSDiskName                         = "test-storage-os-disk"
 	StorageOSDiskOSType                       = "test=storage-os-disk-os-type"
+	SubnetCarvedName                          = "test-subnet-carved"
+	SubnetCarvedSize                          = "/26"
+	SubnetDualStackIPv6AddressPrefix          = "fd00:db8:0:1::/64"
+	SubnetDualStackName                       = "test-subnet-dual-stack"
+	SubnetExistingID                          = "/subscriptions/test/resourceGroups/test-platform/providers/Microsoft.Network/virtualNetworks/test-platform/subnets/test-subnet-existing"
+	SubnetExistingName                        = "test-subnet-existing"
+	SubnetExistingPolicyName                  = "test-subnet-existing-policy"
 	SubnetName                                = "test-subnet"
+	ServiceEndpointPolicyName                 = "test-service-endpoint-policy"
+	SubnetServiceEndpoint                     = "Microsoft.Storage"
 	ResourceGroupName                         = "test-resource-group"
+	RouteTableName                            = "test-route-table"
+	RouteTableNextHopIPAddress                = "10.0.1.4"
 	VirtualMachineCustomData                  = "test-vm-custom-data"
 	VirtualMachineInstanceName                = "test-virtual-machine-00"
 	VirtualMachineName                        = "test-virtual-machine"
+	VirtualMachineOverrideInstanceName        = "test-virtual-machine-01"
+	VirtualMachineOverrideSize                = "D2_Standard"
 	VirtualMachineSize                        = "D1_Standard"
+	VPNConnectionName                         = "test-vpn-connection"
+	VPNConnectionSharedKey                    = "test-shared-key"
+	VPNGatewayName                            = "test-vpn-gateway"
+	VPNGatewaySKU                             = "VpnGw1"
+	VirtualNetworkIPv6AddressSpace            = "fd00:db8::/48"
+	VirtualNetworkExistingName                = "test-virtual-network-existing"
+	VirtualNetworkExistingResourceGroup       = "test-platform"
 	VirtualNetworkName                        = "test-virtual-network"
 	VirtualNetworkAddressSpace                = "10.0.0.0/16"
+	VirtualNetworkPeeringName                 = "test-virtual-network-peering"
+	VirtualNetworkSpokeName                   = "test-virtual-network-spoke"
+	VirtualNetworkSpokeAddressSpace           = "10.1.0.0/16"
 )
 
 var (
+	// VirtualMachineSubnetNames are the subnets of the instances of the mock
+	// virtual machine, by instance index
+	VirtualMachineSubnetNames = []string{"subnet-00", "subnet-01", "subnet-02"}
+
 	Tags = pulumi.StringMap{
 		"key": pulumi.String("value"),
 	}
 	// Config stores all the mock resources
 	Config = map[string]string{
+		// mock application gateways
+		fmt.Sprintf("%s:applicationGateways", ConfigNamespace): `
+[{
+	"backendPools": [{
+		"name": "web",
+		"vmGroups": ["` + VirtualMachineName + `"]
+	},
+	{
+		"fqdns": ["api.example.com"],
+		"name": "api"
+	}],
+	"certificates": [{
+		"data": "dGVzdC1jZXJ0aWZpY2F0ZQ==",
+		"name": "test-certificate",
+		"password": "test-certificate-password"
+	}],
+	"httpSettings": [{
+		"name": "http",
+		"port": 80,
+		"probe": "http",
+		"protocol": "Http"
+	}],
+	"listeners": [{
+		"certificate": "test-certificate",
+		"name": "https",
+		"port": 443,
+		"protocol": "Https"
+	}],
+	"name": "` + ApplicationGatewayName + `",
+	"probes": [{
+		"name": "http",
+		"path": "/",
+		"protocol": "Http"
+	}],
+	"publicIP": "` + PublicIPName + `",
+	"rules": [{
+		"backendPool": "web",
+		"httpSetting": "http",
+		"listener": "https",
+		"name": "web",
+		"pathRules": [{
+			"backendPool": "api",
+			"httpSetting": "http",
+			"name": "api",
+			"paths": ["/api/*"]
+		}]
+	}],
+	"sku": "` + ApplicationGatewaySKU + `",
+	"subnet": "` + ApplicationGatewaySubnetName + `",
+	"waf": {
+		"mode": "Prevention",
+		"ruleSetVersion": "3.1"
+	}
+}]`,
+
 		// mock application security group
 		fmt.Sprintf("%s:appSecurityGroups", ConfigNamespace): `
 [{
 	"name": "` + AppSecGroupName + `"
 }]`,
 
+		// mock auto-shutdown schedules
+		fmt.Sprintf("%s:autoShutdownSchedules", ConfigNamespace): `
+[{
+	"name": "` + AutoShutdownName + `",
+	"notificationEmail": "ops@example.com",
+	"time": "` + AutoShutdownTime + `",
+	"timezone": "` + AutoShutdownTimezone + `"
+}]`,
+
+		fmt.Sprintf("%s:defaultAutoShutdownSchedule", ConfigNamespace): AutoShutdownName,
+
 		// mock availability set
 		fmt.Sprintf("%s:availabilitySets", ConfigNamespace): `
 [{
 	"managed": true,
 	"name": "` + AvailabilitySetName + `",
 	"platformFaultDomainCount": 3,
-	"platformUpdateDomainCount": 5
+	"platformUpdateDomainCount": 5,
+	"proximityPlacementGroup": "` + ProximityPlacementGroupName + `"
+}]`,
+
+		// mock backup policies
+		fmt.Sprintf("%s:backupPolicies", ConfigNamespace): `
+[{
+	"frequency": "` + BackupPolicyFrequency + `",
+	"name": "` + BackupPolicyName + `",
+	"retentionDays": ` + fmt.Sprint(BackupPolicyRetentionDays) + `,
+	"time": "` + BackupPolicyTime + `",
+	"timezone": "UTC"
 }]`,
 
 		// mock bastion host
@@ -82,6 +227,67 @@ var (
 	"virtualNetwork": "` + VirtualNetworkName + `"
 }]`,
 
+		// mock dedicated host groups
+		fmt.Sprintf("%s:dedicatedHostGroups", ConfigNamespace): `
+[{
+	"name": "` + DedicatedHostGroupName + `",
+	"platformFaultDomainCount": 2,
+	"zone": "` + DedicatedHostGroupZone + `"
+}]`,
+
+		// mock dedicated hosts
+		fmt.Sprintf("%s:dedicatedHosts", ConfigNamespace): `
+[{
+	"hostGroup": "` + DedicatedHostGroupName + `",
+	"name": "` + DedicatedHostName + `",
+	"platformFaultDomain": 0,
+	"sku": "DSv3-Type1"
+}]`,
+
+		// mock firewalls
+		fmt.Sprintf("%s:firewalls", ConfigNamespace): `
+[{
+	"applicationRuleCollections": [{
+		"action": "Allow",
+		"name": "test-application-rules",
+		"priority": 200,
+		"rules": [{
+			"name": "allow-updates",
+			"protocols": ["Http:80", "Https:443"],
+			"sourceAddresses": ["` + VirtualNetworkAddressSpace + `"],
+			"targetFqdns": ["*.ubuntu.com"]
+		}]
+	}],
+	"name": "` + FirewallName + `",
+	"natRuleCollections": [{
+		"name": "test-nat-rules",
+		"priority": 100,
+		"rules": [{
+			"destinationPorts": ["2222"],
+			"name": "ssh",
+			"protocols": ["TCP"],
+			"sourceAddresses": ["*"],
+			"translatedAddress": "10.0.2.4",
+			"translatedPort": "22"
+		}]
+	}],
+	"networkRuleCollections": [{
+		"action": "Allow",
+		"name": "test-network-rules",
+		"priority": 100,
+		"rules": [{
+			"destinationAddresses": ["*"],
+			"destinationPorts": ["123"],
+			"name": "allow-ntp",
+			"protocols": ["UDP"],
+			"sourceAddresses": ["` + VirtualNetworkAddressSpace + `"]
+		}]
+	}],
+	"publicIP": "` + PublicIPName + `",
+	"routeSubnets": ["subnet-00"],
+	"subnet": "` + FirewallSubnetName + `"
+}]`,
+
 		// mock IP configuration
 		fmt.Sprintf("%s:ipConfiguration", ConfigNamespace): `
 [{
@@ -89,12 +295,35 @@ var (
 	"primary": true,
 	"privateIPAddressAllocation": "` + IPConfigurationPrivateIPAddressAllocation + `",
 	"privateIPAddressVersion": "` + IPConfigurationPrivateIPAddressVersion + `"
+},
+{
+	"name": "` + IPConfigurationIPv6Name + `",
+	"privateIPAddressAllocation": "Dynamic",
+	"privateIPAddressVersion": "IPv6"
 }]`,
+		// mock load balancer
+		fmt.Sprintf("%s:loadBalancers", ConfigNamespace): `
+[{
+	"backendHosts": ["` + VirtualMachineName + `"],
+	"backendPort": ` + fmt.Sprint(LoadBalancerPort) + `,
+	"frontendPort": ` + fmt.Sprint(LoadBalancerPort) + `,
+	"ipv6PublicIP": "` + LoadBalancerIPv6PublicIPName + `",
+	"name": "` + LoadBalancerName + `",
+	"probePort": ` + fmt.Sprint(LoadBalancerPort) + `,
+	"probeProtocol": "Http",
+	"probeRequestPath": "/",
+	"protocol": "Tcp",
+	"publicIP": "` + PublicIPName + `",
+	"sku": "Standard"
+}]`,
+
 		// mock network interface
 		fmt.Sprintf("%s:networkInterfaces", ConfigNamespace): `
 [{
 	"ipConfiguration": "` + IPConfigurationName + `",
-	"name": "` + NetworkInterfaceName + `"
+	"ipConfigurations": ["` + IPConfigurationIPv6Name + `"],
+	"name": "` + NetworkInterfaceName + `",
+	"securityGroup": "` + NetworkSecurityGroupHostName + `"
 }]`,
 
 		// mock network security rules
@@ -110,15 +339,33 @@ var (
   "protocol": "Tcp",
   "sourceAddressPrefix": "*",
   "sourcePortRange": "*"
-}]`,
+},
+{
+  "access": "Deny",
+  "destinationAppSecurityGroups": ["` + AppSecGroupName + `"],
+  "direction": "Inbound",
+  "name": "` + NetworkSecurityRuleDenyName + `",
+  "service": "http",
+  "sourceAddressPrefix": "*",
+  "sourcePortRange": "*"
+},
+  "allow ssh from VirtualNetwork to ` + AppSecGroupName + `"
+]`,
 
 		// mock network security groups
 		fmt.Sprintf("%s:networkSecurityGroups", ConfigNamespace): `
 [{
 	"name": "` + NetworkSecurityGroupName + `",
 	"securityRules": ["` + NetworkSecurityRuleName + `"]
+},
+{
+	"name": "` + NetworkSecurityGroupHostName + `",
+	"securityRules": ["` + NetworkSecurityRuleDenyName + `"]
 }]`,
 
+		// mock stack organization
+		fmt.Sprintf("%s:organization", ConfigNamespace): Organization,
+
 		// mock OS profile
 		fmt.Sprintf("%s:osProfiles", ConfigNamespace): `
 [{
@@ -126,6 +373,10 @@ var (
 	"adminUsername": "` + OSProfileAdminUsername + `",
 	"customData": "` + OSProfileCustomData + `",
 	"name": "` + OSProfileName + `"
+},
+{
+	"adminUsername": "` + OSProfileAdminUsername + `",
+	"name": "` + OSProfileGeneratedName + `"
 }]`,
 
 		// mock OS profile Linux
@@ -134,7 +385,54 @@ var (
 	"DisablePasswordAuthentication": true,
 	"Name": "` + OSProfileLinuxName + `",
 	"SSHKeyData": "` + OSProfileLinuxSSHKeyData + `",
-	"SSHKeyPath": "` + OSProfileLinuxSSHKeyPath + `"
+	"SSHKeyPath": "` + OSProfileLinuxSSHKeyPath + `",
+	"sshKeys": [{
+		"keyData": "` + OSProfileLinuxSSHKeyDataAlt + `"
+	}]
+},
+{
+	"disablePasswordAuthentication": true,
+	"name": "` + OSProfileLinuxGeneratedName + `",
+	"sshKeyPath": "` + OSProfileLinuxSSHKeyPath + `"
+}]`,
+
+		// mock NAT gateways
+		fmt.Sprintf("%s:natGateways", ConfigNamespace): `
+[{
+	"idleTimeoutMinutes": ` + fmt.Sprint(NATGatewayIdleTimeoutMinutes) + `,
+	"name": "` + NATGatewayName + `",
+	"publicIPPrefixes": ["` + PublicIPPrefixName + `"],
+	"publicIPs": ["` + PublicIPName + `"],
+	"zones": ["` + NATGatewayZone + `"]
+}]`,
+
+		// mock private DNS zones
+		fmt.Sprintf("%s:privateDnsZones", ConfigNamespace): `
+[{
+	"name": "` + PrivateDNSZoneName + `",
+	"records": true,
+	"ttl": ` + fmt.Sprint(PrivateDNSZoneTTL) + `,
+	"virtualNetworkLinks": [{
+		"virtualNetwork": "` + VirtualNetworkName + `"
+	}]
+}]`,
+
+		// mock private endpoints
+		fmt.Sprintf("%s:privateEndpoints", ConfigNamespace): `
+[{
+	"name": "` + PrivateEndpointName + `",
+	"privateDnsZone": "` + PrivateDNSZoneName + `",
+	"subnet": "` + SubnetName + `",
+	"target": {
+		"kind": "recoveryServicesVault",
+		"name": "` + RecoveryServicesVaultName + `"
+	}
+}]`,
+
+		// mock proximity placement groups
+		fmt.Sprintf("%s:proximityPlacementGroups", ConfigNamespace): `
+[{
+	"name": "` + ProximityPlacementGroupName + `"
 }]`,
 
 		// mock public IP
@@ -146,6 +444,31 @@ var (
 	"sku": "` + PublicIPSKU + `"
 }]`,
 
+		// mock public IP prefixes
+		fmt.Sprintf("%s:publicIPPrefixes", ConfigNamespace): `
+[{
+	"name": "` + PublicIPPrefixName + `",
+	"prefixLength": ` + fmt.Sprint(PublicIPPrefixLength) + `,
+	"sku": "` + PublicIPSKU + `"
+}]`,
+
+		// mock route tables
+		fmt.Sprintf("%s:routeTables", ConfigNamespace): `
+[{
+	"disableBGPRoutePropagation": true,
+	"name": "` + RouteTableName + `",
+	"routes": [{
+		"addressPrefix": "0.0.0.0/0",
+		"name": "default",
+		"nextHopIPAddress": "` + RouteTableNextHopIPAddress + `",
+		"nextHopType": "VirtualAppliance"
+	}, {
+		"addressPrefix": "10.2.0.0/16",
+		"name": "blackhole",
+		"nextHopType": "None"
+	}]
+}]`,
+
 		// mock storage image reference
 		fmt.Sprintf("%s:storageImageReference", ConfigNamespace): `
 [{
@@ -156,6 +479,17 @@ var (
 	"version": "` + StorageImageReferenceVersion + `"
 }]`,
 
+		// mock service endpoint policy
+		fmt.Sprintf("%s:serviceEndpointPolicies", ConfigNamespace): `
+[{
+	"definitions": [{
+		"name": "allow-test-storage",
+		"service": "` + SubnetServiceEndpoint + `",
+		"serviceResources": ["/subscriptions/00000000/resourceGroups/` + ResourceGroupName + `"]
+	}],
+	"name": "` + ServiceEndpointPolicyName + `"
+}]`,
+
 		// mock storage os disk
 		fmt.Sprintf("%s:storageOSDisk", ConfigNamespace): `
 [{
@@ -170,9 +504,48 @@ var (
 [{
 	"name": "` + SubnetName + `",
 	"addressPrefix": "10.0.0.0/24",
-	"securityGroup": "` + NetworkSecurityGroupName + `"
+	"enforcePrivateLinkEndpointNetworkPolicies": true,
+	"natGateway": "` + NATGatewayName + `",
+	"routeTable": "` + RouteTableName + `",
+	"securityGroup": "` + NetworkSecurityGroupName + `",
+	"serviceEndpoints": ["` + SubnetServiceEndpoint + `"]
+},
+{
+	"name": "` + SubnetDualStackName + `",
+	"addressPrefix": "10.0.1.0/24",
+	"ipv6AddressPrefix": "` + SubnetDualStackIPv6AddressPrefix + `",
+	"securityGroup": "` + NetworkSecurityGroupName + `",
+	"serviceEndpointPolicies": ["` + ServiceEndpointPolicyName + `"],
+	"serviceEndpoints": ["` + SubnetServiceEndpoint + `"]
+},
+{
+	"name": "` + SubnetCarvedName + `",
+	"size": "` + SubnetCarvedSize + `"
+},
+{
+	"addressPrefix": "10.0.255.0/27",
+	"name": "` + GatewaySubnetName + `"
+},
+{
+	"existing": {
+		"id": "` + SubnetExistingID + `"
+	},
+	"name": "` + SubnetExistingName + `"
+},
+{
+	"addressPrefix": "10.1.0.0/24",
+	"name": "` + SubnetExistingPolicyName + `",
+	"serviceEndpointPolicies": ["` + ServiceEndpointPolicyName + `"],
+	"serviceEndpoints": ["` + SubnetServiceEndpoint + `"]
 }]`,
 
+		// mock recovery services vault
+		fmt.Sprintf("%s:recoveryServicesVault", ConfigNamespace): `
+{
+	"name": "` + RecoveryServicesVaultName + `",
+	"sku": "` + RecoveryServicesVaultSKU + `"
+}`,
+
 		fmt.Sprintf("%s:resourceGroup", ConfigNamespace): `
 {
 	"location": "` + Location + `",
@@ -184,12 +557,24 @@ var (
 [{
 	"appSecGroup": "` + AppSecGroupName + `",
 	"availabilitySet": "` + AvailabilitySetName + `",
+	"backupPolicy": "` + BackupPolicyName + `",
 	"count": 3,
 	"customData": "` + VirtualMachineCustomData + `",
 	"name": "` + VirtualMachineName + `",
 	"networkInterface": "` + NetworkInterfaceName + `",
 	"osProfile": "` + OSProfileName + `",
 	"osProfileLinux": "` + OSProfileLinuxName + `",
+	"rollingUpdate": {
+		"maxUnavailable": 1
+	},
+	"overrides": {
+		"1": {
+			"vmSize": "` + VirtualMachineOverrideSize + `"
+		},
+		"` + VirtualMachineOverrideInstanceName + `": {
+			"tags": {"debug": "true"}
+		}
+	},
 	"storageImageReference": "` + StorageImageReferenceName + `",
 	"storageOSDisk": "` + StorageOSDiskName + `",
 	"virtualNetwork": "` + VirtualNetworkName + `",
@@ -199,9 +584,62 @@ var (
 		// mock virtual network
 		fmt.Sprintf("%s:virtualNetworks", ConfigNamespace): `
 [{
+	"addressSpaces": ["` + VirtualNetworkIPv6AddressSpace + `"],
 	"name": "` + VirtualNetworkName + `",
 	"cidr": "` + VirtualNetworkAddressSpace + `",
-	"subnets": ["` + SubnetName + `"]
+	"subnets": ["` + SubnetName + `", "` + SubnetDualStackName + `", "` + SubnetCarvedName + `", "` + GatewaySubnetName + `"]
+},
+{
+	"name": "` + VirtualNetworkSpokeName + `",
+	"cidr": "` + VirtualNetworkSpokeAddressSpace + `",
+	"subnets": []
+},
+{
+	"existing": {
+		"name": "test-platform",
+		"resourceGroup": "` + VirtualNetworkExistingResourceGroup + `"
+	},
+	"name": "` + VirtualNetworkExistingName + `",
+	"subnets": ["` + SubnetExistingName + `", "` + SubnetExistingPolicyName + `"]
+}]`,
+
+		// mock VPN gateway
+		fmt.Sprintf("%s:vpnGateways", ConfigNamespace): `
+[{
+	"connections": [{
+		"ipsecPolicy": {
+			"dhGroup": "DHGroup14",
+			"ikeEncryption": "AES256",
+			"ikeIntegrity": "SHA256",
+			"ipsecEncryption": "AES256",
+			"ipsecIntegrity": "SHA256",
+			"pfsGroup": "PFS2048",
+			"saLifetimeSeconds": 27000
+		},
+		"localNetworkGateway": "` + LocalNetworkGatewayName + `",
+		"name": "` + VPNConnectionName + `",
+		"sharedKey": "` + VPNConnectionSharedKey + `"
+	}],
+	"localNetworkGateways": [{
+		"addressSpaces": ["` + LocalNetworkGatewayAddressSpace + `"],
+		"gatewayAddress": "` + LocalNetworkGatewayAddress + `",
+		"name": "` + LocalNetworkGatewayName + `"
+	}],
+	"name": "` + VPNGatewayName + `",
+	"publicIP": "` + PublicIPName + `",
+	"sku": "` + VPNGatewaySKU + `",
+	"subnet": "` + GatewaySubnetName + `"
+}]`,
+
+		// mock virtual network peering
+		fmt.Sprintf("%s:virtualNetworkPeerings", ConfigNamespace): `
+[{
+	"allowForwardedTraffic": true,
+	"allowGatewayTransit": true,
+	"localVirtualNetwork": "` + VirtualNetworkName + `",
+	"name": "` + VirtualNetworkPeeringName + `",
+	"remoteVirtualNetwork": "` + VirtualNetworkSpokeName + `",
+	"useRemoteGateways": true
 }]`,
 	}
 )
@@ -221,6 +659,43 @@ func MockApplicationSecurityGroup(ctx *pulumi.Context) (map[string]*network.Appl
 	return appSecGroups, nil
 }
 
+func MockBackupPolicies(ctx *pulumi.Context) (map[string]*backup.PolicyVM, error) {
+	backupPolicies := map[string]*backup.PolicyVM{}
+	backupPolicy, err := backup.NewPolicyVM(ctx, BackupPolicyName, &backup.PolicyVMArgs{
+		Backup: backup.PolicyVMBackupArgs{
+			Frequency: pulumi.String(BackupPolicyFrequency),
+			Time:      pulumi.String(BackupPolicyTime),
+		},
+		Name:              pulumi.String(BackupPolicyName),
+		RecoveryVaultName: pulumi.String(RecoveryServicesVaultName),
+		ResourceGroupName: pulumi.String(ResourceGroupName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	backupPolicies[BackupPolicyName] = backupPolicy
+	return backupPolicies, nil
+}
+
+func MockNetworkSecurityGroups(ctx *pulumi.Context) (map[string]pulumi.IDOutput, error) {
+	securityGroups := map[string]pulumi.IDOutput{}
+	for _, name := range []string{NetworkSecurityGroupName, NetworkSecurityGroupHostName} {
+		securityGroup, err := network.NewNetworkSecurityGroup(ctx, name, &network.NetworkSecurityGroupArgs{
+			Location:          pulumi.String(Location),
+			Name:              pulumi.String(name),
+			ResourceGroupName: pulumi.String(ResourceGroupName),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		securityGroups[name] = securityGroup.ID()
+	}
+
+	return securityGroups, nil
+}
+
 func MockPublicIPs(ctx *pulumi.Context) (map[string]*network.PublicIp, error) {
 	publicIPs := map[string]*network.PublicIp{}
 	publicIP, err := network.NewPublicIp(ctx, PublicIPName, &network.PublicIpArgs{
@@ -239,6 +714,23 @@ func MockPublicIPs(ctx *pulumi.Context) (map[string]*network.PublicIp, error) {
 	return publicIPs, nil
 }
 
+func MockPublicIPPrefixes(ctx *pulumi.Context) (map[string]*network.PublicIpPrefix, error) {
+	publicIPPrefixes := map[string]*network.PublicIpPrefix{}
+	publicIPPrefix, err := network.NewPublicIpPrefix(ctx, PublicIPPrefixName, &network.PublicIpPrefixArgs{
+		Location:          pulumi.String(Location),
+		Name:              pulumi.String(PublicIPPrefixName),
+		PrefixLength:      pulumi.Int(PublicIPPrefixLength),
+		ResourceGroupName: pulumi.String(ResourceGroupName),
+		Sku:               pulumi.String(PublicIPSKU),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	publicIPPrefixes[PublicIPPrefixName] = publicIPPrefix
+	return publicIPPrefixes, nil
+}
+
 func MockResourceGroup(ctx *pulumi.Context) (*core.ResourceGroup, error) {
 	return core.NewResourceGroup(ctx, ResourceGroupName, &core.ResourceGroupArgs{
 		Location: pulumi.String(Location),
@@ -246,13 +738,26 @@ func MockResourceGroup(ctx *pulumi.Context) (*core.ResourceGroup, error) {
 	})
 }
 
-func MockVirtualNetworks(ctx *pulumi.Context) (map[string]*network.VirtualNetwork, error) {
+func MockSubnets(ctx *pulumi.Context) (map[string]*network.Subnet, error) {
+	subnets := map[string]*network.Subnet{}
+	for i, name := range append([]string{SubnetName, ApplicationGatewaySubnetName, FirewallSubnetName, GatewaySubnetName}, VirtualMachineSubnetNames...) {
+		subnet, err := network.NewSubnet(ctx, name, &network.SubnetArgs{
+			AddressPrefix:      pulumi.Sprintf("10.0.%d.0/24", i),
+			Name:               pulumi.String(name),
+			ResourceGroupName:  pulumi.String(ResourceGroupName),
+			VirtualNetworkName: pulumi.String(VirtualNetworkName),
+		})
+		if err != nil {
+			return nil, err
+		}
 
-	subnet := &network.VirtualNetworkSubnetArgs{
-		Name: pulumi.String(SubnetName),
-		Id:   pulumi.String(SubnetName + "_id"),
+		subnets[name] = subnet
 	}
 
+	return subnets, nil
+}
+
+func MockVirtualNetworks(ctx *pulumi.Context) (map[string]*network.VirtualNetwork, error) {
 	virtualNetworks := map[string]*network.VirtualNetwork{}
 	virtualNetwork, err := network.NewVirtualNetwork(ctx, VirtualNetworkName,
 		&network.VirtualNetworkArgs{
@@ -260,7 +765,6 @@ func MockVirtualNetworks(ctx *pulumi.Context) (map[string]*network.VirtualNetwor
 			Location:          pulumi.String(Location),
 			Name:              pulumi.String(VirtualNetworkName),
 			ResourceGroupName: pulumi.String(ResourceGroupName),
-			Subnets:           network.VirtualNetworkSubnetArray{subnet},
 		})
 	if err != nil {
 		return nil, err